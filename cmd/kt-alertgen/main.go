@@ -0,0 +1,74 @@
+// kt-alertgen generates a Prometheus rule file (recording and alerting rules) for the predefined templates and/or the templates in a definitions file, e.g.
+//
+//	go run github.com/keytiles/lib-observability-golang/v2/cmd/kt-alertgen -predefined -service my-service -error-ratio 0.01 -out rules.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_alerting"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+func main() {
+	thresholds := kt_observability_alerting.DefaultThresholds
+
+	defsPath := flag.String("defs", "", "path of a template definitions file (.yaml or .json) to generate rules for")
+	withPredefined := flag.Bool("predefined", false, "generate rules for the predefined templates (execCount, errorCount, ...) too")
+	serviceName := flag.String("service", "", "generate the rules for this service only - all services if not given")
+	rateWindow := flag.String("rate-window", "5m", "the range rates are calculated over")
+	outPath := flag.String("out", "", "path of the generated file - stdout if not given")
+	flag.Float64Var(&thresholds.ErrorRatio, "error-ratio", thresholds.ErrorRatio, "alert threshold of errorCount / execCount (0..1)")
+	flag.Float64Var(&thresholds.ClientFailureRatio, "client-failure-ratio", thresholds.ClientFailureRatio, "alert threshold of clientReqFailedCount / clientReqSentCount (0..1)")
+	flag.Float64Var(&thresholds.ServerFailureRatio, "server-failure-ratio", thresholds.ServerFailureRatio, "alert threshold of serverServeFailedCount / serverServeStartedCount (0..1)")
+	flag.Float64Var(&thresholds.ClientLatencyMillis, "client-latency-ms", thresholds.ClientLatencyMillis, "alert threshold of clientReqProcessingTime quantile in millis")
+	flag.Float64Var(&thresholds.ServerLatencyMillis, "server-latency-ms", thresholds.ServerLatencyMillis, "alert threshold of serverServeProcessingTime quantile in millis")
+	flag.Float64Var(&thresholds.LatencyQuantile, "latency-quantile", thresholds.LatencyQuantile, "the quantile latency alerts are watching")
+	flag.StringVar(&thresholds.For, "for", thresholds.For, "how long the condition must hold before the alert fires")
+	flag.StringVar(&thresholds.Severity, "severity", thresholds.Severity, "value of the 'severity' label of the alerts")
+	flag.Parse()
+
+	if err := run(*defsPath, *withPredefined, *serviceName, *rateWindow, thresholds, *outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "kt-alertgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(defsPath string, withPredefined bool, serviceName string, rateWindow string, thresholds kt_observability_alerting.Thresholds, outPath string) error {
+	if defsPath == "" && !withPredefined {
+		return fmt.Errorf("nothing to generate - use -defs and/or -predefined")
+	}
+
+	var templates []kt_observability_monitoring.MetricTemplateInfo
+	if withPredefined {
+		kt_observability_monitoring.InitMetrics()
+		templates = append(templates, kt_observability_monitoring.RegisteredMetricTemplateInfos()...)
+	}
+	if defsPath != "" {
+		defs, err := kt_observability_monitoring.ParseMetricTemplateDefinitions(defsPath)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, defs.Infos()...)
+	}
+
+	ruleFile, err := kt_observability_alerting.BuildRules(templates,
+		kt_observability_alerting.WithThresholds(thresholds),
+		kt_observability_alerting.WithRateWindow(rateWindow),
+		kt_observability_alerting.WithServiceName(serviceName),
+	)
+	if err != nil {
+		return err
+	}
+	rules, err := ruleFile.Yaml()
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err = os.Stdout.Write(rules)
+		return err
+	}
+	return os.WriteFile(outPath, rules, 0644)
+}
@@ -0,0 +1,38 @@
+// kt-configdoc generates the documentation of the KT_OBS_* env vars from kt_observability_setup.Config, e.g.
+//
+//	go run github.com/keytiles/lib-observability-golang/v2/cmd/kt-configdoc -out CONFIGURATION.md
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_setup"
+)
+
+const header = "# Configuration\n\n" +
+	"Every setting of the library can be given in env vars - take them with `kt_observability_setup.ConfigFromEnv()` and apply them with\n" +
+	"`kt_observability_setup.Setup(kt_observability_setup.WithConfig(config))`. Env vars starting with `" + kt_observability_setup.ConfigEnvPrefix + "` which\n" +
+	"are not listed here are reported as errors - so typos do not go unnoticed.\n\n" +
+	"This file is generated from the `Config` struct - do not edit it by hand, run `go generate ./...` instead.\n\n"
+
+func main() {
+	outPath := flag.String("out", "", "path of the generated file - stdout if not given")
+	flag.Parse()
+
+	doc := []byte(header + kt_observability_setup.ConfigDocumentation())
+	if *outPath == "" {
+		_, err := os.Stdout.Write(doc)
+		exitOnError(err)
+		return
+	}
+	exitOnError(os.WriteFile(*outPath, doc, 0644))
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kt-configdoc: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,56 @@
+// kt-grafanagen generates a Grafana dashboard (JSON) for the predefined templates and/or the templates in a definitions file, e.g.
+//
+//	go run github.com/keytiles/lib-observability-golang/v2/cmd/kt-grafanagen -predefined -defs metric_templates.yaml -title "My service" -out dashboard.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_grafana"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+func main() {
+	defsPath := flag.String("defs", "", "path of a template definitions file (.yaml or .json) to generate panels for")
+	withPredefined := flag.Bool("predefined", false, "generate panels for the predefined templates (execCount, errorCount, ...) too")
+	title := flag.String("title", "Service overview", "title of the dashboard")
+	uid := flag.String("uid", "", "uid of the dashboard - optional")
+	outPath := flag.String("out", "", "path of the generated file - stdout if not given")
+	flag.Parse()
+
+	if err := run(*defsPath, *withPredefined, *title, *uid, *outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "kt-grafanagen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(defsPath string, withPredefined bool, title string, uid string, outPath string) error {
+	if defsPath == "" && !withPredefined {
+		return fmt.Errorf("nothing to generate - use -defs and/or -predefined")
+	}
+
+	var templates []kt_observability_monitoring.MetricTemplateInfo
+	if withPredefined {
+		kt_observability_monitoring.InitMetrics()
+		templates = append(templates, kt_observability_monitoring.RegisteredMetricTemplateInfos()...)
+	}
+	if defsPath != "" {
+		defs, err := kt_observability_monitoring.ParseMetricTemplateDefinitions(defsPath)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, defs.Infos()...)
+	}
+
+	dashboardJson, err := kt_observability_grafana.BuildDashboard(title, templates, kt_observability_grafana.WithUid(uid)).JSON()
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err = os.Stdout.Write(append(dashboardJson, '\n'))
+		return err
+	}
+	return os.WriteFile(outPath, append(dashboardJson, '\n'), 0644)
+}
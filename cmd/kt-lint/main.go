@@ -0,0 +1,87 @@
+// kt-lint checks metrics against the Keytiles monitoring standards. It can check a running service, a saved /metrics output or template definitions, e.g.
+//
+//	go run github.com/keytiles/lib-observability-golang/v2/cmd/kt-lint -url http://localhost:9008/metrics
+//	go run github.com/keytiles/lib-observability-golang/v2/cmd/kt-lint -defs metric_templates.yaml
+//
+// Exits with code 1 if violations were found - so it can break your CI build.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_lint"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+func main() {
+	defsPath := flag.String("defs", "", "path of a template definitions file (.yaml or .json) to check")
+	metricsUrl := flag.String("url", "", "URL of a /metrics endpoint to check")
+	metricsPath := flag.String("file", "", "path of a file with metrics in text exposition format to check")
+	globalLabels := flag.String("global-labels", "", "comma separated global label names every metric must have - the standard ones if not given")
+	flag.Parse()
+
+	var opts []kt_observability_lint.LintOpt
+	if *globalLabels != "" {
+		opts = append(opts, kt_observability_lint.WithGlobalLabelNames(strings.Split(*globalLabels, ",")...))
+	}
+
+	violations, err := run(*defsPath, *metricsUrl, *metricsPath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kt-lint: %v\n", err)
+		os.Exit(2)
+	}
+	for _, violation := range violations {
+		fmt.Println(violation.String())
+	}
+	if len(violations) > 0 {
+		fmt.Fprintf(os.Stderr, "kt-lint: %d violations found\n", len(violations))
+		os.Exit(1)
+	}
+}
+
+func run(defsPath string, metricsUrl string, metricsPath string, opts []kt_observability_lint.LintOpt) ([]kt_observability_lint.Violation, error) {
+	if defsPath == "" && metricsUrl == "" && metricsPath == "" {
+		return nil, fmt.Errorf("nothing to check - use -defs, -url or -file")
+	}
+
+	var violations []kt_observability_lint.Violation
+	if defsPath != "" {
+		defs, err := kt_observability_monitoring.ParseMetricTemplateDefinitions(defsPath)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, kt_observability_lint.LintTemplates(defs.Infos(), opts...)...)
+	}
+	if metricsUrl != "" {
+		resp, err := http.Get(metricsUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch metrics from %v! error was: %v", metricsUrl, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch metrics from %v! response status: %v", metricsUrl, resp.Status)
+		}
+		found, err := kt_observability_lint.LintExposition(resp.Body, opts...)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+	}
+	if metricsPath != "" {
+		file, err := os.Open(metricsPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		found, err := kt_observability_lint.LintExposition(file, opts...)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+	}
+	return violations, nil
+}
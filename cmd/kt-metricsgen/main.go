@@ -0,0 +1,202 @@
+// kt-metricsgen generates type-safe accessors for metric templates - so instead of
+//
+//	kt_observability_monitoring.GetCounterMetricInstance(kt_observability_monitoring.GetExecCountTemplate(), map[string]any{"of": "x", "qualifier": "y"})
+//
+// you can simply write
+//
+//	ExecCount("x", "y")
+//
+// The label names are checked at compile time this way. The template is looked up only once and the instance is taken by the label values - so unless the
+// template has a cardinality limit, an instance TTL or allowed label values there is no map building on the hot path.
+//
+// Use it from go generate, e.g.
+//
+//	//go:generate go run github.com/keytiles/lib-observability-golang/v2/cmd/kt-metricsgen -defs metric_templates.yaml -predefined -out metrics_gen.go
+//
+// Templates coming from a definitions file (-defs) must be loaded with kt_observability_monitoring.LoadMetricTemplates() before the accessors are used.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+// what the code template gets for one accessor
+type accessor struct {
+	FuncName   string
+	VarName    string
+	Name       string
+	Help       string
+	Params     []string
+	ReturnType string
+	GetterFunc string
+}
+
+var codeTemplate = template.Must(template.New("code").Parse(`// Code generated by kt-metricsgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"sync/atomic"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+)
+{{range .Accessors}}
+var {{.VarName}} = &ktMetricsgenTemplate{name: "{{.Name}}"}
+
+// {{.FuncName}} returns the instance of the "{{.Name}}" metric template for the given label values.{{if .Help}}
+//
+// {{.Help}}{{end}}
+func {{.FuncName}}({{range $i, $p := .Params}}{{if $i}}, {{end}}{{$p}}{{end}}{{if .Params}} string{{end}}) {{.ReturnType}} {
+	return kt_observability_monitoring.{{.GetterFunc}}({{.VarName}}.get(){{range .Params}}, {{.}}{{end}})
+}
+{{end}}
+// the template is looked up on first use - templates from definitions files are registered at runtime. And again if it is not registered anymore, as
+// InitMetrics() re-creates the templates.
+type ktMetricsgenTemplate struct {
+	name string
+	tpl  atomic.Pointer[kt_observability_monitoring.MetricTemplate]
+}
+
+func (t *ktMetricsgenTemplate) get() kt_observability_monitoring.MetricTemplate {
+	if tpl := t.tpl.Load(); tpl != nil && tpl.IsRegistered() {
+		return *tpl
+	}
+	tpl, found := kt_observability_monitoring.GetRegisteredMetricTemplate(t.name)
+	if !found {
+		panic("metric template '" + t.name + "' is not registered - was it loaded?")
+	}
+	t.tpl.Store(&tpl)
+	return tpl
+}
+`))
+
+func main() {
+	defsPath := flag.String("defs", "", "path of a template definitions file (.yaml or .json) to generate accessors for")
+	withPredefined := flag.Bool("predefined", false, "generate accessors for the predefined templates (execCount, errorCount, ...) too")
+	packageName := flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file - defaults to $GOPACKAGE set by go generate")
+	outPath := flag.String("out", "metrics_gen.go", "path of the generated file")
+	flag.Parse()
+
+	if err := run(*defsPath, *withPredefined, *packageName, *outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "kt-metricsgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(defsPath string, withPredefined bool, packageName string, outPath string) error {
+	if packageName == "" {
+		// let's go with the name of the directory
+		absOutPath, err := filepath.Abs(outPath)
+		if err != nil {
+			return err
+		}
+		packageName = filepath.Base(filepath.Dir(absOutPath))
+	}
+	if defsPath == "" && !withPredefined {
+		return fmt.Errorf("nothing to generate - use -defs and/or -predefined")
+	}
+
+	var templates []kt_observability_monitoring.MetricTemplateInfo
+	if withPredefined {
+		kt_observability_monitoring.InitMetrics()
+		templates = append(templates, kt_observability_monitoring.RegisteredMetricTemplateInfos()...)
+	}
+	if defsPath != "" {
+		defs, err := kt_observability_monitoring.ParseMetricTemplateDefinitions(defsPath)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, defs.Infos()...)
+	}
+
+	code, err := generate(packageName, templates)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, code, 0644)
+}
+
+// generates the (gofmt-ed) source code of the accessors
+func generate(packageName string, templates []kt_observability_monitoring.MetricTemplateInfo) ([]byte, error) {
+	var accessors []accessor
+	seenFuncNames := make(map[string]string)
+	for _, tpl := range templates {
+		acc := accessor{
+			FuncName: goIdentifier(tpl.Name, true),
+			VarName:  "ktMetricsgen" + goIdentifier(tpl.Name, true) + "Template",
+			Name:     tpl.Name,
+			Help:     strings.Join(strings.Fields(tpl.Help), " "),
+		}
+		if otherName, found := seenFuncNames[acc.FuncName]; found {
+			return nil, fmt.Errorf("templates '%v' and '%v' would get the same accessor name %v", otherName, tpl.Name, acc.FuncName)
+		}
+		seenFuncNames[acc.FuncName] = tpl.Name
+
+		switch tpl.MetricType {
+		case "counter":
+			acc.ReturnType, acc.GetterFunc = "prometheus.Counter", "GetCounterMetricInstanceByLabelValues"
+		case "gauge":
+			acc.ReturnType, acc.GetterFunc = "prometheus.Gauge", "GetGaugeMetricInstanceByLabelValues"
+		case "summary":
+			acc.ReturnType, acc.GetterFunc = "prometheus.Observer", "GetSummaryMetricInstanceByLabelValues"
+		case "histogram":
+			acc.ReturnType, acc.GetterFunc = "prometheus.Observer", "GetHistogramMetricInstanceByLabelValues"
+		default:
+			return nil, fmt.Errorf("template '%v' has unknown type '%v'", tpl.Name, tpl.MetricType)
+		}
+
+		seenParams := make(map[string]string)
+		for _, labelName := range tpl.LabelNames {
+			param := goIdentifier(labelName, false)
+			if otherLabelName, found := seenParams[param]; found {
+				return nil, fmt.Errorf("template '%v': labels '%v' and '%v' would get the same parameter name %v", tpl.Name, otherLabelName, labelName, param)
+			}
+			seenParams[param] = labelName
+			acc.Params = append(acc.Params, param)
+		}
+		accessors = append(accessors, acc)
+	}
+
+	var buf bytes.Buffer
+	err := codeTemplate.Execute(&buf, map[string]any{"Package": packageName, "Accessors": accessors})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// turns a metric or label name (e.g. "execCount", "my_metric", "type") into a Go identifier - exported one or not
+func goIdentifier(name string, exported bool) string {
+	var id strings.Builder
+	upperNext := exported
+	for _, r := range name {
+		if r == '_' || r == ':' {
+			upperNext = id.Len() > 0 || exported
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		id.WriteRune(r)
+	}
+
+	result := id.String()
+	if !exported && (token.IsKeyword(result) || result == "prometheus") {
+		// would not compile or would shadow an import
+		result += "Label"
+	}
+	return result
+}
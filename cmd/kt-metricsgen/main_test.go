@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+// run "go test ./cmd/kt-metricsgen -update" after an intended change of the generated code - and review the diff of the golden file
+var updateGolden = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+func TestGenerateMatchesGolden(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "metrics_gen.go")
+	if err := run("testdata/metric_templates.yaml", false, "metrics", outPath); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("failed to read the generated file: %v", err)
+	}
+
+	goldenPath := "testdata/metrics_gen.go.golden"
+	if *updateGolden {
+		if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+			t.Fatalf("failed to update the golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read the golden file: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("the generated code differs from %v - if the change is intended run the test with -update\n--- got:\n%s", goldenPath, got)
+	}
+}
+
+func TestGenerateRejectsClashingNames(t *testing.T) {
+	cases := []struct {
+		name      string
+		templates []kt_observability_monitoring.MetricTemplateInfo
+		wantErr   string
+	}{
+		{
+			name: "same accessor name",
+			templates: []kt_observability_monitoring.MetricTemplateInfo{
+				{Name: "orderCount", MetricType: "counter"},
+				{Name: "order_count", MetricType: "counter"},
+			},
+			wantErr: "same accessor name OrderCount",
+		},
+		{
+			name: "same parameter name",
+			templates: []kt_observability_monitoring.MetricTemplateInfo{
+				{Name: "orderCount", MetricType: "counter", LabelNames: []string{"queueName", "queue_name"}},
+			},
+			wantErr: "same parameter name queueName",
+		},
+		{
+			name: "unknown type",
+			templates: []kt_observability_monitoring.MetricTemplateInfo{
+				{Name: "orderCount", MetricType: "meter"},
+			},
+			wantErr: "unknown type 'meter'",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := generate("metrics", c.templates)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
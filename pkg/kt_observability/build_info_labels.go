@@ -0,0 +1,83 @@
+package kt_observability
+
+import (
+	"runtime/debug"
+)
+
+// The labels the BuildInfoLabelProvider gives - besides "serviceVer"
+const (
+	// the Go version the binary was built with
+	GoVersionLabelName = "goVersion"
+	// the VCS revision (e.g. git commit hash) the binary was built from
+	CommitLabelName = "commit"
+	// the time of the VCS revision - RFC3339
+	CommitTimeLabelName = "commitTime"
+	// "true" if the binary was built with uncommitted changes, "false" otherwise
+	CommitModifiedLabelName = "commitModified"
+)
+
+// A LabelProvider taking the labels from the build info embedded into the binary by the Go toolchain:
+//   - the Go version as "goVersion"
+//   - the VCS info (if the binary was built with it - which is the default when building inside a git checkout) as "commit", "commitTime" and
+//     "commitModified"
+//   - the version of the main module as "serviceVer" - if it is not a real version (but "(devel)") then the short commit hash, with a "-dirty" suffix if
+//     there were uncommitted changes
+type BuildInfoLabelProvider struct {
+	readBuildInfo func() (*debug.BuildInfo, bool)
+}
+
+// Creates a provider using the given function to get the build info - pass in nil to use debug.ReadBuildInfo (you can pass in a fake one in tests)
+func NewBuildInfoLabelProvider(readBuildInfo func() (*debug.BuildInfo, bool)) *BuildInfoLabelProvider {
+	if readBuildInfo == nil {
+		readBuildInfo = debug.ReadBuildInfo
+	}
+	return &BuildInfoLabelProvider{readBuildInfo: readBuildInfo}
+}
+
+func (p *BuildInfoLabelProvider) Name() string {
+	return "buildinfo"
+}
+
+func (p *BuildInfoLabelProvider) Labels() (map[string]any, error) {
+	labels := make(map[string]any)
+	buildInfo, ok := p.readBuildInfo()
+	if !ok || buildInfo == nil {
+		return labels, nil
+	}
+
+	if buildInfo.GoVersion != "" {
+		labels[GoVersionLabelName] = buildInfo.GoVersion
+	}
+
+	var revision, modified string
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.time":
+			labels[CommitTimeLabelName] = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" {
+		labels[CommitLabelName] = revision
+		if modified != "" {
+			labels[CommitModifiedLabelName] = modified
+		}
+	}
+
+	if version := buildInfo.Main.Version; version != "" && version != "(devel)" {
+		labels["serviceVer"] = version
+	} else if revision != "" {
+		version = revision
+		if len(version) > 12 {
+			version = version[:12]
+		}
+		if modified == "true" {
+			version += "-dirty"
+		}
+		labels["serviceVer"] = version
+	}
+	return labels, nil
+}
@@ -0,0 +1,35 @@
+package kt_observability
+
+import (
+	"context"
+)
+
+type contextLabelsKey struct{}
+
+// Returns a child context carrying the given labels on top of the labels the parent context already had (the given ones override the parent's ones with
+// the same name). Use it for request-scoped labels - e.g. tenantId, requestId, route - so they flow through the call chain without passing maps around. Get
+// them back with LabelsFrom().
+func WithLabels(ctx context.Context, labels map[string]any) context.Context {
+	merged := LabelsFrom(ctx)
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, contextLabelsKey{}, merged)
+}
+
+// Returns the labels added to the context with WithLabels() - an empty map if there are none. You get a copy so feel free to modify it.
+func LabelsFrom(ctx context.Context) map[string]any {
+	labels, _ := ctx.Value(contextLabelsKey{}).(map[string]any)
+	copied := make(map[string]any, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Returns one label added to the context with WithLabels() - the bool is FALSE if there is no such label
+func LabelFrom(ctx context.Context, labelName string) (any, bool) {
+	labels, _ := ctx.Value(contextLabelsKey{}).(map[string]any)
+	value, found := labels[labelName]
+	return value, found
+}
@@ -0,0 +1,39 @@
+package kt_observability
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestContextLabels(t *testing.T) {
+	ctx := context.Background()
+	if labels := LabelsFrom(ctx); len(labels) != 0 {
+		t.Errorf("expected no labels in an empty context, got %v", labels)
+	}
+	if _, found := LabelFrom(ctx, "tenantId"); found {
+		t.Errorf("expected no tenantId in an empty context")
+	}
+
+	parent := WithLabels(ctx, map[string]any{"tenantId": "t1", "route": "/orders"})
+	child := WithLabels(parent, map[string]any{"route": "/orders/{id}", "requestId": "r1"})
+
+	if got, want := LabelsFrom(parent), map[string]any{"tenantId": "t1", "route": "/orders"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the parent must not see the labels of the child - expected %v, got %v", want, got)
+	}
+	if got, want := LabelsFrom(child), map[string]any{"tenantId": "t1", "route": "/orders/{id}", "requestId": "r1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the merged labels %v, got %v", want, got)
+	}
+	if value, found := LabelFrom(child, "route"); !found || value != "/orders/{id}" {
+		t.Errorf("expected the route of the child, got %v (found: %v)", value, found)
+	}
+	if _, found := LabelFrom(child, "missing"); found {
+		t.Errorf("expected no 'missing' label")
+	}
+
+	// we get a copy
+	LabelsFrom(child)["tenantId"] = "changed"
+	if value, _ := LabelFrom(child, "tenantId"); value != "t1" {
+		t.Errorf("modifying the returned map must not change the context, got %v", value)
+	}
+}
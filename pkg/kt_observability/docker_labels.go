@@ -0,0 +1,74 @@
+package kt_observability
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var containerIdRegex = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// A LabelProvider finding out the id of the (Docker, containerd, ...) container we are running in - from the cgroup info of the process. Provides it as
+// "containerId" (the short, 12 chars form - as "docker ps" shows it) and also as "instId" - as that identifies the instance of the service.
+type DockerLabelProvider struct {
+	fsys fs.FS
+}
+
+// Creates a provider reading "proc/self/cgroup" (cgroup v1) and "proc/self/mountinfo" (cgroup v2) from the given filesystem - which is the root filesystem.
+// Use os.DirFS("/") or NewDefaultDockerLabelProvider() in real life and a fake filesystem (e.g. fstest.MapFS) in tests.
+func NewDockerLabelProvider(fsys fs.FS) *DockerLabelProvider {
+	return &DockerLabelProvider{fsys: fsys}
+}
+
+// Creates a provider reading the real root filesystem
+func NewDefaultDockerLabelProvider() *DockerLabelProvider {
+	return NewDockerLabelProvider(os.DirFS("/"))
+}
+
+func (p *DockerLabelProvider) Name() string {
+	return "docker"
+}
+
+func (p *DockerLabelProvider) Labels() (map[string]any, error) {
+	labels := make(map[string]any)
+
+	// with cgroup v1 the id is in the cgroup path - e.g. "12:memory:/docker/<id>" or ".../docker-<id>.scope"
+	containerId, err := p.findContainerId("proc/self/cgroup", func(line string) bool { return true })
+	if err != nil {
+		return labels, err
+	}
+	if containerId == "" {
+		// with cgroup v2 the cgroup path is just "0::/" - but the id is there in the mounts of the container (e.g. /etc/hostname)
+		containerId, err = p.findContainerId("proc/self/mountinfo", func(line string) bool { return strings.Contains(line, "/containers/") })
+		if err != nil {
+			return labels, err
+		}
+	}
+
+	if containerId != "" {
+		labels["containerId"] = containerId[:12]
+		labels["instId"] = containerId[:12]
+	}
+	return labels, nil
+}
+
+// returns the first container id found in the lines of the file accepted by the filter - "" if there is none (or the file is not there)
+func (p *DockerLabelProvider) findContainerId(path string, filter func(line string) bool) (string, error) {
+	content, err := readOptionalFile(p.fsys, path)
+	if err != nil || content == "" {
+		return "", err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !filter(line) {
+			continue
+		}
+		if id := containerIdRegex.FindString(line); id != "" {
+			return id, nil
+		}
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,48 @@
+package kt_observability
+
+import (
+	"os"
+)
+
+// The env vars the global labels are taken from by default - label name -> env var names in order of preference
+var DefaultEnvLabelMapping = map[string][]string{
+	"serviceName": {"SERVICE_NAME", "CONTAINER_NAME"},
+	"serviceVer":  {"SERVICE_VERSION", "CONTAINER_VERSION"},
+	"host":        {"HOSTNAME"},
+	"instId":      {"INSTANCE_ID"},
+	"commit":      {"GIT_COMMIT", "COMMIT_SHA"},
+}
+
+// A LabelProvider taking the labels from env vars
+type EnvLabelProvider struct {
+	mapping   map[string][]string
+	lookupEnv func(key string) (string, bool)
+}
+
+// Creates a provider which takes the labels from env vars - the mapping is label name -> env var names in order of preference (the first non-empty wins).
+// Labels whose env vars are all missing or empty are not provided.
+//
+// The lookupEnv is how env vars are read - pass in nil to use os.LookupEnv (you can pass in a fake one in tests).
+func NewEnvLabelProvider(mapping map[string][]string, lookupEnv func(key string) (string, bool)) *EnvLabelProvider {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	return &EnvLabelProvider{mapping: mapping, lookupEnv: lookupEnv}
+}
+
+func (p *EnvLabelProvider) Name() string {
+	return "env"
+}
+
+func (p *EnvLabelProvider) Labels() (map[string]any, error) {
+	labels := make(map[string]any)
+	for labelName, envVarNames := range p.mapping {
+		for _, envVarName := range envVarNames {
+			if value, found := p.lookupEnv(envVarName); found && value != "" {
+				labels[labelName] = value
+				break
+			}
+		}
+	}
+	return labels, nil
+}
@@ -0,0 +1,127 @@
+package kt_observability
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Label names you can not use for global labels:
+//   - "metricType" is added by the MetricTemplates, "of" is a custom label of (almost) all predefined templates
+//   - "le" and "quantile" are used by Prometheus for histograms and summaries
+//   - "message", "level", "time" and "logger" are the keys of the log events written by kt_logging
+var ReservedLabelNames = []string{"metricType", "of", "le", "quantile", "message", "level", "time", "logger"}
+
+// the label name rule of Prometheus
+var labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Checks if the name can be used as a global label - so it is fine for Prometheus and also for kt_logging, and it is not one of the ReservedLabelNames
+func ValidateLabelName(name string) error {
+	if !labelNameRegex.MatchString(name) {
+		return fmt.Errorf("label name '%v' is invalid - it must match %v", name, labelNameRegex.String())
+	}
+	if strings.HasPrefix(name, "__") {
+		return fmt.Errorf("label name '%v' is invalid - names starting with '__' are reserved by Prometheus", name)
+	}
+	for _, reserved := range ReservedLabelNames {
+		if name == reserved {
+			return fmt.Errorf("label name '%v' is reserved", name)
+		}
+	}
+	return nil
+}
+
+// Checks the global labels - the names (see ValidateLabelName()) and the values (must be a string, number or bool). Returns all problems found joined into
+// one error, or nil if the labels are fine.
+func ValidateGlobalLabels(labels map[string]any) error {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := ValidateLabelName(name); err != nil {
+			errs = append(errs, err)
+		}
+		if labels[name] == nil {
+			errs = append(errs, fmt.Errorf("label '%v' has no value", name))
+		} else if _, ok := NormalizeLabelValue(labels[name]); !ok {
+			errs = append(errs, fmt.Errorf("label '%v' has a value of type %T - only string, number or bool is supported", name, labels[name]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reads global labels from a file - which must be either a JSON (.json), Yaml (.yaml / .yml) or a .env file (KEY=value lines). In JSON and Yaml the labels
+// are key-value pairs of the root object, e.g.
+//
+//	region: eu-west-1
+//	tier: 2
+//	canary: false
+//
+// The labels are validated (see ValidateGlobalLabels()) and the values are normalized (see NormalizeLabelValue()).
+func LoadGlobalLabels(labelsPath string) (map[string]any, error) {
+	byteValue, readErr := os.ReadFile(labelsPath)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read global labels! error was: %v", readErr)
+	}
+
+	labels := make(map[string]any)
+
+	// json or yaml or .env?
+	extension := path.Ext(strings.ToLower(labelsPath))
+	switch extension {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(byteValue, &labels); err != nil {
+			return nil, fmt.Errorf("failed to parse global labels! error was: %v", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(byteValue, &labels); err != nil {
+			return nil, fmt.Errorf("failed to parse global labels! error was: %v", err)
+		}
+	case ".env":
+		for key, value := range parseEnvFileContent(string(byteValue)) {
+			labels[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("unknown global labels file extension '%v'! Only .json, .yaml or .env is supported!", extension)
+	}
+
+	if err := ValidateGlobalLabels(labels); err != nil {
+		return nil, fmt.Errorf("invalid global labels in '%v': %w", labelsPath, err)
+	}
+	for key, value := range labels {
+		labels[key], _ = NormalizeLabelValue(value)
+	}
+	return labels, nil
+}
+
+// A LabelProvider taking the labels from a file - see LoadGlobalLabels()
+type FileLabelProvider struct {
+	labelsPath string
+}
+
+// Creates a provider reading the labels from the given file. If the file does not exist the provider gives no labels.
+func NewFileLabelProvider(labelsPath string) *FileLabelProvider {
+	return &FileLabelProvider{labelsPath: labelsPath}
+}
+
+func (p *FileLabelProvider) Name() string {
+	return "file"
+}
+
+func (p *FileLabelProvider) Labels() (map[string]any, error) {
+	if _, err := os.Stat(p.labelsPath); errors.Is(err, os.ErrNotExist) {
+		return map[string]any{}, nil
+	}
+	return LoadGlobalLabels(p.labelsPath)
+}
@@ -0,0 +1,231 @@
+package kt_observability
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Where the Kubernetes downward API volume is mounted by default
+const DefaultKubernetesDownwardApiPath = "/etc/podinfo"
+
+// The files we are reading from the downward API volume - file name -> label name. Mount them like this:
+//
+//	volumes:
+//	  - name: podinfo
+//	    downwardAPI:
+//	      items:
+//	        - path: "namespace"
+//	          fieldRef: { fieldPath: metadata.namespace }
+//	        - path: "podName"
+//	          fieldRef: { fieldPath: metadata.name }
+//	        - path: "nodeName"
+//	          fieldRef: { fieldPath: spec.nodeName }
+//	        - path: "labels"
+//	          fieldRef: { fieldPath: metadata.labels }
+//	        - path: "annotations"
+//	          fieldRef: { fieldPath: metadata.annotations }
+//
+// You can also put the owner (e.g. Deployment) name into an "ownerName" file if you know it - otherwise we try to figure it out from the pod name.
+var DefaultKubernetesDownwardApiFiles = map[string]string{
+	"namespace": "k8sNamespace",
+	"podName":   "k8sPod",
+	"nodeName":  "k8sNode",
+	"ownerName": "k8sOwner",
+}
+
+// If the downward API file of a label is not there we try these env vars - label name -> env var names in order of preference. Set them like this:
+//
+//	env:
+//	  - name: POD_NAMESPACE
+//	    valueFrom: { fieldRef: { fieldPath: metadata.namespace } }
+var DefaultKubernetesEnvVars = map[string][]string{
+	"k8sNamespace": {"POD_NAMESPACE", "K8S_NAMESPACE"},
+	"k8sPod":       {"POD_NAME", "K8S_POD_NAME"},
+	"k8sNode":      {"NODE_NAME", "K8S_NODE_NAME"},
+	"k8sOwner":     {"OWNER_NAME", "K8S_OWNER_NAME"},
+}
+
+// A LabelProvider taking the labels from Kubernetes downward API files or env vars - namespace, pod name, node name, owner (e.g. Deployment) name and the
+// allow-listed pod labels and annotations. If the pod name is known it is also provided as "instId" - as that identifies the instance of the service.
+type KubernetesLabelProvider struct {
+	fsys           fs.FS
+	files          map[string]string
+	envVars        map[string][]string
+	lookupEnv      func(key string) (string, bool)
+	podLabels      []string
+	podAnnotations []string
+}
+
+type KubernetesLabelProviderOpt func(*KubernetesLabelProvider)
+
+// The pod labels (keys) promoted to global labels - nothing by default. The name of the global label is built from the key, e.g.
+// "app.kubernetes.io/part-of" becomes "k8sLabelAppKubernetesIoPartOf". Needs the "labels" downward API file.
+func WithKubernetesPodLabels(keys ...string) KubernetesLabelProviderOpt {
+	return func(p *KubernetesLabelProvider) {
+		p.podLabels = keys
+	}
+}
+
+// The pod annotations (keys) promoted to global labels - nothing by default. The name of the global label is built from the key, e.g.
+// "example.com/team" becomes "k8sAnnotationExampleComTeam". Needs the "annotations" downward API file.
+func WithKubernetesPodAnnotations(keys ...string) KubernetesLabelProviderOpt {
+	return func(p *KubernetesLabelProvider) {
+		p.podAnnotations = keys
+	}
+}
+
+// How env vars are read - by default os.LookupEnv (you can pass in a fake one in tests)
+func WithKubernetesEnvLookup(lookupEnv func(key string) (string, bool)) KubernetesLabelProviderOpt {
+	return func(p *KubernetesLabelProvider) {
+		p.lookupEnv = lookupEnv
+	}
+}
+
+// Creates a provider reading the DefaultKubernetesDownwardApiFiles from the given filesystem - which is the downward API volume - with fallback to the
+// DefaultKubernetesEnvVars. Use os.DirFS(DefaultKubernetesDownwardApiPath) or NewDefaultKubernetesLabelProvider() in real life and a fake filesystem (e.g.
+// fstest.MapFS or os.DirFS() of a temp directory) in tests.
+func NewKubernetesLabelProvider(fsys fs.FS, opts ...KubernetesLabelProviderOpt) *KubernetesLabelProvider {
+	p := &KubernetesLabelProvider{
+		fsys:      fsys,
+		files:     DefaultKubernetesDownwardApiFiles,
+		envVars:   DefaultKubernetesEnvVars,
+		lookupEnv: os.LookupEnv,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Creates a provider reading the downward API volume mounted at DefaultKubernetesDownwardApiPath
+func NewDefaultKubernetesLabelProvider(opts ...KubernetesLabelProviderOpt) *KubernetesLabelProvider {
+	return NewKubernetesLabelProvider(os.DirFS(DefaultKubernetesDownwardApiPath), opts...)
+}
+
+// Returns TRUE if we are running in Kubernetes - based on the KUBERNETES_SERVICE_HOST env var which Kubernetes sets in every container
+func IsRunningInKubernetes() bool {
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+func (p *KubernetesLabelProvider) Name() string {
+	return "kubernetes"
+}
+
+func (p *KubernetesLabelProvider) Labels() (map[string]any, error) {
+	labels := make(map[string]any)
+	for fileName, labelName := range p.files {
+		value, err := readOptionalFile(p.fsys, fileName)
+		if err != nil {
+			return labels, err
+		}
+		if value != "" {
+			labels[labelName] = value
+		}
+	}
+	for labelName, envVarNames := range p.envVars {
+		if _, found := labels[labelName]; found {
+			continue
+		}
+		for _, envVarName := range envVarNames {
+			if value, found := p.lookupEnv(envVarName); found && value != "" {
+				labels[labelName] = value
+				break
+			}
+		}
+	}
+
+	podLabels, err := p.readKeyValueFile("labels")
+	if err != nil {
+		return labels, err
+	}
+	for _, key := range p.podLabels {
+		if value, found := podLabels[key]; found {
+			labels[promotedLabelName("k8sLabel", key)] = value
+		}
+	}
+	if len(p.podAnnotations) > 0 {
+		podAnnotations, err := p.readKeyValueFile("annotations")
+		if err != nil {
+			return labels, err
+		}
+		for _, key := range p.podAnnotations {
+			if value, found := podAnnotations[key]; found {
+				labels[promotedLabelName("k8sAnnotation", key)] = value
+			}
+		}
+	}
+
+	if podName, found := labels["k8sPod"]; found {
+		labels["instId"] = podName
+		if _, found := labels["k8sOwner"]; !found {
+			if owner := ownerNameOf(podName.(string), podLabels); owner != "" {
+				labels["k8sOwner"] = owner
+			}
+		}
+	}
+	return labels, nil
+}
+
+// reads a downward API labels / annotations file - lines like key="value"
+func (p *KubernetesLabelProvider) readKeyValueFile(fileName string) (map[string]string, error) {
+	values := make(map[string]string)
+	content, err := readOptionalFile(p.fsys, fileName)
+	if err != nil || content == "" {
+		return values, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, quotedValue, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		value, err := strconv.Unquote(quotedValue)
+		if err != nil {
+			value = quotedValue
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// figures out the owner name from the pod name - for Deployment pods (named "<deployment>-<pod-template-hash>-<random>") and StatefulSet pods (named
+// "<statefulset>-<ordinal>"). Returns "" if we can not tell.
+func ownerNameOf(podName string, podLabels map[string]string) string {
+	if hash := podLabels["pod-template-hash"]; hash != "" {
+		if i := strings.LastIndex(podName, "-"+hash+"-"); i > 0 {
+			return podName[:i]
+		}
+	}
+	if podLabels["statefulset.kubernetes.io/pod-name"] == podName {
+		if i := strings.LastIndex(podName, "-"); i > 0 {
+			if _, err := strconv.Atoi(podName[i+1:]); err == nil {
+				return podName[:i]
+			}
+		}
+	}
+	return ""
+}
+
+// builds a lowerCamelCase label name from a Kubernetes label / annotation key - e.g. "app.kubernetes.io/part-of" with prefix "k8sLabel" becomes
+// "k8sLabelAppKubernetesIoPartOf"
+func promotedLabelName(prefix string, key string) string {
+	var name strings.Builder
+	name.WriteString(prefix)
+	upperNext := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) || r > unicode.MaxASCII {
+			upperNext = true
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
@@ -0,0 +1,106 @@
+package kt_observability
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writes a fake downward API volume into a temp dir
+func downwardApiDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %v: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func noEnv(key string) (string, bool) {
+	return "", false
+}
+
+func TestKubernetesLabelProvider(t *testing.T) {
+	podLabelsFile := `app="my-app"
+app.kubernetes.io/part-of="shop"
+pod-template-hash="5d9f7c6b8"
+team="a \"quoted\" value"
+broken=not-quoted
+`
+	tests := []struct {
+		name    string
+		files   map[string]string
+		opts    []KubernetesLabelProviderOpt
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "downward API files - owner from the pod-template-hash",
+			files: map[string]string{
+				"namespace": "shop\n",
+				"podName":   "my-app-5d9f7c6b8-x7k2p",
+				"nodeName":  "node-1",
+				"labels":    podLabelsFile,
+			},
+			want: map[string]any{
+				"k8sNamespace": "shop", "k8sPod": "my-app-5d9f7c6b8-x7k2p", "k8sNode": "node-1", "k8sOwner": "my-app", "instId": "my-app-5d9f7c6b8-x7k2p",
+			},
+		},
+		{
+			name:  "only the allow-listed pod labels are promoted - quoting is removed",
+			files: map[string]string{"labels": podLabelsFile},
+			opts:  []KubernetesLabelProviderOpt{WithKubernetesPodLabels("app.kubernetes.io/part-of", "team", "broken", "missing")},
+			want:  map[string]any{"k8sLabelAppKubernetesIoPartOf": "shop", "k8sLabelTeam": `a "quoted" value`, "k8sLabelBroken": "not-quoted"},
+		},
+		{
+			name:  "allow-listed annotations",
+			files: map[string]string{"annotations": "example.com/team=\"payments\"\nother=\"x\"\n"},
+			opts:  []KubernetesLabelProviderOpt{WithKubernetesPodAnnotations("example.com/team")},
+			want:  map[string]any{"k8sAnnotationExampleComTeam": "payments"},
+		},
+		{
+			name: "owner from the StatefulSet pod name",
+			files: map[string]string{
+				"podName": "db-2",
+				"labels":  "statefulset.kubernetes.io/pod-name=\"db-2\"\n",
+			},
+			want: map[string]any{"k8sPod": "db-2", "k8sOwner": "db", "instId": "db-2"},
+		},
+		{
+			name:  "explicit owner file wins",
+			files: map[string]string{"podName": "my-app-5d9f7c6b8-x7k2p", "ownerName": "my-deployment", "labels": podLabelsFile},
+			want:  map[string]any{"k8sPod": "my-app-5d9f7c6b8-x7k2p", "k8sOwner": "my-deployment", "instId": "my-app-5d9f7c6b8-x7k2p"},
+		},
+		{
+			name:  "env vars are the fallback of the missing files",
+			files: map[string]string{"namespace": "shop"},
+			opts: []KubernetesLabelProviderOpt{WithKubernetesEnvLookup(func(key string) (string, bool) {
+				env := map[string]string{"POD_NAMESPACE": "ignored", "K8S_POD_NAME": "my-pod"}
+				value, found := env[key]
+				return value, found
+			})},
+			want: map[string]any{"k8sNamespace": "shop", "k8sPod": "my-pod", "instId": "my-pod"},
+		},
+		{
+			name:  "not running in Kubernetes",
+			files: map[string]string{},
+			want:  map[string]any{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append([]KubernetesLabelProviderOpt{WithKubernetesEnvLookup(noEnv)}, test.opts...)
+			provider := NewKubernetesLabelProvider(os.DirFS(downwardApiDir(t, test.files)), opts...)
+			labels, err := provider.Labels()
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %v, got: %v", test.wantErr, err)
+			}
+			if !reflect.DeepEqual(labels, test.want) {
+				t.Errorf("expected %v, got %v", test.want, labels)
+			}
+		})
+	}
+}
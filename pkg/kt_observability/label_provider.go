@@ -0,0 +1,139 @@
+package kt_observability
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// A LabelProvider contributes key-value pairs to the global labels - e.g. from env vars, from Kubernetes downward API files, from the Go build info etc.
+// Providers can be chained with NewLabelProviderChain().
+type LabelProvider interface {
+	// a short name of the provider - used in error messages
+	Name() string
+	// Returns the labels the provider knows about. If a source is simply not available (e.g. we are not running in Kubernetes) that is not an error - the
+	// provider just returns no labels. Errors are for sources which are there but could not be read or parsed.
+	Labels() (map[string]any, error)
+}
+
+// Decides which value wins if more providers in a chain are giving the same label
+type LabelMergePolicy int
+
+const (
+	// the provider earlier in the chain wins - this is the default
+	FirstProviderWins LabelMergePolicy = iota
+	// the provider later in the chain wins - so later providers can override earlier ones
+	LastProviderWins
+)
+
+// A LabelProvider which merges the labels of other providers - see NewLabelProviderChain()
+type LabelProviderChain struct {
+	providers   []LabelProvider
+	mergePolicy LabelMergePolicy
+}
+
+type LabelProviderChainOpt func(*LabelProviderChain)
+
+// Sets the merge policy of the chain - by default it is FirstProviderWins
+func WithMergePolicy(policy LabelMergePolicy) LabelProviderChainOpt {
+	return func(c *LabelProviderChain) {
+		c.mergePolicy = policy
+	}
+}
+
+// Creates a chain of the given providers. By default the provider earlier in the chain wins if more providers are giving the same label - you can change
+// this with WithMergePolicy().
+func NewLabelProviderChain(providers []LabelProvider, opts ...LabelProviderChainOpt) *LabelProviderChain {
+	chain := &LabelProviderChain{
+		providers:   providers,
+		mergePolicy: FirstProviderWins,
+	}
+	for _, opt := range opts {
+		opt(chain)
+	}
+	return chain
+}
+
+func (c *LabelProviderChain) Name() string {
+	return "chain"
+}
+
+// Returns the merged labels of the providers. If some providers fail the labels of the rest are still returned - together with the errors.
+func (c *LabelProviderChain) Labels() (map[string]any, error) {
+	labels := make(map[string]any)
+	var errs []error
+	for _, provider := range c.providers {
+		providedLabels, err := provider.Labels()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("label provider '%v' failed: %w", provider.Name(), err))
+		}
+		for key, value := range providedLabels {
+			if _, alreadyThere := labels[key]; alreadyThere && c.mergePolicy == FirstProviderWins {
+				continue
+			}
+			labels[key] = value
+		}
+	}
+	return labels, errors.Join(errs...)
+}
+
+// A LabelProvider always returning the same labels - useful for fallback values (put it at the end of a FirstProviderWins chain) or for labels you know
+// upfront
+type StaticLabelProvider struct {
+	labels map[string]any
+}
+
+func NewStaticLabelProvider(labels map[string]any) *StaticLabelProvider {
+	return &StaticLabelProvider{labels: labels}
+}
+
+func (p *StaticLabelProvider) Name() string {
+	return "static"
+}
+
+func (p *StaticLabelProvider) Labels() (map[string]any, error) {
+	labels := make(map[string]any, len(p.labels))
+	for key, value := range p.labels {
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// A LabelProvider giving only some of the labels of another provider - see NewFilteredLabelProvider()
+type FilteredLabelProvider struct {
+	provider   LabelProvider
+	labelNames []string
+}
+
+// Creates a provider which gives only the listed labels of the given provider - e.g. if you want the "serviceVer" of the build info but not the rest
+func NewFilteredLabelProvider(provider LabelProvider, labelNames ...string) *FilteredLabelProvider {
+	return &FilteredLabelProvider{provider: provider, labelNames: labelNames}
+}
+
+func (p *FilteredLabelProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *FilteredLabelProvider) Labels() (map[string]any, error) {
+	providedLabels, err := p.provider.Labels()
+	labels := make(map[string]any, len(p.labelNames))
+	for _, labelName := range p.labelNames {
+		if value, found := providedLabels[labelName]; found {
+			labels[labelName] = value
+		}
+	}
+	return labels, err
+}
+
+// reads a file and returns its trimmed content - a missing file is not an error, we just return ""
+func readOptionalFile(fsys fs.FS, path string) (string, error) {
+	content, err := fs.ReadFile(fsys, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,234 @@
+package kt_observability
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"testing"
+	"testing/fstest"
+)
+
+const testContainerId = "3f4e8a1b2c9d0e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+
+// a provider failing - but still giving some labels
+type failingLabelProvider struct {
+	labels map[string]any
+}
+
+func (p failingLabelProvider) Name() string {
+	return "failing"
+}
+
+func (p failingLabelProvider) Labels() (map[string]any, error) {
+	return p.labels, errors.New("broken")
+}
+
+func TestLabelProviders(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %v: %v", path, err)
+		}
+		return path
+	}
+	yamlPath := writeFile("labels.yaml", "region: eu-west-1\ntier: 2\ncanary: false\n")
+	envPath := writeFile("labels.env", "REGION=\"eu-west-1\"\n")
+	invalidPath := writeFile("invalid.yaml", "of: something\n")
+
+	tests := []struct {
+		name     string
+		provider LabelProvider
+		want     map[string]any
+		wantErr  bool
+	}{
+		{
+			name: "env - first non-empty env var wins",
+			provider: NewEnvLabelProvider(map[string][]string{"serviceName": {"SERVICE_NAME", "CONTAINER_NAME"}, "host": {"HOSTNAME"}},
+				func(key string) (string, bool) {
+					env := map[string]string{"SERVICE_NAME": "", "CONTAINER_NAME": "my-service"}
+					value, found := env[key]
+					return value, found
+				}),
+			want: map[string]any{"serviceName": "my-service"},
+		},
+		{
+			name: "docker - cgroup v1",
+			provider: NewDockerLabelProvider(fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte("12:memory:/docker/" + testContainerId + "\n0::/\n")},
+			}),
+			want: map[string]any{"containerId": testContainerId[:12], "instId": testContainerId[:12]},
+		},
+		{
+			name: "docker - cgroup v2",
+			provider: NewDockerLabelProvider(fstest.MapFS{
+				"proc/self/cgroup":    {Data: []byte("0::/\n")},
+				"proc/self/mountinfo": {Data: []byte("1 2 0:1 / / rw - overlay overlay rw\n3 1 8:1 /var/lib/docker/containers/" + testContainerId + "/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n")},
+			}),
+			want: map[string]any{"containerId": testContainerId[:12], "instId": testContainerId[:12]},
+		},
+		{
+			name:     "docker - not in a container",
+			provider: NewDockerLabelProvider(fstest.MapFS{"proc/self/cgroup": {Data: []byte("0::/\n")}}),
+			want:     map[string]any{},
+		},
+		{
+			name:     "docker - unreadable cgroup file",
+			provider: NewDockerLabelProvider(fstest.MapFS{"proc/self/cgroup/oops": {}}),
+			want:     map[string]any{},
+			wantErr:  true,
+		},
+		{
+			name: "os-release",
+			provider: NewOsReleaseLabelProvider(fstest.MapFS{
+				"etc/os-release": {Data: []byte("# comment\nNAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID='3.19.1'\n")},
+			}),
+			want: map[string]any{"osName": "alpine", "osVersion": "3.19.1"},
+		},
+		{
+			name: "os-release - fallback file",
+			provider: NewOsReleaseLabelProvider(fstest.MapFS{
+				"usr/lib/os-release": {Data: []byte("ID=debian\nVERSION_ID=\"12\"\n")},
+			}),
+			want: map[string]any{"osName": "debian", "osVersion": "12"},
+		},
+		{
+			name:     "os-release - missing",
+			provider: NewOsReleaseLabelProvider(fstest.MapFS{}),
+			want:     map[string]any{},
+		},
+		{
+			name: "build info - devel version with VCS info",
+			provider: NewBuildInfoLabelProvider(func() (*debug.BuildInfo, bool) {
+				return &debug.BuildInfo{
+					GoVersion: "go1.23.4",
+					Main:      debug.Module{Version: "(devel)"},
+					Settings: []debug.BuildSetting{
+						{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+						{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+						{Key: "vcs.modified", Value: "true"},
+					},
+				}, true
+			}),
+			want: map[string]any{
+				GoVersionLabelName: "go1.23.4", CommitLabelName: "0123456789abcdef0123", CommitTimeLabelName: "2024-01-02T03:04:05Z",
+				CommitModifiedLabelName: "true", "serviceVer": "0123456789ab-dirty",
+			},
+		},
+		{
+			name: "build info - released version",
+			provider: NewBuildInfoLabelProvider(func() (*debug.BuildInfo, bool) {
+				return &debug.BuildInfo{GoVersion: "go1.23.4", Main: debug.Module{Version: "v1.2.3"}}, true
+			}),
+			want: map[string]any{GoVersionLabelName: "go1.23.4", "serviceVer": "v1.2.3"},
+		},
+		{
+			name:     "build info - not available",
+			provider: NewBuildInfoLabelProvider(func() (*debug.BuildInfo, bool) { return nil, false }),
+			want:     map[string]any{},
+		},
+		{
+			name:     "file - yaml",
+			provider: NewFileLabelProvider(yamlPath),
+			want:     map[string]any{"region": "eu-west-1", "tier": int64(2), "canary": false},
+		},
+		{
+			name:     "file - env",
+			provider: NewFileLabelProvider(envPath),
+			want:     map[string]any{"REGION": "eu-west-1"},
+		},
+		{
+			name:     "file - missing",
+			provider: NewFileLabelProvider(filepath.Join(dir, "missing.yaml")),
+			want:     map[string]any{},
+		},
+		{
+			name:     "file - reserved label name",
+			provider: NewFileLabelProvider(invalidPath),
+			wantErr:  true,
+		},
+		{
+			name:     "static",
+			provider: NewStaticLabelProvider(map[string]any{"region": "eu"}),
+			want:     map[string]any{"region": "eu"},
+		},
+		{
+			name:     "filtered",
+			provider: NewFilteredLabelProvider(NewStaticLabelProvider(map[string]any{"region": "eu", "zone": "a"}), "zone", "missing"),
+			want:     map[string]any{"zone": "a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels, err := test.provider.Labels()
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %v, got: %v", test.wantErr, err)
+			}
+			if !reflect.DeepEqual(labels, test.want) {
+				t.Errorf("expected %v, got %v", test.want, labels)
+			}
+		})
+	}
+}
+
+func TestLabelProviderChainMergePolicies(t *testing.T) {
+	first := NewStaticLabelProvider(map[string]any{"serviceName": "first", "region": "eu"})
+	second := NewStaticLabelProvider(map[string]any{"serviceName": "second", "zone": "a"})
+	failing := failingLabelProvider{labels: map[string]any{"serviceName": "failing", "host": "h1"}}
+
+	tests := []struct {
+		name      string
+		providers []LabelProvider
+		opts      []LabelProviderChainOpt
+		want      map[string]any
+		wantErr   bool
+	}{
+		{
+			name:      "first provider wins by default",
+			providers: []LabelProvider{first, second},
+			want:      map[string]any{"serviceName": "first", "region": "eu", "zone": "a"},
+		},
+		{
+			name:      "first provider wins",
+			providers: []LabelProvider{first, second},
+			opts:      []LabelProviderChainOpt{WithMergePolicy(FirstProviderWins)},
+			want:      map[string]any{"serviceName": "first", "region": "eu", "zone": "a"},
+		},
+		{
+			name:      "last provider wins",
+			providers: []LabelProvider{first, second},
+			opts:      []LabelProviderChainOpt{WithMergePolicy(LastProviderWins)},
+			want:      map[string]any{"serviceName": "second", "region": "eu", "zone": "a"},
+		},
+		{
+			name:      "failing provider - first wins",
+			providers: []LabelProvider{first, failing},
+			want:      map[string]any{"serviceName": "first", "region": "eu", "host": "h1"},
+			wantErr:   true,
+		},
+		{
+			name:      "failing provider - last wins",
+			providers: []LabelProvider{first, failing},
+			opts:      []LabelProviderChainOpt{WithMergePolicy(LastProviderWins)},
+			want:      map[string]any{"serviceName": "failing", "region": "eu", "host": "h1"},
+			wantErr:   true,
+		},
+		{
+			name: "empty chain",
+			want: map[string]any{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels, err := NewLabelProviderChain(test.providers, test.opts...).Labels()
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %v, got: %v", test.wantErr, err)
+			}
+			if !reflect.DeepEqual(labels, test.want) {
+				t.Errorf("expected %v, got %v", test.want, labels)
+			}
+		})
+	}
+}
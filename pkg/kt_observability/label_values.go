@@ -0,0 +1,98 @@
+package kt_observability
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// The value of labels which are nil
+const NullLabelValue = "<null>"
+
+// Normalizes a label value so logs and metrics are showing the same - the result is a string, int64, float64 or bool:
+//   - time.Time is formatted as RFC3339, time.Duration like "1.5s", an error is its message and a fmt.Stringer is its String()
+//   - []byte is taken as a string
+//   - pointers are dereferenced - nil pointers, slices and maps (and nil) are NullLabelValue
+//   - named types (e.g. `type MyInt int`) are normalized by their kind
+//
+// The bool is FALSE if the type of the value is not supported (e.g. structs, slices, maps) - in this case you get back a string telling this.
+func NormalizeLabelValue(value any) (any, bool) {
+	if value == nil {
+		return NullLabelValue, true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return NullLabelValue, true
+		}
+	}
+	// a *time.Time is a fmt.Stringer too - but it must look the same as a time.Time
+	if v.Kind() == reflect.Pointer {
+		switch elem := v.Elem().Interface().(type) {
+		case time.Time, time.Duration:
+			return NormalizeLabelValue(elem)
+		}
+	}
+	switch typed := value.(type) {
+	case time.Time:
+		return typed.Format(time.RFC3339), true
+	case time.Duration:
+		return typed.String(), true
+	case error:
+		return typed.Error(), true
+	case fmt.Stringer:
+		return typed.String(), true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		return NormalizeLabelValue(v.Elem().Interface())
+	case reflect.String:
+		return v.String(), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), true
+		}
+		return fmt.Sprintf("<'%v' value not supported>", v.Kind()), false
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		} else {
+			return float64(u), true
+		}
+	case reflect.Float32:
+		// going through the shortest string form - so float32(0.1) stays 0.1 and does not become 0.10000000149011612
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(v.Float(), 'g', -1, 32), 64)
+		return f, true
+	case reflect.Float64:
+		return v.Float(), true
+	default:
+		return fmt.Sprintf("<'%v' value not supported>", v.Kind()), false
+	}
+}
+
+// Returns the string form of a label value - this is how the value appears in metric labels. Numbers are formatted the same way as they appear in (JSON)
+// logs, e.g. 1000000.0 is "1000000" and not "1e+06".
+func LabelValueString(value any) string {
+	normalized, _ := NormalizeLabelValue(value)
+	switch v := normalized.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
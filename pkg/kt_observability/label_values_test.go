@@ -0,0 +1,49 @@
+package kt_observability
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type myInt int
+
+func TestNormalizeLabelValue(t *testing.T) {
+	someTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	someDuration := 1500 * time.Millisecond
+	someString := "text"
+	var nilTime *time.Time
+
+	tests := []struct {
+		name          string
+		value         any
+		want          any
+		wantSupported bool
+	}{
+		{name: "nil", value: nil, want: NullLabelValue, wantSupported: true},
+		{name: "string", value: "text", want: "text", wantSupported: true},
+		{name: "named int", value: myInt(5), want: int64(5), wantSupported: true},
+		{name: "float32", value: float32(0.1), want: 0.1, wantSupported: true},
+		{name: "time", value: someTime, want: "2024-01-02T03:04:05Z", wantSupported: true},
+		{name: "pointer to time", value: &someTime, want: "2024-01-02T03:04:05Z", wantSupported: true},
+		{name: "nil pointer to time", value: nilTime, want: NullLabelValue, wantSupported: true},
+		{name: "duration", value: someDuration, want: "1.5s", wantSupported: true},
+		{name: "pointer to duration", value: &someDuration, want: "1.5s", wantSupported: true},
+		{name: "pointer to string", value: &someString, want: "text", wantSupported: true},
+		{name: "error", value: errors.New("oops"), want: "oops", wantSupported: true},
+		{name: "stringer", value: net.IPv4(10, 0, 0, 1), want: "10.0.0.1", wantSupported: true},
+		{name: "bytes", value: []byte("raw"), want: "raw", wantSupported: true},
+		{name: "nil bytes", value: []byte(nil), want: NullLabelValue, wantSupported: true},
+		{name: "slice", value: []string{"a"}, want: "<'slice' value not supported>", wantSupported: false},
+		{name: "struct", value: struct{}{}, want: "<'struct' value not supported>", wantSupported: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, supported := NormalizeLabelValue(test.value)
+			if got != test.want || supported != test.wantSupported {
+				t.Errorf("expected %v (%T) / %v, got %v (%T) / %v", test.want, test.want, test.wantSupported, got, got, supported)
+			}
+		})
+	}
+}
@@ -1,42 +1,64 @@
 package kt_observability
 
 import (
-	"os"
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
 )
 
-// Builds the default key-value pairs due to our Logging / Monitoring standards (TBD!!!)
-func BuildGlobalLabelsMap() map[string]any {
-	globalLabels := make(map[string]any)
+// The names of the global labels BuildGlobalLabelsMap() builds - tools generating dashboards, alerts etc. can rely on these being present
+var StandardGlobalLabelNames = []string{"serviceName", "serviceVer", "host", "instId"}
 
-	serviceName := os.Getenv("SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = os.Getenv("CONTAINER_NAME")
-	}
-	if serviceName == "" {
-		serviceName = "?"
-	}
-	globalLabels["serviceName"] = serviceName
+// The value of the standard global labels if none of the providers knows them
+const UnknownLabelValue = "?"
 
-	serviceVer := os.Getenv("SERVICE_VERSION")
-	if serviceVer == "" {
-		serviceVer = os.Getenv("CONTAINER_VERSION")
-	}
-	if serviceVer == "" {
-		serviceVer = "?"
-	}
-	globalLabels["serviceVer"] = serviceVer
+// The provider BuildGlobalLabelsMap() is using. By default this is NewDefaultGlobalLabelProvider() but you can replace it with your own chain - e.g.
+//
+//	kt_observability.GlobalLabelProvider = kt_observability.NewLabelProviderChain([]kt_observability.LabelProvider{
+//		kt_observability.NewEnvLabelProvider(kt_observability.DefaultEnvLabelMapping, nil),
+//		kt_observability.NewDefaultKubernetesLabelProvider(),
+//		kt_observability.NewDefaultDockerLabelProvider(),
+//		kt_observability.NewBuildInfoLabelProvider(nil),
+//		kt_observability.NewStaticLabelProvider(kt_observability.UnknownStandardGlobalLabels()),
+//	})
+//
+// Please note: you have to do this before the global labels are built - so before kt_observability_monitoring.InitMetrics() for example.
+var GlobalLabelProvider LabelProvider = NewDefaultGlobalLabelProvider()
 
-	host := os.Getenv("HOSTNAME")
-	if host == "" {
-		host = "?"
+// Creates the default global label provider - the standard global labels are taken from env vars (see DefaultEnvLabelMapping). If we are running in
+// Kubernetes (see IsRunningInKubernetes()) then the Kubernetes metadata is added too - see NewDefaultKubernetesLabelProvider(), the given options are
+// passed to it (e.g. WithKubernetesPodLabels() to promote some pod labels). If the env vars do not tell the "serviceVer" and "commit" then these are taken
+// from the Go build info - see BuildInfoLabelProvider. Standard global labels nobody knows are UnknownLabelValue.
+func NewDefaultGlobalLabelProvider(kubernetesOpts ...KubernetesLabelProviderOpt) *LabelProviderChain {
+	providers := []LabelProvider{NewEnvLabelProvider(DefaultEnvLabelMapping, nil)}
+	if IsRunningInKubernetes() {
+		providers = append(providers, NewDefaultKubernetesLabelProvider(kubernetesOpts...))
 	}
-	globalLabels["host"] = host
+	providers = append(providers,
+		NewFilteredLabelProvider(NewBuildInfoLabelProvider(nil), "serviceVer", CommitLabelName),
+		NewStaticLabelProvider(UnknownStandardGlobalLabels()),
+	)
+	return NewLabelProviderChain(providers)
+}
 
-	instId := os.Getenv("INSTANCE_ID")
-	if instId == "" {
-		instId = "?"
+// Returns all the StandardGlobalLabelNames with UnknownLabelValue - use it as the last provider of a chain so the standard labels are always there
+func UnknownStandardGlobalLabels() map[string]any {
+	labels := make(map[string]any, len(StandardGlobalLabelNames))
+	for _, labelName := range StandardGlobalLabelNames {
+		labels[labelName] = UnknownLabelValue
 	}
-	globalLabels["instId"] = instId
+	return labels
+}
 
+// Builds the default key-value pairs due to our Logging / Monitoring standards - using the GlobalLabelProvider. If some providers fail that is logged but the
+// labels of the rest are still used.
+func BuildGlobalLabelsMap() map[string]any {
+	return BuildGlobalLabelsMapFrom(GlobalLabelProvider)
+}
+
+// Same as BuildGlobalLabelsMap() but using the given provider instead of the GlobalLabelProvider
+func BuildGlobalLabelsMapFrom(provider LabelProvider) map[string]any {
+	globalLabels, err := provider.Labels()
+	if err != nil {
+		kt_logging.GetLogger("keytiles.observability.LabelProvider").Warn("some of the global labels could not be built - error: %v", err)
+	}
 	return globalLabels
 }
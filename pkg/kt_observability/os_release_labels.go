@@ -0,0 +1,73 @@
+package kt_observability
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// A LabelProvider taking the operating system (or base image) name and version from the os-release file - as "osName" (e.g. "alpine") and "osVersion"
+// (e.g. "3.19.1")
+type OsReleaseLabelProvider struct {
+	fsys fs.FS
+}
+
+// Creates a provider reading "etc/os-release" (or "usr/lib/os-release" as a fallback) from the given filesystem - which is the root filesystem. Use
+// os.DirFS("/") or NewDefaultOsReleaseLabelProvider() in real life and a fake filesystem (e.g. fstest.MapFS) in tests.
+func NewOsReleaseLabelProvider(fsys fs.FS) *OsReleaseLabelProvider {
+	return &OsReleaseLabelProvider{fsys: fsys}
+}
+
+// Creates a provider reading the real root filesystem
+func NewDefaultOsReleaseLabelProvider() *OsReleaseLabelProvider {
+	return NewOsReleaseLabelProvider(os.DirFS("/"))
+}
+
+func (p *OsReleaseLabelProvider) Name() string {
+	return "os-release"
+}
+
+func (p *OsReleaseLabelProvider) Labels() (map[string]any, error) {
+	labels := make(map[string]any)
+
+	content, err := readOptionalFile(p.fsys, "etc/os-release")
+	if err == nil && content == "" {
+		content, err = readOptionalFile(p.fsys, "usr/lib/os-release")
+	}
+	if err != nil {
+		return labels, err
+	}
+
+	values := parseEnvFileContent(content)
+	if id := values["ID"]; id != "" {
+		labels["osName"] = id
+	}
+	if versionId := values["VERSION_ID"]; versionId != "" {
+		labels["osVersion"] = versionId
+	}
+	return labels, nil
+}
+
+// parses KEY=value lines (like in os-release or .env files) - comments and empty lines are skipped, quotes around the value are removed
+func parseEnvFileContent(content string) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' && value[len(value)-1] == '"' || value[0] == '\'' && value[len(value)-1] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values
+}
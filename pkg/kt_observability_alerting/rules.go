@@ -0,0 +1,306 @@
+// Generates Prometheus recording and alerting rules for the metric templates - so every service following the standards gets baseline alerting for free.
+package kt_observability_alerting
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+	"gopkg.in/yaml.v2"
+)
+
+// The thresholds and other settings of the generated alerts - see DefaultThresholds
+type Thresholds struct {
+	// alert if errorCount / execCount is above this (0..1)
+	ErrorRatio float64
+	// alert if clientReqFailedCount / clientReqSentCount is above this (0..1)
+	ClientFailureRatio float64
+	// alert if serverServeFailedCount / serverServeStartedCount is above this (0..1)
+	ServerFailureRatio float64
+	// alert if the given quantile of clientReqProcessingTime is above this
+	ClientLatencyMillis float64
+	// alert if the given quantile of serverServeProcessingTime is above this
+	ServerLatencyMillis float64
+	// the quantile the latency alerts are watching - must be one of the generated quantiles and summary templates must report it (be one of their objectives)
+	LatencyQuantile float64
+	// how long the condition must hold before the alert fires - e.g. "5m"
+	For string
+	// the value of the "severity" label of the alerts
+	Severity string
+}
+
+// The thresholds used if you do not pass in your own with WithThresholds()
+var DefaultThresholds = Thresholds{
+	ErrorRatio:          0.05,
+	ClientFailureRatio:  0.05,
+	ServerFailureRatio:  0.05,
+	ClientLatencyMillis: 1000,
+	ServerLatencyMillis: 1000,
+	LatencyQuantile:     0.99,
+	For:                 "5m",
+	Severity:            "warning",
+}
+
+// The quantiles latency recording rules are generated for
+var DefaultQuantiles = []float64{0.5, 0.95, 0.99}
+
+// The Prometheus rule file model - only the parts we are using
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// A recording rule (Record is set) or an alerting rule (Alert is set)
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Renders the rules as Yaml you can put into the rule_files of Prometheus
+func (f RuleFile) Yaml() ([]byte, error) {
+	return yaml.Marshal(f)
+}
+
+type rulesBuilder struct {
+	thresholds  Thresholds
+	rateWindow  string
+	quantiles   []float64
+	serviceName string
+}
+
+type RulesOpt func(*rulesBuilder)
+
+// The thresholds of the alerts - by default DefaultThresholds
+func WithThresholds(thresholds Thresholds) RulesOpt {
+	return func(b *rulesBuilder) {
+		b.thresholds = thresholds
+	}
+}
+
+// The range the rates are calculated over - by default "5m"
+func WithRateWindow(window string) RulesOpt {
+	return func(b *rulesBuilder) {
+		b.rateWindow = window
+	}
+}
+
+// The quantiles latency recording rules are generated for - by default DefaultQuantiles
+func WithQuantiles(quantiles ...float64) RulesOpt {
+	return func(b *rulesBuilder) {
+		b.quantiles = quantiles
+	}
+}
+
+// Generates the rules for one service only - the rules are filtered by the "serviceName" label and the groups are named after the service. By default the
+// rules cover all services.
+func WithServiceName(serviceName string) RulesOpt {
+	return func(b *rulesBuilder) {
+		b.serviceName = serviceName
+	}
+}
+
+// a ratio of two counter templates we record and alert on
+type ratioRule struct {
+	name        string
+	numerator   string
+	denominator string
+	alert       string
+	threshold   func(Thresholds) float64
+	description string
+}
+
+var ratioRules = []ratioRule{
+	{
+		name: "errorRatio", numerator: "errorCount", denominator: "execCount",
+		alert: "KtHighErrorRatio", threshold: func(t Thresholds) float64 { return t.ErrorRatio },
+		description: "errors / executions",
+	},
+	{
+		name: "clientReqFailureRatio", numerator: "clientReqFailedCount", denominator: "clientReqSentCount",
+		alert: "KtHighClientRequestFailureRatio", threshold: func(t Thresholds) float64 { return t.ClientFailureRatio },
+		description: "failed / sent client requests",
+	},
+	{
+		name: "serverServeFailureRatio", numerator: "serverServeFailedCount", denominator: "serverServeStartedCount",
+		alert: "KtHighServerServeFailureRatio", threshold: func(t Thresholds) float64 { return t.ServerFailureRatio },
+		description: "failed / started served requests",
+	},
+}
+
+// the latency templates we alert on
+type latencyAlert struct {
+	template    string
+	alert       string
+	threshold   func(Thresholds) float64
+	description string
+}
+
+var latencyAlerts = []latencyAlert{
+	{
+		template: "clientReqProcessingTime", alert: "KtHighClientRequestLatency",
+		threshold: func(t Thresholds) float64 { return t.ClientLatencyMillis }, description: "client request time",
+	},
+	{
+		template: "serverServeProcessingTime", alert: "KtHighServerServeLatency",
+		threshold: func(t Thresholds) float64 { return t.ServerLatencyMillis }, description: "serving time",
+	},
+}
+
+// Builds the recording and alerting rules for the given templates - e.g. the predefined ones you get from
+// kt_observability_monitoring.RegisteredMetricTemplateInfos() plus the ones in a definitions file.
+//
+// You get:
+//   - ratio recording rules and alerts per "of" for errorCount / execCount, clientReqFailedCount / clientReqSentCount and serverServeFailedCount /
+//     serverServeStartedCount - if both templates are present
+//   - latency quantile recording rules per "of" for every summary and histogram template which has "Time" in its name (our time values are in millis) - summaries
+//     get rules only for the quantiles they report
+//   - latency alerts for clientReqProcessingTime and serverServeProcessingTime
+//
+// Everything is aggregated by "serviceName" and "of" so the alerts tell you which service and which "of" is in trouble.
+//
+// You get an error if the alerts could not work - e.g. the LatencyQuantile is not one of the generated quantiles or a summary we alert on does not report it.
+func BuildRules(templates []kt_observability_monitoring.MetricTemplateInfo, opts ...RulesOpt) (RuleFile, error) {
+	b := &rulesBuilder{
+		thresholds: DefaultThresholds,
+		rateWindow: "5m",
+		quantiles:  DefaultQuantiles,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	templatesByName := make(map[string]kt_observability_monitoring.MetricTemplateInfo, len(templates))
+	for _, tpl := range templates {
+		templatesByName[tpl.Name] = tpl
+	}
+
+	recording := RuleGroup{Name: b.groupName("recording"), Rules: []Rule{}}
+	alerting := RuleGroup{Name: b.groupName("alerts"), Rules: []Rule{}}
+
+	for _, ratio := range ratioRules {
+		_, hasNumerator := templatesByName[ratio.numerator]
+		_, hasDenominator := templatesByName[ratio.denominator]
+		if !hasNumerator || !hasDenominator {
+			continue
+		}
+		record := b.recordName(ratio.name + ":rate" + b.rateWindow)
+		recording.Rules = append(recording.Rules, Rule{
+			Record: record,
+			Expr:   fmt.Sprintf("%v\n/\n%v", b.sumRate(ratio.numerator), b.sumRate(ratio.denominator)),
+		})
+		alerting.Rules = append(alerting.Rules, b.alertRule(ratio.alert, record, ratio.threshold(b.thresholds), ratio.description,
+			"{{ $labels.serviceName }} / {{ $labels.of }}: ratio of "+ratio.description+" is {{ $value | humanizePercentage }}"))
+	}
+
+	for _, tpl := range templates {
+		if (tpl.MetricType != "summary" && tpl.MetricType != "histogram") || !strings.Contains(tpl.Name, "Time") {
+			continue
+		}
+		for _, quantile := range b.quantiles {
+			if tpl.MetricType == "summary" && !slices.Contains(tpl.Quantiles, quantile) {
+				continue
+			}
+			expr, err := b.quantileExpr(tpl, quantile)
+			if err != nil {
+				return RuleFile{}, err
+			}
+			recording.Rules = append(recording.Rules, Rule{
+				Record: b.recordName(tpl.Name + ":" + quantileName(quantile)),
+				Expr:   expr,
+			})
+		}
+	}
+
+	for _, latency := range latencyAlerts {
+		tpl, found := templatesByName[latency.template]
+		if !found {
+			continue
+		}
+		// the alert is watching the recorded quantile - so it must be there
+		if !slices.Contains(b.quantiles, b.thresholds.LatencyQuantile) {
+			return RuleFile{}, fmt.Errorf("latency quantile %v is not one of the generated quantiles %v", b.thresholds.LatencyQuantile, b.quantiles)
+		}
+		if _, err := b.quantileExpr(tpl, b.thresholds.LatencyQuantile); err != nil {
+			return RuleFile{}, err
+		}
+		record := b.recordName(latency.template + ":" + quantileName(b.thresholds.LatencyQuantile))
+		alerting.Rules = append(alerting.Rules, b.alertRule(latency.alert, record, latency.threshold(b.thresholds), latency.description,
+			"{{ $labels.serviceName }} / {{ $labels.of }}: "+quantileName(b.thresholds.LatencyQuantile)+" of "+latency.description+" is {{ $value }} ms"))
+	}
+
+	return RuleFile{Groups: []RuleGroup{recording, alerting}}, nil
+}
+
+func (b *rulesBuilder) groupName(kind string) string {
+	if b.serviceName != "" {
+		return b.serviceName + "." + kind
+	}
+	return "keytiles-standards." + kind
+}
+
+// following the level:metric:operations naming convention of Prometheus
+func (b *rulesBuilder) recordName(metricAndOperations string) string {
+	return "serviceName_of:" + metricAndOperations
+}
+
+func (b *rulesBuilder) selector(extra ...string) string {
+	var matchers []string
+	if b.serviceName != "" {
+		matchers = append(matchers, fmt.Sprintf("serviceName=%q", b.serviceName))
+	}
+	matchers = append(matchers, extra...)
+	if len(matchers) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+func (b *rulesBuilder) sumRate(metricName string) string {
+	return fmt.Sprintf("sum by (serviceName, of) (rate(%v%v[%v]))", metricName, b.selector(), b.rateWindow)
+}
+
+// returns error if the template can not give us the quantile - summaries report only their objectives
+func (b *rulesBuilder) quantileExpr(tpl kt_observability_monitoring.MetricTemplateInfo, quantile float64) (string, error) {
+	if quantile < 0 || quantile > 1 {
+		return "", fmt.Errorf("quantile %v of template '%v' is out of range - it must be between 0 and 1", quantile, tpl.Name)
+	}
+	q := strconv.FormatFloat(quantile, 'f', -1, 64)
+	if tpl.MetricType == "histogram" {
+		if len(tpl.Buckets) == 0 {
+			return "", fmt.Errorf("histogram template '%v' has no buckets - can not calculate quantile %v", tpl.Name, q)
+		}
+		return fmt.Sprintf("histogram_quantile(%v, sum by (serviceName, of, le) (rate(%v_bucket%v[%v])))", q, tpl.Name, b.selector(), b.rateWindow), nil
+	}
+	if !slices.Contains(tpl.Quantiles, quantile) {
+		return "", fmt.Errorf("summary template '%v' does not report quantile %v - its objectives are %v", tpl.Name, q, tpl.Quantiles)
+	}
+	return fmt.Sprintf("max by (serviceName, of) (%v%v)", tpl.Name, b.selector(fmt.Sprintf("quantile=%q", q))), nil
+}
+
+func (b *rulesBuilder) alertRule(alert string, record string, threshold float64, description string, detail string) Rule {
+	return Rule{
+		Alert:  alert,
+		Expr:   fmt.Sprintf("%v > %v", record, strconv.FormatFloat(threshold, 'f', -1, 64)),
+		For:    b.thresholds.For,
+		Labels: map[string]string{"severity": b.thresholds.Severity},
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("%v is above %v", description, strconv.FormatFloat(threshold, 'f', -1, 64)),
+			"description": detail,
+		},
+	}
+}
+
+// e.g. 0.99 is "p99", 0.5 is "p50", 0.999 is "p99_9"
+func quantileName(quantile float64) string {
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(quantile*100, 'f', -1, 64), ".", "_")
+}
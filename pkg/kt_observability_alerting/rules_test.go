@@ -0,0 +1,73 @@
+package kt_observability_alerting
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+// the rules of the integration test application are checked in - they must be the same what kt-alertgen generates, see tests/integration_tests
+func TestRulesMatchGoldenFile(t *testing.T) {
+	kt_observability_monitoring.InitMetrics()
+	templates := kt_observability_monitoring.RegisteredMetricTemplateInfos()
+	defs, err := kt_observability_monitoring.ParseMetricTemplateDefinitions("../../tests/integration_tests/metric_templates.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse the template definitions: %v", err)
+	}
+	templates = append(templates, defs.Infos()...)
+
+	ruleFile, err := BuildRules(templates)
+	if err != nil {
+		t.Fatalf("failed to build the rules: %v", err)
+	}
+	rules, err := ruleFile.Yaml()
+	if err != nil {
+		t.Fatalf("failed to render the rules: %v", err)
+	}
+	golden, err := os.ReadFile("../../tests/integration_tests/prometheus_rules.yaml")
+	if err != nil {
+		t.Fatalf("failed to read the golden file: %v", err)
+	}
+	if !bytes.Equal(rules, golden) {
+		t.Errorf("the generated rules differ from tests/integration_tests/prometheus_rules.yaml - regenerate them with kt-alertgen if the change is intended")
+	}
+}
+
+func TestLatencyQuantileIsValidated(t *testing.T) {
+	summary := kt_observability_monitoring.MetricTemplateInfo{Name: "serverServeProcessingTime", MetricType: "summary", LabelNames: []string{"of"},
+		Quantiles: []float64{0.5, 0.99}}
+	histogram := kt_observability_monitoring.MetricTemplateInfo{Name: "serverServeProcessingTime", MetricType: "histogram", LabelNames: []string{"of"},
+		Buckets: []float64{100, 1000}}
+
+	tests := []struct {
+		name      string
+		template  kt_observability_monitoring.MetricTemplateInfo
+		quantile  float64
+		quantiles []float64
+		wantErr   bool
+	}{
+		{name: "summary reporting the quantile", template: summary, quantile: 0.99},
+		{name: "summary not reporting the quantile", template: summary, quantile: 0.95, wantErr: true},
+		{name: "quantile not generated", template: summary, quantile: 0.5, quantiles: []float64{0.99}, wantErr: true},
+		{name: "histogram", template: histogram, quantile: 0.95},
+		{name: "histogram out of range quantile", template: histogram, quantile: 95, quantiles: []float64{95}, wantErr: true},
+		{name: "histogram without buckets", template: kt_observability_monitoring.MetricTemplateInfo{Name: "serverServeProcessingTime", MetricType: "histogram"},
+			quantile: 0.95, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thresholds := DefaultThresholds
+			thresholds.LatencyQuantile = test.quantile
+			opts := []RulesOpt{WithThresholds(thresholds)}
+			if test.quantiles != nil {
+				opts = append(opts, WithQuantiles(test.quantiles...))
+			}
+			_, err := BuildRules([]kt_observability_monitoring.MetricTemplateInfo{test.template}, opts...)
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error: %v, got: %v", test.wantErr, err)
+			}
+		})
+	}
+}
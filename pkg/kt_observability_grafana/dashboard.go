@@ -0,0 +1,400 @@
+// Generates Grafana dashboards from metric templates - so every service following the standards gets the same, consistent dashboards without hand editing
+// JSON files.
+package kt_observability_grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+const (
+	// the width of a panel - 2 panels fit in a row
+	panelWidth = 12
+	// the height of a panel
+	panelHeight = 8
+
+	datasourceVariable = "datasource"
+)
+
+// The quantiles the latency (summary, histogram) panels are showing
+var DefaultQuantiles = []string{"0.5", "0.95", "0.99"}
+
+// The Grafana dashboard model - only the parts we are using
+type Dashboard struct {
+	Uid           string     `json:"uid,omitempty"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// A template variable of the dashboard
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label,omitempty"`
+	Type       string      `json:"type"`
+	Query      any         `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	Multi      bool        `json:"multi"`
+	IncludeAll bool        `json:"includeAll"`
+	AllValue   string      `json:"allValue,omitempty"`
+	Sort       int         `json:"sort,omitempty"`
+}
+
+type Datasource struct {
+	Type string `json:"type"`
+	Uid  string `json:"uid"`
+}
+
+type Panel struct {
+	Id          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Datasource  *Datasource  `json:"datasource,omitempty"`
+	GridPos     GridPos      `json:"gridPos"`
+	FieldConfig *FieldConfig `json:"fieldConfig,omitempty"`
+	Targets     []Target     `json:"targets,omitempty"`
+	Collapsed   bool         `json:"collapsed,omitempty"`
+}
+
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+type Target struct {
+	RefId        string      `json:"refId"`
+	Expr         string      `json:"expr"`
+	LegendFormat string      `json:"legendFormat,omitempty"`
+	Datasource   *Datasource `json:"datasource,omitempty"`
+}
+
+// Renders the dashboard as (indented) JSON you can import into Grafana
+func (d Dashboard) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+type dashboardBuilder struct {
+	title            string
+	uid              string
+	tags             []string
+	globalLabelNames []string
+	quantiles        []string
+}
+
+type DashboardOpt func(*dashboardBuilder)
+
+// The uid of the dashboard - set it if you want to overwrite the same dashboard on import
+func WithUid(uid string) DashboardOpt {
+	return func(b *dashboardBuilder) {
+		b.uid = uid
+	}
+}
+
+// The tags of the dashboard - by default "keytiles" and "generated"
+func WithTags(tags ...string) DashboardOpt {
+	return func(b *dashboardBuilder) {
+		b.tags = tags
+	}
+}
+
+// The global labels to create template variables for - by default kt_observability.StandardGlobalLabelNames
+func WithGlobalLabelNames(labelNames ...string) DashboardOpt {
+	return func(b *dashboardBuilder) {
+		b.globalLabelNames = labelNames
+	}
+}
+
+// The quantiles latency panels are showing - by default DefaultQuantiles
+func WithQuantiles(quantiles ...string) DashboardOpt {
+	return func(b *dashboardBuilder) {
+		b.quantiles = quantiles
+	}
+}
+
+// Builds a dashboard with standard panels for the given templates - e.g. the predefined ones you get from
+// kt_observability_monitoring.RegisteredMetricTemplateInfos() or the ones in a definitions file.
+//
+// The dashboard gets template variables for the datasource, the global labels and "of". Every template gets one panel: counters are shown as rate, gauges
+// per instance, summaries and histograms as quantiles. Panels are grouped into rows by the prefix of the template name (e.g. "client", "circuitBreaker").
+func BuildDashboard(title string, templates []kt_observability_monitoring.MetricTemplateInfo, opts ...DashboardOpt) Dashboard {
+	b := &dashboardBuilder{
+		title:            title,
+		tags:             []string{"keytiles", "generated"},
+		globalLabelNames: kt_observability.StandardGlobalLabelNames,
+		quantiles:        DefaultQuantiles,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	dashboard := Dashboard{
+		Uid:           b.uid,
+		Title:         b.title,
+		Tags:          b.tags,
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          TimeRange{From: "now-6h", To: "now"},
+		Templating:    Templating{List: b.buildVariables()},
+		Panels:        []Panel{},
+	}
+
+	nextId := 1
+	y := 0
+	lastGroup := ""
+	inRow := 0
+	groupedTemplates, groupNames := groupTemplates(templates)
+	for i, tpl := range groupedTemplates {
+		group := groupNames[i]
+		if group != lastGroup {
+			if inRow > 0 {
+				y += panelHeight
+				inRow = 0
+			}
+			dashboard.Panels = append(dashboard.Panels, Panel{
+				Id:      nextId,
+				Type:    "row",
+				Title:   group,
+				GridPos: GridPos{H: 1, W: 2 * panelWidth, X: 0, Y: y},
+			})
+			nextId++
+			y++
+			lastGroup = group
+		}
+
+		panel := b.buildPanel(tpl)
+		panel.Id = nextId
+		panel.GridPos = GridPos{H: panelHeight, W: panelWidth, X: inRow * panelWidth, Y: y}
+		dashboard.Panels = append(dashboard.Panels, panel)
+		nextId++
+
+		inRow++
+		if inRow == 2 {
+			y += panelHeight
+			inRow = 0
+		}
+	}
+
+	return dashboard
+}
+
+func (b *dashboardBuilder) datasource() *Datasource {
+	return &Datasource{Type: "prometheus", Uid: "${" + datasourceVariable + "}"}
+}
+
+func (b *dashboardBuilder) buildVariables() []Variable {
+	variables := []Variable{
+		{Name: datasourceVariable, Label: "Datasource", Type: "datasource", Query: "prometheus"},
+	}
+
+	// every variable is filtered by the ones before - so e.g. only the hosts of the selected service are offered
+	var selectors []string
+	for _, labelName := range append(append([]string{}, b.globalLabelNames...), "of") {
+		query := fmt.Sprintf("label_values(%v)", labelName)
+		if len(selectors) > 0 {
+			query = fmt.Sprintf("label_values({%v}, %v)", strings.Join(selectors, ", "), labelName)
+		}
+		variables = append(variables, Variable{
+			Name:       labelName,
+			Type:       "query",
+			Query:      map[string]any{"query": query, "refId": "PrometheusVariableQueryEditor-VariableQuery"},
+			Datasource: b.datasource(),
+			Refresh:    2,
+			Multi:      true,
+			IncludeAll: true,
+			AllValue:   ".*",
+			Sort:       1,
+		})
+		selectors = append(selectors, fmt.Sprintf(`%v=~"$%v"`, labelName, labelName))
+	}
+	return variables
+}
+
+// the label selector filtering by the template variables
+func (b *dashboardBuilder) selector(tpl kt_observability_monitoring.MetricTemplateInfo, extra ...string) string {
+	var matchers []string
+	for _, labelName := range b.globalLabelNames {
+		matchers = append(matchers, fmt.Sprintf(`%v=~"$%v"`, labelName, labelName))
+	}
+	if hasLabel(tpl, "of") {
+		matchers = append(matchers, `of=~"$of"`)
+	}
+	matchers = append(matchers, extra...)
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+func (b *dashboardBuilder) buildPanel(tpl kt_observability_monitoring.MetricTemplateInfo) Panel {
+	panel := Panel{
+		Type:        "timeseries",
+		Title:       tpl.Name,
+		Description: tpl.Help,
+		Datasource:  b.datasource(),
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: unitOf(tpl)}},
+	}
+
+	by := ""
+	legend := ""
+	if hasLabel(tpl, "of") {
+		by = "of"
+		legend = "{{of}}"
+	}
+
+	switch tpl.MetricType {
+	case "counter":
+		panel.Title += " (rate)"
+		panel.Targets = []Target{{
+			Expr:         fmt.Sprintf("sum by (%v) (rate(%v%v[$__rate_interval]))", by, tpl.Name, b.selector(tpl)),
+			LegendFormat: legend,
+		}}
+	case "gauge":
+		panel.Targets = []Target{{
+			Expr:         fmt.Sprintf("max by (%v) (%v%v)", joinLabels(by, "instId"), tpl.Name, b.selector(tpl)),
+			LegendFormat: strings.TrimSpace(legend + " {{instId}}"),
+		}}
+	case "summary":
+		panel.Targets = []Target{{
+			Expr:         fmt.Sprintf("max by (%v) (%v%v)", joinLabels(by, "quantile"), tpl.Name, b.selector(tpl, fmt.Sprintf(`quantile=~"%v"`, strings.Join(b.quantiles, "|")))),
+			LegendFormat: strings.TrimSpace(legend + " q{{quantile}}"),
+		}}
+	case "histogram":
+		for _, quantile := range b.quantiles {
+			panel.Targets = append(panel.Targets, Target{
+				Expr:         fmt.Sprintf("histogram_quantile(%v, sum by (%v) (rate(%v_bucket%v[$__rate_interval])))", quantile, joinLabels(by, "le"), tpl.Name, b.selector(tpl)),
+				LegendFormat: strings.TrimSpace(legend + " q" + quantile),
+			})
+		}
+	}
+
+	for i := range panel.Targets {
+		panel.Targets[i].RefId = string(rune('A' + i))
+		panel.Targets[i].Datasource = b.datasource()
+	}
+	return panel
+}
+
+func hasLabel(tpl kt_observability_monitoring.MetricTemplateInfo, labelName string) bool {
+	for _, name := range tpl.LabelNames {
+		if name == labelName {
+			return true
+		}
+	}
+	return false
+}
+
+func joinLabels(labelNames ...string) string {
+	var nonEmpty []string
+	for _, name := range labelNames {
+		if name != "" {
+			nonEmpty = append(nonEmpty, name)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// our time values are in millis and sizes in bytes - the rest is just a number
+func unitOf(tpl kt_observability_monitoring.MetricTemplateInfo) string {
+	switch {
+	case tpl.MetricType == "counter":
+		return "ops"
+	case strings.HasSuffix(tpl.Name, "Time") && tpl.MetricType != "gauge":
+		return "ms"
+	case strings.HasSuffix(tpl.Name, "Size"):
+		return "bytes"
+	default:
+		return "short"
+	}
+}
+
+// keeps the templates of the same group together - groups are in order of their first appearance. Returns the group names too (same index as the template).
+//
+// Templates are grouped by the first camel case "hump" of their name (e.g. "clientReqSentCount" and "clientRespSize" are in group "client") - but if all
+// templates of the group share a longer prefix then that is the name of the group (e.g. "circuitBreakerState" and "circuitBreakerRejectedCount" are in group
+// "circuitBreaker").
+func groupTemplates(templates []kt_observability_monitoring.MetricTemplateInfo) ([]kt_observability_monitoring.MetricTemplateInfo, []string) {
+	var firstHumps []string
+	templatesByFirstHump := make(map[string][]kt_observability_monitoring.MetricTemplateInfo)
+	for _, tpl := range templates {
+		firstHump := humpsOf(tpl.Name)[0]
+		if _, found := templatesByFirstHump[firstHump]; !found {
+			firstHumps = append(firstHumps, firstHump)
+		}
+		templatesByFirstHump[firstHump] = append(templatesByFirstHump[firstHump], tpl)
+	}
+
+	grouped := make([]kt_observability_monitoring.MetricTemplateInfo, 0, len(templates))
+	groupNames := make([]string, 0, len(templates))
+	for _, firstHump := range firstHumps {
+		groupTemplates := templatesByFirstHump[firstHump]
+		groupName := firstHump
+		if len(groupTemplates) > 1 {
+			groupName = commonHumpsPrefix(groupTemplates)
+		}
+		for _, tpl := range groupTemplates {
+			grouped = append(grouped, tpl)
+			groupNames = append(groupNames, groupName)
+		}
+	}
+	return grouped, groupNames
+}
+
+// splits the name into camel case "humps" - e.g. "clientReqSentCount" is "client", "Req", "Sent", "Count"
+func humpsOf(name string) []string {
+	var humps []string
+	start := 0
+	for i, r := range name {
+		if i > 0 && (r >= 'A' && r <= 'Z' || r == '_' || r == ':') {
+			humps = append(humps, name[start:i])
+			start = i
+		}
+	}
+	return append(humps, name[start:])
+}
+
+// the longest prefix (built from whole humps) all the template names share - but never a whole name
+func commonHumpsPrefix(templates []kt_observability_monitoring.MetricTemplateInfo) string {
+	common := humpsOf(templates[0].Name)
+	for _, tpl := range templates[1:] {
+		humps := humpsOf(tpl.Name)
+		n := 0
+		for n < len(common) && n < len(humps) && common[n] == humps[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	for _, tpl := range templates {
+		if strings.Join(common, "") == tpl.Name {
+			common = common[:len(common)-1]
+		}
+	}
+	return strings.Join(common, "")
+}
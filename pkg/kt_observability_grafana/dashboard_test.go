@@ -0,0 +1,32 @@
+package kt_observability_grafana
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+// the dashboard of the integration test application is checked in - it must be the same what kt-grafanagen generates, see tests/integration_tests
+func TestDashboardMatchesGoldenFile(t *testing.T) {
+	kt_observability_monitoring.InitMetrics()
+	templates := kt_observability_monitoring.RegisteredMetricTemplateInfos()
+	defs, err := kt_observability_monitoring.ParseMetricTemplateDefinitions("../../tests/integration_tests/metric_templates.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse the template definitions: %v", err)
+	}
+	templates = append(templates, defs.Infos()...)
+
+	dashboardJson, err := BuildDashboard("Test application", templates, WithUid("kt-test-application")).JSON()
+	if err != nil {
+		t.Fatalf("failed to build the dashboard: %v", err)
+	}
+	golden, err := os.ReadFile("../../tests/integration_tests/grafana_dashboard.json")
+	if err != nil {
+		t.Fatalf("failed to read the golden file: %v", err)
+	}
+	if !bytes.Equal(append(dashboardJson, '\n'), golden) {
+		t.Errorf("the generated dashboard differs from tests/integration_tests/grafana_dashboard.json - regenerate it with kt-grafanagen if the change is intended")
+	}
+}
@@ -0,0 +1,231 @@
+// Checks metrics against the Keytiles monitoring standards - either the live metrics (a prometheus.Gatherer or a scraped /metrics output) or the template
+// definitions. Useful in CI so violations are caught before they reach Prometheus.
+package kt_observability_lint
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// The rules violations are reported for
+const (
+	// the metric has no "metricType" label - so it was not created from a MetricTemplate
+	RuleNotFromTemplate = "not-from-template"
+	// the name is not lowerCamelCase - as the predefined ones are (e.g. "clientReqSentCount")
+	RuleNameNotLowerCamelCase = "name-not-lower-camel-case"
+	// the metric does not have all the global labels
+	RuleMissingGlobalLabel = "missing-global-label"
+	// a custom label of the template has the same name as a global label
+	RuleLabelCollidesWithGlobal = "label-collides-with-global"
+	// the metric has no Help text
+	RuleEmptyHelp = "empty-help"
+	// a counter without "of" label or with an empty / placeholder "of" value
+	RuleCounterWithoutOf = "counter-without-of"
+)
+
+var lowerCamelCaseRegex = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+// One problem found
+type Violation struct {
+	Metric  string `json:"metric"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v: [%v] %v", v.Metric, v.Rule, v.Message)
+}
+
+type linter struct {
+	globalLabelNames []string
+	ignoredPrefixes  []string
+}
+
+type LintOpt func(*linter)
+
+// The global labels every metric must have - by default kt_observability.StandardGlobalLabelNames
+func WithGlobalLabelNames(labelNames ...string) LintOpt {
+	return func(l *linter) {
+		l.globalLabelNames = labelNames
+	}
+}
+
+// Metrics with these name prefixes are not checked - by default the ones of the standard Go and process collectors ("go_", "process_", "promhttp_")
+func WithIgnoredPrefixes(prefixes ...string) LintOpt {
+	return func(l *linter) {
+		l.ignoredPrefixes = prefixes
+	}
+}
+
+func newLinter(opts []LintOpt) *linter {
+	l := &linter{
+		globalLabelNames: kt_observability.StandardGlobalLabelNames,
+		ignoredPrefixes:  []string{"go_", "process_", "promhttp_"},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *linter) isIgnored(name string) bool {
+	for _, prefix := range l.ignoredPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the metrics of the gatherer - e.g. kt_observability_monitoring.MetricRegistry. Returns the violations sorted by metric name.
+func LintGatherer(gatherer prometheus.Gatherer, opts ...LintOpt) ([]Violation, error) {
+	metricFamilies, err := gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics! error was: %v", err)
+	}
+	return lintMetricFamilies(metricFamilies, newLinter(opts)), nil
+}
+
+// Checks the metrics in the given text exposition format - e.g. the output of a /metrics endpoint. Returns the violations sorted by metric name.
+func LintExposition(in io.Reader, opts ...LintOpt) ([]Violation, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	metricFamiliesByName, err := parser.TextToMetricFamilies(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics! error was: %v", err)
+	}
+	metricFamilies := make([]*dto.MetricFamily, 0, len(metricFamiliesByName))
+	for _, mf := range metricFamiliesByName {
+		metricFamilies = append(metricFamilies, mf)
+	}
+	return lintMetricFamilies(metricFamilies, newLinter(opts)), nil
+}
+
+func lintMetricFamilies(metricFamilies []*dto.MetricFamily, l *linter) []Violation {
+	var violations []Violation
+	for _, mf := range metricFamilies {
+		name := mf.GetName()
+		if l.isIgnored(name) {
+			continue
+		}
+		add := func(rule string, format string, args ...any) {
+			violations = append(violations, Violation{Metric: name, Rule: rule, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if !lowerCamelCaseRegex.MatchString(name) {
+			add(RuleNameNotLowerCamelCase, "name is not lowerCamelCase")
+		}
+		if strings.TrimSpace(mf.GetHelp()) == "" {
+			add(RuleEmptyHelp, "Help text is empty")
+		}
+
+		// we report every problem once per metric - not per series
+		notFromTemplate := false
+		missingGlobalLabels := make(map[string]bool)
+		counterWithoutOf := false
+		for _, metric := range mf.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if _, found := labels["metricType"]; !found {
+				notFromTemplate = true
+			}
+			for _, globalLabelName := range l.globalLabelNames {
+				if _, found := labels[globalLabelName]; !found {
+					missingGlobalLabels[globalLabelName] = true
+				}
+			}
+			if mf.GetType() == dto.MetricType_COUNTER && !isMeaningfulOf(labels["of"]) {
+				counterWithoutOf = true
+			}
+		}
+
+		if notFromTemplate {
+			add(RuleNotFromTemplate, "has no 'metricType' label - looks like it was not created from a MetricTemplate")
+		}
+		if len(missingGlobalLabels) > 0 {
+			add(RuleMissingGlobalLabel, "missing global labels: %v", strings.Join(sortedKeys(missingGlobalLabels), ", "))
+		}
+		if counterWithoutOf {
+			add(RuleCounterWithoutOf, "counter has series without a meaningful 'of' label value")
+		}
+	}
+	sortViolations(violations)
+	return violations
+}
+
+// Checks the template definitions - e.g. the ones in a definitions file. Returns the violations sorted by metric name.
+//
+// Checking the metrics of a running service (LintGatherer(), LintExposition()) is more complete - but you can check the definitions before anything is
+// deployed.
+func LintTemplates(templates []kt_observability_monitoring.MetricTemplateInfo, opts ...LintOpt) []Violation {
+	l := newLinter(opts)
+
+	globalLabelNames := make(map[string]bool, len(l.globalLabelNames))
+	for _, labelName := range l.globalLabelNames {
+		globalLabelNames[labelName] = true
+	}
+
+	var violations []Violation
+	for _, tpl := range templates {
+		add := func(rule string, format string, args ...any) {
+			violations = append(violations, Violation{Metric: tpl.Name, Rule: rule, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if !lowerCamelCaseRegex.MatchString(tpl.Name) {
+			add(RuleNameNotLowerCamelCase, "name is not lowerCamelCase")
+		}
+		if strings.TrimSpace(tpl.Help) == "" {
+			add(RuleEmptyHelp, "Help text is empty")
+		}
+		hasOf := false
+		for _, labelName := range tpl.LabelNames {
+			if globalLabelNames[labelName] {
+				add(RuleLabelCollidesWithGlobal, "label '%v' collides with a global label", labelName)
+			}
+			if labelName == "of" {
+				hasOf = true
+			}
+		}
+		if tpl.MetricType == "counter" && !hasOf {
+			add(RuleCounterWithoutOf, "counter has no 'of' label")
+		}
+	}
+	sortViolations(violations)
+	return violations
+}
+
+// "-" is the default label value and "?" the "unknown" value of global labels - these do not tell anything
+func isMeaningfulOf(of string) bool {
+	switch strings.TrimSpace(of) {
+	case "", "-", "?":
+		return false
+	default:
+		return true
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortViolations(violations []Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Metric < violations[j].Metric
+	})
+}
@@ -0,0 +1,155 @@
+package kt_observability_lint
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the rules violated per metric - so the tests do not depend on the exact messages
+func rulesByMetric(violations []Violation) map[string][]string {
+	rules := make(map[string][]string)
+	for _, violation := range violations {
+		rules[violation.Metric] = append(rules[violation.Metric], violation.Rule)
+	}
+	return rules
+}
+
+func TestLintTemplates(t *testing.T) {
+	templates := []kt_observability_monitoring.MetricTemplateInfo{
+		{Name: "cleanCount", MetricType: "counter", Help: "clean counter", LabelNames: []string{"of", "topic"}},
+		{Name: "cleanTime", MetricType: "summary", Help: "clean summary", LabelNames: []string{"topic"}},
+		{Name: "Bad_name", MetricType: "gauge", Help: "bad name", LabelNames: []string{"of"}},
+		{Name: "noHelp", MetricType: "gauge", Help: "  ", LabelNames: []string{"of"}},
+		{Name: "collidingCount", MetricType: "counter", Help: "label collides", LabelNames: []string{"of", "serviceName"}},
+		{Name: "noOfCount", MetricType: "counter", Help: "counter without of", LabelNames: []string{"topic"}},
+	}
+
+	got := rulesByMetric(LintTemplates(templates))
+	want := map[string][]string{
+		"Bad_name":       {RuleNameNotLowerCamelCase},
+		"noHelp":         {RuleEmptyHelp},
+		"collidingCount": {RuleLabelCollidesWithGlobal},
+		"noOfCount":      {RuleCounterWithoutOf},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLintExposition(t *testing.T) {
+	exposition := `# HELP cleanCount clean counter
+# TYPE cleanCount counter
+cleanCount{host="h",instId="i",metricType="counter",of="orders",serviceName="s",serviceVer="1"} 3
+# HELP notFromTemplate plain gauge
+# TYPE notFromTemplate gauge
+notFromTemplate{host="h",instId="i",serviceName="s",serviceVer="1"} 1
+# HELP missingGlobalCount counter with one global label only
+# TYPE missingGlobalCount counter
+missingGlobalCount{metricType="counter",of="orders",serviceName="s"} 1
+# HELP placeholderOfCount counter with placeholder of
+# TYPE placeholderOfCount counter
+placeholderOfCount{host="h",instId="i",metricType="counter",of="-",serviceName="s",serviceVer="1"} 1
+placeholderOfCount{host="h",instId="i",metricType="counter",of="orders",serviceName="s",serviceVer="1"} 1
+# HELP snake_case_total bad name
+# TYPE snake_case_total counter
+snake_case_total{host="h",instId="i",metricType="counter",of="orders",serviceName="s",serviceVer="1"} 1
+# HELP go_goroutines ignored
+# TYPE go_goroutines gauge
+go_goroutines 5
+`
+	violations, err := LintExposition(strings.NewReader(exposition))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := rulesByMetric(violations)
+	want := map[string][]string{
+		"notFromTemplate":    {RuleNotFromTemplate},
+		"missingGlobalCount": {RuleMissingGlobalLabel},
+		"placeholderOfCount": {RuleCounterWithoutOf},
+		"snake_case_total":   {RuleNameNotLowerCamelCase},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for _, violation := range violations {
+		if violation.Rule == RuleMissingGlobalLabel && violation.Message != "missing global labels: host, instId, serviceVer" {
+			t.Errorf("expected the missing labels in the message, got %q", violation.Message)
+		}
+	}
+
+	if _, err := LintExposition(strings.NewReader("this is { not metrics")); err == nil {
+		t.Errorf("expected an error for a broken exposition")
+	}
+}
+
+func TestLintGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "plainCount", Help: "not from a template"}, []string{"of"})
+	registry.MustRegister(counter)
+	counter.WithLabelValues("x").Inc()
+
+	violations, err := LintGatherer(registry, WithGlobalLabelNames("region"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := rulesByMetric(violations)
+	want := map[string][]string{"plainCount": {RuleNotFromTemplate, RuleMissingGlobalLabel}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// the same setup as the test application - its own global labels, the predefined templates and its metric_templates.yaml - must be clean
+func TestIntegrationRegistryIsClean(t *testing.T) {
+	kt_observability_monitoring.InitMetrics()
+	kt_observability_monitoring.SetGlobalLabels(map[string]any{"globalLabel1": "value1", "globalLabel2": 5})
+	templates, err := kt_observability_monitoring.LoadMetricTemplates("../../tests/integration_tests/metric_templates.yaml")
+	if err != nil {
+		t.Fatalf("failed to load the templates of the test application: %v", err)
+	}
+	opts := []LintOpt{WithGlobalLabelNames("globalLabel1", "globalLabel2")}
+
+	if violations := LintTemplates(kt_observability_monitoring.RegisteredMetricTemplateInfos(), opts...); len(violations) > 0 {
+		t.Errorf("expected clean templates, got %v", violations)
+	}
+
+	// and some series - like the test application has them
+	for name, tpl := range templates {
+		labels := map[string]any{}
+		for _, labelName := range tpl.Info().LabelNames {
+			labels[labelName] = "lintTest"
+		}
+		switch tpl.Info().MetricType {
+		case "counter":
+			kt_observability_monitoring.GetCounterMetricInstance(tpl, labels).Inc()
+		case "gauge":
+			kt_observability_monitoring.GetGaugeMetricInstance(tpl, labels).Set(1)
+		case "summary":
+			kt_observability_monitoring.GetSummaryMetricInstance(tpl, labels).Observe(1)
+		case "histogram":
+			kt_observability_monitoring.GetHistogramMetricInstance(tpl, labels).Observe(1)
+		default:
+			t.Fatalf("%v: unexpected metric type %v", name, tpl.Info().MetricType)
+		}
+	}
+	httpServer := kt_observability_monitoring.NewHttpServerLazyMetricsSet("lintTestEndpoint")
+	httpServer.ServeSucceeded(httptest.NewRequest("GET", "/", nil), "200")
+	httpClient := kt_observability_monitoring.NewHttpClientLazyMetricsSet("lintTestClient")
+	httpClient.Begin().End("200")
+	job := kt_observability_monitoring.NewScheduledJobMetricsSet("lintTestJob")
+	job.Run(context.Background(), func(ctx context.Context) error { return nil })
+
+	violations, err := LintGatherer(kt_observability_monitoring.MetricRegistry, opts...)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(violations) > 0 {
+		t.Errorf("expected clean metrics, got %v", violations)
+	}
+}
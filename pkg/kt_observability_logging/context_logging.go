@@ -0,0 +1,21 @@
+package kt_observability_logging
+
+import (
+	"context"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+)
+
+// Returns a LogEvent of the named Logger decorated with the labels of the context (see kt_observability.WithLabels()) - so request-scoped labels are
+// attached to the log events automatically, e.g.
+//
+//	kt_observability_logging.GetContextLogger(ctx, "main.orders").Info("order %v accepted", orderId)
+func GetContextLogger(ctx context.Context, loggerName string) kt_logging.LogEvent {
+	return WithContextLabels(ctx, kt_logging.GetLogger(loggerName))
+}
+
+// Same as GetContextLogger() but with a Logger you already have
+func WithContextLabels(ctx context.Context, logger *kt_logging.Logger) kt_logging.LogEvent {
+	return logger.WithLabels(BuildLogLabels(kt_observability.LabelsFrom(ctx)))
+}
@@ -0,0 +1,39 @@
+package kt_observability_logging
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+)
+
+func TestGetContextLogger(t *testing.T) {
+	logger, buffer := newBufferedLogger(t)
+	ctx := kt_observability.WithLabels(context.Background(), map[string]any{"tenantId": "t1", "attempt": 2})
+
+	GetContextLogger(ctx, logger.GetName()).Info("order %v accepted", 42)
+
+	got := parseLogLine(t, bytes.TrimSpace(buffer.Bytes()))
+	if got["tenantId"] != "t1" || got["attempt"] != float64(2) {
+		t.Errorf("expected the labels of the context, got %v", got)
+	}
+	if got["msg"] != "order 42 accepted" || got["logger"] != logger.GetName() {
+		t.Errorf("expected the message with the name of the logger, got %v", got)
+	}
+}
+
+func TestGetContextLoggerWithoutLabels(t *testing.T) {
+	logger, buffer := newBufferedLogger(t)
+
+	GetContextLogger(context.Background(), logger.GetName()).Info("nothing extra")
+
+	got := parseLogLine(t, bytes.TrimSpace(buffer.Bytes()))
+	for key := range got {
+		switch key {
+		case "msg", "level", "time", "logger":
+		default:
+			t.Errorf("expected no extra labels, got %v", got)
+		}
+	}
+}
@@ -1,63 +1,153 @@
 package kt_observability_logging
 
 import (
-	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 
 	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
 	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
 )
 
-// Builds a list of log labels from the given map
-func BuildLogLabels(labels map[string]any) []kt_logging.Label {
+// The max nesting level WithFlattening() goes into if you do not set it with WithFlatteningLimits() - deeper values are not logged
+var DefaultFlatteningMaxDepth = 5
 
-	logLabels := make([]kt_logging.Label, 0, len(labels))
+// The max number of labels one value is flattened into if you do not set it with WithFlatteningLimits() - the rest is not logged
+var DefaultFlatteningMaxLabels = 100
+
+type logLabelsBuilder struct {
+	flatten   bool
+	separator string
+	maxDepth  int
+	maxLabels int
+}
+
+// the state of flattening one value into labels
+type flattening struct {
+	remainingLabels int
+	// TRUE if some parts were not logged - because they were too deep or there were too many
+	truncated bool
+	// TRUE if we have no more labels left - we can stop
+	full bool
+}
+
+type LogLabelsOpt func(*logLabelsBuilder)
+
+// Slices, arrays and maps are flattened into more labels with dotted keys - e.g. "tags": []string{"a", "b"} becomes "tags.0": "a" and "tags.1": "b", and
+// "user": map[string]any{"id": 5} becomes "user.id": 5. Without this they are reported as not supported values - as kt_logging does not want complex
+// structures in the log events.
+//
+// The flattening is bounded - see WithFlatteningLimits(). If a value does not fit then the "<key>.truncated" label is added with TRUE.
+func WithFlattening() LogLabelsOpt {
+	return func(b *logLabelsBuilder) {
+		b.flatten = true
+	}
+}
 
+// The separator of the flattened keys - by default "."
+func WithFlatteningSeparator(separator string) LogLabelsOpt {
+	return func(b *logLabelsBuilder) {
+		b.separator = separator
+	}
+}
+
+// The max nesting level and the max number of labels one value is flattened into - by default DefaultFlatteningMaxDepth and DefaultFlatteningMaxLabels.
+// Pass in 0 to remove a limit.
+func WithFlatteningLimits(maxDepth int, maxLabels int) LogLabelsOpt {
+	return func(b *logLabelsBuilder) {
+		b.maxDepth = maxDepth
+		b.maxLabels = maxLabels
+	}
+}
+
+// Builds a list of log labels from the given map. The values are normalized with kt_observability.NormalizeLabelValue() - so they look the same as in the
+// metric labels. Time, duration, error, fmt.Stringer, []byte values and pointers are supported too - and slices / maps if you pass in WithFlattening().
+func BuildLogLabels(labels map[string]any, opts ...LogLabelsOpt) []kt_logging.Label {
+	b := newLogLabelsBuilder(opts)
+	logLabels := make([]kt_logging.Label, 0, len(labels))
 	for key, value := range labels {
-		var label kt_logging.Label
-
-		vt := reflect.TypeOf(value)
-		if vt == nil {
-			label = kt_logging.StringLabel(key, "<null>")
-		} else {
-			valueParamType := vt.Kind()
-			switch valueParamType {
-			case reflect.Int:
-				label = kt_logging.FloatLabel(key, float64(value.(int)))
-			case reflect.Int8:
-				label = kt_logging.FloatLabel(key, float64(value.(int8)))
-			case reflect.Int16:
-				label = kt_logging.FloatLabel(key, float64(value.(int16)))
-			case reflect.Int32:
-				label = kt_logging.FloatLabel(key, float64(value.(int32)))
-			case reflect.Int64:
-				label = kt_logging.FloatLabel(key, float64(value.(int64)))
-			case reflect.Uint:
-				label = kt_logging.FloatLabel(key, float64(value.(uint)))
-			case reflect.Uint8:
-				label = kt_logging.FloatLabel(key, float64(value.(uint8)))
-			case reflect.Uint16:
-				label = kt_logging.FloatLabel(key, float64(value.(uint16)))
-			case reflect.Uint32:
-				label = kt_logging.FloatLabel(key, float64(value.(uint32)))
-			case reflect.Uint64:
-				label = kt_logging.FloatLabel(key, float64(value.(uint64)))
-			case reflect.Float32:
-				label = kt_logging.FloatLabel(key, float64(value.(float32)))
-			case reflect.Float64:
-				label = kt_logging.FloatLabel(key, value.(float64))
-			case reflect.String:
-				label = kt_logging.StringLabel(key, value.(string))
-			case reflect.Bool:
-				label = kt_logging.BoolLabel(key, value.(bool))
-			default:
-				label = kt_logging.StringLabel(key, fmt.Sprintf("<'%v' value not supported>", valueParamType))
+		logLabels = b.appendValue(logLabels, key, value)
+	}
+	return logLabels
+}
+
+func newLogLabelsBuilder(opts []LogLabelsOpt) *logLabelsBuilder {
+	b := &logLabelsBuilder{separator: ".", maxDepth: DefaultFlatteningMaxDepth, maxLabels: DefaultFlatteningMaxLabels}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// appends the label(s) of one value - adds the "<key>.truncated" label if the value was flattened only partially
+func (b *logLabelsBuilder) appendValue(logLabels []kt_logging.Label, key string, value any) []kt_logging.Label {
+	f := &flattening{remainingLabels: b.maxLabels}
+	logLabels = b.appendLabels(logLabels, key, value, 0, f)
+	if f.truncated {
+		logLabels = append(logLabels, kt_logging.BoolLabel(key+b.separator+"truncated", true))
+	}
+	return logLabels
+}
+
+func (b *logLabelsBuilder) appendLabels(logLabels []kt_logging.Label, key string, value any, depth int, f *flattening) []kt_logging.Label {
+	normalized, supported := kt_observability.NormalizeLabelValue(value)
+	if !supported && b.flatten {
+		v := reflect.ValueOf(value)
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			if b.maxDepth > 0 && depth >= b.maxDepth {
+				f.truncated = true
+				return logLabels
+			}
+		}
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len() && !f.full; i++ {
+				logLabels = b.appendLabels(logLabels, key+b.separator+strconv.Itoa(i), v.Index(i).Interface(), depth+1, f)
+			}
+			return logLabels
+		case reflect.Map:
+			// sorted - so if we can not log all of them it is always the same ones we skip
+			subKeys := make([]string, 0, v.Len())
+			values := make(map[string]any, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				subKey := kt_observability.LabelValueString(iter.Key().Interface())
+				subKeys = append(subKeys, subKey)
+				values[subKey] = iter.Value().Interface()
+			}
+			sort.Strings(subKeys)
+			for _, subKey := range subKeys {
+				if f.full {
+					break
+				}
+				logLabels = b.appendLabels(logLabels, key+b.separator+subKey, values[subKey], depth+1, f)
 			}
+			return logLabels
 		}
-		logLabels = append(logLabels, label)
 	}
 
-	return logLabels
+	if b.maxLabels > 0 {
+		if f.remainingLabels <= 0 {
+			f.truncated = true
+			f.full = true
+			return logLabels
+		}
+		f.remainingLabels--
+	}
+	switch v := normalized.(type) {
+	case int64:
+		return append(logLabels, kt_logging.IntLabel(key, v))
+	case float64:
+		return append(logLabels, kt_logging.FloatLabel(key, v))
+	case bool:
+		return append(logLabels, kt_logging.BoolLabel(key, v))
+	default:
+		return append(logLabels, kt_logging.StringLabel(key, kt_observability.LabelValueString(v)))
+	}
 }
 
 // builds the default key-value pairs due to our Logging Standards
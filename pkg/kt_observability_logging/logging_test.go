@@ -0,0 +1,70 @@
+package kt_observability_logging
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+)
+
+func TestBuildLogLabelsFlattening(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		opts  []LogLabelsOpt
+		want  []kt_logging.Label
+	}{
+		{
+			name:  "bytes are logged as string",
+			value: []byte("raw"),
+			opts:  []LogLabelsOpt{WithFlattening()},
+			want:  []kt_logging.Label{kt_logging.StringLabel("v", "raw")},
+		},
+		{
+			name:  "not flattened without the option",
+			value: []string{"a", "b"},
+			want:  []kt_logging.Label{kt_logging.StringLabel("v", "<'slice' value not supported>")},
+		},
+		{
+			name:  "slices and maps",
+			value: map[string]any{"tags": []string{"a", "b"}, "id": 5},
+			opts:  []LogLabelsOpt{WithFlattening()},
+			want: []kt_logging.Label{
+				kt_logging.IntLabel("v.id", 5), kt_logging.StringLabel("v.tags.0", "a"), kt_logging.StringLabel("v.tags.1", "b"),
+			},
+		},
+		{
+			name:  "max depth",
+			value: map[string]any{"a": map[string]any{"b": map[string]any{"c": 1}}, "x": 2},
+			opts:  []LogLabelsOpt{WithFlattening(), WithFlatteningLimits(2, 0)},
+			want:  []kt_logging.Label{kt_logging.IntLabel("v.x", 2), kt_logging.BoolLabel("v.truncated", true)},
+		},
+		{
+			name:  "max labels",
+			value: []int{1, 2, 3, 4},
+			opts:  []LogLabelsOpt{WithFlattening(), WithFlatteningLimits(0, 2)},
+			want:  []kt_logging.Label{kt_logging.IntLabel("v.0", 1), kt_logging.IntLabel("v.1", 2), kt_logging.BoolLabel("v.truncated", true)},
+		},
+		{
+			name:  "self referencing value",
+			value: selfReferencing(),
+			opts:  []LogLabelsOpt{WithFlattening(), WithFlatteningLimits(3, 0)},
+			want:  []kt_logging.Label{kt_logging.BoolLabel("v.truncated", true)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := BuildLogLabels(map[string]any{"v": test.value}, test.opts...)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+// without the depth limit this would never end
+func selfReferencing() map[string]any {
+	m := map[string]any{}
+	m["self"] = m
+	return m
+}
@@ -0,0 +1,144 @@
+package kt_observability_logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+)
+
+// A slog.Handler forwarding the records to a kt_logging Logger - so libraries using log/slog end up in the same log stream and format, e.g.
+//
+//	slog.SetDefault(slog.New(kt_observability_logging.NewSlogHandler(kt_logging.GetLogger("slog"))))
+//
+// The attributes are converted with the same typing rules as BuildLogLabels() - groups become dotted key prefixes (e.g. "http.method") and groups without
+// attributes are omitted. The labels of the context (see kt_observability.WithLabels()) are attached too - qualified with the groups of WithGroup() just like
+// the attributes of the record.
+//
+// The global labels are attached by kt_logging itself (see kt_logging.SetGlobalLabels()). If they were not set when the handler was created then the handler
+// attaches BuildDefaultGlobalLogLabels() - so the records are never missing them.
+type SlogHandler struct {
+	logger       *kt_logging.Logger
+	opts         []LogLabelsOpt
+	globalLabels []kt_logging.Label
+	// the labels of WithAttrs() - already with the group prefix
+	attrLabels []kt_logging.Label
+	// the open groups joined with "." - ends with a "." if not empty
+	groupPrefix string
+}
+
+// Creates the handler forwarding to the given Logger. The opts are the same you can pass to BuildLogLabels() - e.g. WithFlattening().
+func NewSlogHandler(logger *kt_logging.Logger, opts ...LogLabelsOpt) *SlogHandler {
+	h := &SlogHandler{logger: logger, opts: opts}
+	if len(kt_logging.GetGlobalLabels()) == 0 {
+		h.globalLabels = BuildDefaultGlobalLogLabels()
+	}
+	return h
+}
+
+// slog levels between the kt_logging ones are rounded down - e.g. slog.LevelInfo+2 is still Info
+func toKtLogLevel(level slog.Level) kt_logging.LogLevel {
+	switch {
+	case level >= slog.LevelError:
+		return kt_logging.ErrorLevel
+	case level >= slog.LevelWarn:
+		return kt_logging.WarningLevel
+	case level >= slog.LevelInfo:
+		return kt_logging.InfoLevel
+	default:
+		return kt_logging.DebugLevel
+	}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	switch toKtLogLevel(level) {
+	case kt_logging.ErrorLevel:
+		return h.logger.IsErrorEnabled()
+	case kt_logging.WarningLevel:
+		return h.logger.IsWarningEnabled()
+	case kt_logging.InfoLevel:
+		return h.logger.IsInfoEnabled()
+	default:
+		return h.logger.IsDebugEnabled()
+	}
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	labels := make([]kt_logging.Label, 0, len(h.globalLabels)+len(h.attrLabels)+record.NumAttrs())
+	labels = append(labels, h.globalLabels...)
+	b := h.labelsBuilder()
+	if ctx != nil {
+		// the context labels are attributes of the record too - so they go into the open groups
+		for key, value := range kt_observability.LabelsFrom(ctx) {
+			labels = b.appendValue(labels, h.groupPrefix+key, value)
+		}
+	}
+	labels = append(labels, h.attrLabels...)
+	record.Attrs(func(attr slog.Attr) bool {
+		labels = b.appendAttr(labels, h.groupPrefix, attr)
+		return true
+	})
+
+	// the message is not a format string - so we must not let kt_logging interpret the % signs in it
+	event := h.logger.WithLabels(labels)
+	switch toKtLogLevel(record.Level) {
+	case kt_logging.ErrorLevel:
+		event.Error("%s", record.Message)
+	case kt_logging.WarningLevel:
+		event.Warn("%s", record.Message)
+	case kt_logging.InfoLevel:
+		event.Info("%s", record.Message)
+	default:
+		event.Debug("%s", record.Message)
+	}
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.attrLabels = append([]kt_logging.Label{}, h.attrLabels...)
+	b := h.labelsBuilder()
+	for _, attr := range attrs {
+		clone.attrLabels = b.appendAttr(clone.attrLabels, h.groupPrefix, attr)
+	}
+	return &clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groupPrefix = h.groupPrefix + name + "."
+	return &clone
+}
+
+func (h *SlogHandler) labelsBuilder() *logLabelsBuilder {
+	return newLogLabelsBuilder(h.opts)
+}
+
+// converts the attribute following the rules of slog.Handler - empty attributes are skipped, groups with empty key are inlined
+func (b *logLabelsBuilder) appendAttr(logLabels []kt_logging.Label, prefix string, attr slog.Attr) []kt_logging.Label {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		groupAttrs := value.Group()
+		if len(groupAttrs) == 0 {
+			return logLabels
+		}
+		if attr.Key != "" {
+			prefix = prefix + attr.Key + "."
+		}
+		for _, groupAttr := range groupAttrs {
+			logLabels = b.appendAttr(logLabels, prefix, groupAttr)
+		}
+		return logLabels
+	}
+	if attr.Key == "" && value.Any() == nil {
+		return logLabels
+	}
+	return b.appendValue(logLabels, prefix+attr.Key, value.Any())
+}
@@ -0,0 +1,91 @@
+package kt_observability_logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// returns a Logger writing JSON into the returned buffer - its only handler is replaced, so nothing else is affected
+func newBufferedLogger(t *testing.T) (*kt_logging.Logger, *bytes.Buffer) {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		MessageKey:  "message",
+		LevelKey:    "level",
+		TimeKey:     "time",
+		EncodeLevel: zapcore.LowercaseLevelEncoder,
+		EncodeTime:  zapcore.RFC3339NanoTimeEncoder,
+	})
+	logger := kt_logging.GetLogger("slogHandlerTest." + strings.ReplaceAll(t.Name(), "/", "_"))
+	handlers := logger.GetHandlers()
+	clear(handlers)
+	handlers["test"] = zap.New(zapcore.NewCore(encoder, zapcore.AddSync(buffer), zapcore.DebugLevel))
+	return logger, buffer
+}
+
+// parses the JSON log line into the structure slogtest expects - the dotted keys become nested groups
+func parseLogLine(t *testing.T, line []byte) map[string]any {
+	t.Helper()
+	var flat map[string]any
+	if err := json.Unmarshal(line, &flat); err != nil {
+		t.Fatalf("failed to parse log line %q: %v", line, err)
+	}
+	result := map[string]any{}
+	for key, value := range flat {
+		if key == "message" {
+			key = slog.MessageKey
+		}
+		parts := strings.Split(key, ".")
+		group := result
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := group[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				group[part] = child
+			}
+			group = child
+		}
+		group[parts[len(parts)-1]] = value
+	}
+	return result
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buffer *bytes.Buffer
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		if strings.HasSuffix(t.Name(), "/zero-time") {
+			t.Skip("kt_logging stamps the time of the events itself")
+		}
+		var logger *kt_logging.Logger
+		logger, buffer = newBufferedLogger(t)
+		return NewSlogHandler(logger)
+	}, func(t *testing.T) map[string]any {
+		return parseLogLine(t, bytes.TrimSpace(buffer.Bytes()))
+	})
+}
+
+func TestSlogHandlerContextLabelsAreGroupQualified(t *testing.T) {
+	logger, buffer := newBufferedLogger(t)
+	ctx := kt_observability.WithLabels(context.Background(), map[string]any{"requestId": "r1"})
+
+	slog.New(NewSlogHandler(logger)).WithGroup("http").InfoContext(ctx, "message", "method", "GET")
+
+	got := parseLogLine(t, bytes.TrimSpace(buffer.Bytes()))
+	group, _ := got["http"].(map[string]any)
+	if group["requestId"] != "r1" || group["method"] != "GET" {
+		t.Errorf("expected requestId and method in the http group, got %v", got)
+	}
+	if _, found := got["requestId"]; found {
+		t.Errorf("requestId must not be logged outside of the group, got %v", got)
+	}
+}
@@ -0,0 +1,53 @@
+package kt_observability_monitoring
+
+import (
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The build info labels the "buildInfo" gauge carries - unless they are global labels already (e.g. "commit" is a global label by default)
+var buildInfoLabelNames = []string{
+	kt_observability.GoVersionLabelName,
+	kt_observability.CommitLabelName,
+	kt_observability.CommitTimeLabelName,
+	kt_observability.CommitModifiedLabelName,
+}
+
+// The "buildInfo" gauge - always 1, the information is in the labels. Use it in dashboards to see which version is running where, e.g.
+//
+//	count by (serviceName, serviceVer, commit) (buildInfo)
+//
+// It is not a MetricTemplate - the global labels are read when the Metrics are collected, so it follows SetGlobalLabels() even if that is invoked after
+// InitMetrics().
+type buildInfoCollector struct {
+	buildInfoLabels map[string]any
+}
+
+func newBuildInfoCollector() buildInfoCollector {
+	// reading the build info never fails - if it is not there we just get no labels
+	buildInfoLabels, _ := kt_observability.NewBuildInfoLabelProvider(nil).Labels()
+	return buildInfoCollector{buildInfoLabels: buildInfoLabels}
+}
+
+// we do not describe anything as the labels are only known at collect time - so this is an "unchecked" collector for the registry
+func (c buildInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (c buildInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	labels := prometheus.Labels{"metricType": "gauge"}
+	// SetGlobalLabels() may run concurrently with the scrape - so we take the labels under its lock
+	for key, value := range getGlobalMetricLabels() {
+		labels[key] = value
+	}
+	for _, labelName := range buildInfoLabelNames {
+		if _, isGlobal := labels[labelName]; isGlobal {
+			continue
+		}
+		labels[labelName] = "-"
+		if value, found := c.buildInfoLabels[labelName]; found {
+			labels[labelName] = kt_observability.LabelValueString(value)
+		}
+	}
+	desc := prometheus.NewDesc("buildInfo", "Build info metric - always 1. Reports the Go version and VCS info the service was built with in its labels", nil, labels)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1)
+}
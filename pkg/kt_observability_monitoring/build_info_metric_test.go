@@ -0,0 +1,44 @@
+package kt_observability_monitoring
+
+import (
+	"sync"
+	"testing"
+)
+
+// run it with -race: the scrape must not read the global labels while SetGlobalLabels() replaces them
+func TestBuildInfoFollowsSetGlobalLabels(t *testing.T) {
+	InitMetrics()
+	SetGlobalLabels(map[string]any{"serviceName": "first"})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			SetGlobalLabels(map[string]any{"serviceName": "second"})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := MetricRegistry.Gather(); err != nil {
+			t.Fatalf("failed to gather: %v", err)
+		}
+	}
+	wg.Wait()
+
+	families, err := MetricRegistry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "buildInfo" {
+			continue
+		}
+		for _, pair := range family.GetMetric()[0].GetLabel() {
+			if pair.GetName() == "serviceName" && pair.GetValue() == "second" {
+				return
+			}
+		}
+		t.Fatalf("buildInfo does not have the new serviceName")
+	}
+	t.Errorf("buildInfo is not in the registry")
+}
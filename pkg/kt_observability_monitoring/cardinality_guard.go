@@ -0,0 +1,176 @@
+package kt_observability_monitoring
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// the label value used in the series new label value combinations are folded into once the cardinality limit of the template is reached
+	OverflowLabelValue = "__overflow__"
+	// the label value used instead of values which are not allowed for the label - see MetricTemplate.SetAllowedLabelValues()
+	OtherLabelValue = "other"
+
+	// we do not want to flood the logs - this is the min time between two warnings about the same template
+	cardinalityWarningInterval = time.Minute
+)
+
+// The cardinality limit new templates are created with - 0 means no limit. You can change this centrally - but please note it only affects templates created
+// after the change! For already existing templates use MetricTemplate.SetCardinalityLimit().
+var DefaultCardinalityLimit = 0
+
+// Sets the max number of distinct label value combinations (so Metric instances) this template hands out. Once the limit is reached new combinations are
+// folded into one "overflow" series (all custom label values are OverflowLabelValue), the "cardinalityLimitExceeded" counter is increased and a warning is
+// logged (but not more often than once a minute).
+//
+// This is a safety net against bugs like putting user ids into a label which would blow up your Prometheus. Pass in 0 to remove the limit.
+//
+// Please note: set the limit before you create instances of the template! Templates without limit (and without TTL) do not keep track of their instances - so
+// the ones created before the limit was set are not counted into it.
+func (tpl *MetricTemplate) SetCardinalityLimit(limit int) {
+	if tpl.state == nil {
+		return
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	tpl.state.lock.Lock()
+	tpl.state.cardinalityLimit = limit
+	tpl.state.updateTracksInstances()
+	tpl.state.lock.Unlock()
+}
+
+// Returns the cardinality limit of the template - 0 means no limit
+func (tpl *MetricTemplate) CardinalityLimit() int {
+	if tpl.state == nil {
+		return 0
+	}
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+	return tpl.state.cardinalityLimit
+}
+
+// Returns how many distinct label value combinations (so Metric instances) the template has right now - the overflow series included
+func (tpl *MetricTemplate) InstanceCount() int {
+	return len(tpl.liveInstanceLabels())
+}
+
+// Restricts the values of the given (custom) label to the allowed values - any other value is replaced with OtherLabelValue when instances are created. This
+// way you can keep the cardinality of a label under control even if the values are coming from outside (e.g. from the request).
+//
+// Pass in no values to remove the restriction from the label.
+func (tpl *MetricTemplate) SetAllowedLabelValues(labelName string, allowedValues ...string) {
+	if tpl.state == nil {
+		return
+	}
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+
+	defer func() {
+		tpl.state.hasAllowedLabelValues.Store(len(tpl.state.allowedLabelValues) > 0)
+	}()
+
+	if len(allowedValues) == 0 {
+		delete(tpl.state.allowedLabelValues, labelName)
+		return
+	}
+	allowed := make(map[string]bool, len(allowedValues))
+	for _, value := range allowedValues {
+		allowed[value] = true
+	}
+	if tpl.state.allowedLabelValues == nil {
+		tpl.state.allowedLabelValues = make(map[string]map[string]bool)
+	}
+	tpl.state.allowedLabelValues[labelName] = allowed
+}
+
+// replaces the values which are not allowed with OtherLabelValue - see SetAllowedLabelValues()
+func (tpl *MetricTemplate) foldNotAllowedLabelValues(labels prometheus.Labels) prometheus.Labels {
+	if tpl.state == nil || !tpl.state.hasAllowedLabelValues.Load() {
+		return labels
+	}
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+
+	for labelName, allowed := range tpl.state.allowedLabelValues {
+		if value, found := labels[labelName]; found && !allowed[value] {
+			labels[labelName] = OtherLabelValue
+		}
+	}
+	return labels
+}
+
+// builds a key from the label values - in the order of the label names of the template
+func (tpl *MetricTemplate) instanceKey(labels prometheus.Labels) string {
+	var key strings.Builder
+	for _, name := range tpl.customLabelNames {
+		key.WriteString(labels[name])
+		key.WriteByte(0xff)
+	}
+	return key.String()
+}
+
+// checks if the label value combination fits into the cardinality limit - if not then returns the overflow combination instead
+func (tpl *MetricTemplate) guardCardinality(labels prometheus.Labels) prometheus.Labels {
+	if tpl.state == nil || !tpl.state.tracksInstances.Load() {
+		// no limit and no TTL - nothing to keep track of
+		return labels
+	}
+
+	key := tpl.instanceKey(labels)
+
+	tpl.state.lock.Lock()
+	if entry, found := tpl.state.instances[key]; found {
+		entry.touch()
+		tpl.state.lock.Unlock()
+		return labels
+	}
+	if tpl.state.cardinalityLimit == 0 || len(tpl.state.instances) < tpl.state.cardinalityLimit {
+		tpl.state.addInstance(key, labels)
+		tpl.state.lock.Unlock()
+		return labels
+	}
+	// we are over the limit
+	doWarn := time.Since(tpl.state.cardinalityWarnedAt) >= cardinalityWarningInterval
+	if doWarn {
+		tpl.state.cardinalityWarnedAt = time.Now()
+	}
+	limit := tpl.state.cardinalityLimit
+	tpl.state.lock.Unlock()
+
+	if doWarn {
+		tpl._LOGGER.Warn(
+			"%v: cardinality limit %d is reached - new label value combinations are folded into the '%v' series! Rejected labels: %v",
+			tpl.ToString(), limit, OverflowLabelValue, labels,
+		)
+	}
+	// the counter has no limit - but if somebody has set one on it we must not count its own overflow into itself, that would never end
+	if exceededTemplate := GetCardinalityLimitExceededTemplate(); exceededTemplate.state != tpl.state {
+		GetCounterMetricInstance(exceededTemplate, map[string]any{"of": tpl.fullyQualifiedName}).Inc()
+	}
+
+	overflowLabels := overflowLabelsOf(labels)
+	// the overflow series does not count into the limit - but it can expire just like the others
+	tpl.state.lock.Lock()
+	if tpl.state.overflow == nil {
+		tpl.state.overflow = newMetricInstanceEntry(overflowLabels)
+	}
+	tpl.state.overflow.touch()
+	tpl.state.lock.Unlock()
+	return overflowLabels
+}
+
+// returns the label value combination of the overflow series - all label values are OverflowLabelValue except "metricType"
+func overflowLabelsOf(labels prometheus.Labels) prometheus.Labels {
+	overflowLabels := prometheus.Labels{}
+	for name, value := range labels {
+		if name == "metricType" {
+			overflowLabels[name] = value
+		} else {
+			overflowLabels[name] = OverflowLabelValue
+		}
+	}
+	return overflowLabels
+}
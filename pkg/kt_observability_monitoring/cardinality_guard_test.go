@@ -0,0 +1,168 @@
+package kt_observability_monitoring
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func newTestCounterTemplate(t *testing.T, name string) MetricTemplate {
+	t.Helper()
+	tpl := GetCounterMetricTemplate(prometheus.CounterOpts{Name: name, Help: "test counter"}, []string{"of"})
+	tpl.Register(MetricRegistry)
+	return tpl
+}
+
+// the value of the instance with the given "of" - taken from the template directly, so it does not matter which registry the template is registered in
+func counterValue(t *testing.T, tpl MetricTemplate, of string) float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		tpl.counterVec.Collect(ch)
+		close(ch)
+	}()
+	value := 0.0
+	for metric := range ch {
+		var out dto.Metric
+		if err := metric.Write(&out); err != nil {
+			t.Fatalf("failed to write metric: %v", err)
+		}
+		for _, pair := range out.GetLabel() {
+			if pair.GetName() == "of" && pair.GetValue() == of {
+				value = out.GetCounter().GetValue()
+			}
+		}
+	}
+	return value
+}
+
+// the instances of the template whose labels match the given ones - taken from the template directly, like counterValue()
+func collectMetrics(t *testing.T, tpl MetricTemplate, labels map[string]string) []*dto.Metric {
+	t.Helper()
+	var collector prometheus.Collector
+	switch tpl.metricType {
+	case "summary":
+		collector = tpl.summaryVec
+	case "counter":
+		collector = tpl.counterVec
+	case "gauge":
+		collector = tpl.gaugeVec
+	case "histogram":
+		collector = tpl.histogramVec
+	}
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+	var metrics []*dto.Metric
+	for metric := range ch {
+		out := &dto.Metric{}
+		if err := metric.Write(out); err != nil {
+			t.Fatalf("failed to write metric: %v", err)
+		}
+		matching := 0
+		for _, pair := range out.GetLabel() {
+			if value, found := labels[pair.GetName()]; found && value == pair.GetValue() {
+				matching++
+			}
+		}
+		if matching == len(labels) {
+			metrics = append(metrics, out)
+		}
+	}
+	return metrics
+}
+
+// the value of the single matching counter or gauge instance - or the sample count of the single matching summary instance. 0 if there is no such instance.
+func metricValue(t *testing.T, tpl MetricTemplate, labels map[string]string) float64 {
+	t.Helper()
+	metrics := collectMetrics(t, tpl, labels)
+	switch {
+	case len(metrics) == 0:
+		return 0
+	case len(metrics) > 1:
+		t.Fatalf("%v instances of %v match %v", len(metrics), tpl.fullyQualifiedName, labels)
+	}
+	switch tpl.metricType {
+	case "counter":
+		return metrics[0].GetCounter().GetValue()
+	case "gauge":
+		return metrics[0].GetGauge().GetValue()
+	case "summary":
+		return float64(metrics[0].GetSummary().GetSampleCount())
+	default:
+		return float64(metrics[0].GetHistogram().GetSampleCount())
+	}
+}
+
+// the sum of the observations of the single matching summary instance
+func summarySum(t *testing.T, tpl MetricTemplate, labels map[string]string) float64 {
+	t.Helper()
+	metrics := collectMetrics(t, tpl, labels)
+	if len(metrics) != 1 {
+		t.Fatalf("expected 1 instance of %v matching %v, got %v", tpl.fullyQualifiedName, labels, len(metrics))
+	}
+	return metrics[0].GetSummary().GetSampleSum()
+}
+
+// with DefaultCardinalityLimit=1 the cardinalityLimitExceeded counter overflowed itself once the second template overflowed - and recursed until the stack
+// was gone
+func TestCardinalityLimitExceededCounterIsNotLimited(t *testing.T) {
+	originalLimit := DefaultCardinalityLimit
+	DefaultCardinalityLimit = 1
+	t.Cleanup(func() { DefaultCardinalityLimit = originalLimit })
+	InitMetrics()
+
+	exceeded := GetCardinalityLimitExceededTemplate()
+	if exceeded.CardinalityLimit() != 0 {
+		t.Errorf("cardinalityLimitExceeded must not have a limit but it has %d", exceeded.CardinalityLimit())
+	}
+
+	first := newTestCounterTemplate(t, "guardTestFirstCount")
+	second := newTestCounterTemplate(t, "guardTestSecondCount")
+	for _, tpl := range []MetricTemplate{first, second} {
+		GetCounterMetricInstance(tpl, map[string]any{"of": "a"}).Inc()
+		GetCounterMetricInstance(tpl, map[string]any{"of": "b"}).Inc()
+		GetCounterMetricInstance(tpl, map[string]any{"of": "c"}).Inc()
+	}
+
+	if got := counterValue(t, exceeded, "guardTestFirstCount"); got != 2 {
+		t.Errorf("expected 2 overflows of guardTestFirstCount, got %v", got)
+	}
+	if got := counterValue(t, exceeded, "guardTestSecondCount"); got != 2 {
+		t.Errorf("expected 2 overflows of guardTestSecondCount, got %v", got)
+	}
+	if got := counterValue(t, second, OverflowLabelValue); got != 2 {
+		t.Errorf("expected 2 increments in the overflow series, got %v", got)
+	}
+
+	// even if somebody puts a limit on it - it must not count its own overflow
+	exceeded.SetCardinalityLimit(1)
+	t.Cleanup(func() { exceeded.SetCardinalityLimit(0) })
+	third := newTestCounterTemplate(t, "guardTestThirdCount")
+	GetCounterMetricInstance(third, map[string]any{"of": "a"}).Inc()
+	GetCounterMetricInstance(third, map[string]any{"of": "b"}).Inc()
+}
+
+func TestInstancesAreNotTrackedWithoutLimitAndTTL(t *testing.T) {
+	InitMetrics()
+	tpl := newTestCounterTemplate(t, "guardTestUntrackedCount")
+	tpl.SetCardinalityLimit(0)
+
+	for _, of := range []string{"a", "b", "c"} {
+		GetCounterMetricInstance(tpl, map[string]any{"of": of}).Inc()
+	}
+
+	tpl.state.lock.Lock()
+	tracked := len(tpl.state.instances)
+	tpl.state.lock.Unlock()
+	if tracked != 0 {
+		t.Errorf("expected no tracked instances, got %d", tracked)
+	}
+	// but the catalog still sees them
+	if got := tpl.InstanceCount(); got != 3 {
+		t.Errorf("expected 3 live instances, got %d", got)
+	}
+}
@@ -0,0 +1,408 @@
+package kt_observability_monitoring
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Returned by the CircuitBreaker if the call was rejected because the circuit is open
+var ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
+
+// The state of a CircuitBreaker. The numeric values are the values the "circuitBreakerState" gauge reports.
+type CircuitBreakerState int
+
+const (
+	// calls are going through and their outcome is tracked
+	CircuitBreakerClosed CircuitBreakerState = 0
+	// the open period is over - a limited number of trial calls are let through to check if the remote side has recovered
+	CircuitBreakerHalfOpen CircuitBreakerState = 1
+	// too many calls have failed - calls are rejected immediately until the open period is over
+	CircuitBreakerOpen CircuitBreakerState = 2
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "closed"
+	case CircuitBreakerHalfOpen:
+		return "half-open"
+	case CircuitBreakerOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// A circuit breaker you can put in front of your (HTTP, gRPC, etc) clients - fully instrumented with the standard circuit breaker Metrics.
+//
+// The breaker tracks the outcome of the last N calls (the window). Once there were enough calls in the window and the failure rate reaches the threshold the
+// circuit opens and calls are rejected with ErrCircuitBreakerOpen. After the open period a few trial calls are let through (half-open state) - if they all
+// succeed the circuit closes again, if any of them fails the circuit opens again. Only the outcomes of calls allowed in the current state count.
+//
+// The Metrics carry the same "of", "qualifier" and "clientId" labels as the client Metrics - use NewCircuitBreakerForHttpClient() to take them over from your
+// HttpClientLazyMetricsSet. The methods are safe for concurrent use.
+type CircuitBreaker struct {
+	of        string
+	qualifier any
+	clientId  string
+
+	failureRateThreshold float64
+	windowSize           int
+	minimumCalls         int
+	openDuration         time.Duration
+	halfOpenMaxCalls     int
+	halfOpenProbeTimeout time.Duration
+
+	lock  sync.Mutex
+	state CircuitBreakerState
+	// increased with every state change - the outcome of a call allowed in an earlier generation does not count
+	generation uint64
+	// when the circuit was opened last time
+	openedAt time.Time
+	// when the circuit went half-open last time
+	halfOpenedAt time.Time
+	// ring buffer of the outcomes in the window - true means failure
+	window         []bool
+	windowNext     int
+	windowCalls    int
+	windowFailures int
+	// trial calls let through / succeeded in half-open state
+	halfOpenCalls     int
+	halfOpenSucceeded int
+
+	stateGauge              prometheus.Gauge
+	failureRateGauge        prometheus.Gauge
+	rejectedCounter         prometheus.Counter
+	transitionCountersByKey map[string]prometheus.Counter
+}
+
+type CircuitBreakerOpt func(cb *CircuitBreaker)
+
+// Creates a new circuit breaker.
+//
+// Pass in "of" as the best name (meaningful) of the endpoint the protected client is invoking! And feel free to use the optional setup too! By default the
+// circuit opens if at least 50% of the last 20 calls failed (with at least 10 calls in the window), stays open for 30 seconds and then lets 3 trial calls
+// through. If the trial calls do not report back within 30 seconds the circuit opens again.
+func NewCircuitBreaker(of string, opts ...CircuitBreakerOpt) *CircuitBreaker {
+	if of == "" {
+		panic("Can not create CircuitBreaker with empty 'of' parameter!")
+	}
+
+	cb := &CircuitBreaker{
+		of:                      of,
+		qualifier:               "-",
+		clientId:                "-",
+		failureRateThreshold:    0.5,
+		windowSize:              20,
+		minimumCalls:            10,
+		openDuration:            30 * time.Second,
+		halfOpenMaxCalls:        3,
+		halfOpenProbeTimeout:    30 * time.Second,
+		state:                   CircuitBreakerClosed,
+		transitionCountersByKey: make(map[string]prometheus.Counter),
+	}
+
+	for _, o := range opts {
+		o(cb)
+	}
+
+	if cb.minimumCalls > cb.windowSize {
+		cb.minimumCalls = cb.windowSize
+	}
+	cb.window = make([]bool, cb.windowSize)
+
+	cb.stateGauge = GetGaugeMetricInstance(GetCircuitBreakerStateTemplate(), cb.labels())
+	cb.failureRateGauge = GetGaugeMetricInstance(GetCircuitBreakerFailureRateTemplate(), cb.labels())
+	cb.rejectedCounter = GetCounterMetricInstance(GetCircuitBreakerRejectedCountTemplate(), cb.labels())
+	cb.stateGauge.Set(float64(cb.state))
+
+	return cb
+}
+
+// Creates a new circuit breaker for a client you already observe with a HttpClientLazyMetricsSet. The "of", "qualifier" and "clientId" are taken over from
+// the set so the circuit breaker Metrics can be matched with the client Metrics.
+func NewCircuitBreakerForHttpClient(clientMetrics *HttpClientLazyMetricsSet, opts ...CircuitBreakerOpt) *CircuitBreaker {
+	takeOver := func(cb *CircuitBreaker) {
+		cb.qualifier = clientMetrics.qualifier
+		cb.clientId = clientMetrics.clientId
+	}
+	return NewCircuitBreaker(clientMetrics.of, append([]CircuitBreakerOpt{takeOver}, opts...)...)
+}
+
+// Assigns a "qualifier" to all Metric instances of the circuit breaker.
+func WithCircuitBreakerQualifier(qualifier any) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if qualifier != nil {
+			cb.qualifier = qualifier
+		}
+	}
+}
+
+// Assigns a "clientId" to all Metric instances of the circuit breaker.
+func WithCircuitBreakerClientId(id string) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if id != "" {
+			cb.clientId = id
+		}
+	}
+}
+
+// The failure rate (between 0 and 1) in the window which opens the circuit. Default is 0.5
+func WithCircuitBreakerFailureRateThreshold(threshold float64) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if threshold > 0 && threshold <= 1 {
+			cb.failureRateThreshold = threshold
+		}
+	}
+}
+
+// The window is the last "size" calls - the failure rate is calculated from these. You can also set how many calls the window must contain at least before
+// the circuit can open. Defaults are 20 and 10.
+func WithCircuitBreakerWindow(size int, minimumCalls int) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if size > 0 {
+			cb.windowSize = size
+		}
+		if minimumCalls > 0 {
+			cb.minimumCalls = minimumCalls
+		}
+	}
+}
+
+// How long the circuit stays open before trial calls are let through. Default is 30 seconds.
+func WithCircuitBreakerOpenDuration(d time.Duration) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if d > 0 {
+			cb.openDuration = d
+		}
+	}
+}
+
+// How many trial calls are let through in half-open state - these all have to succeed to close the circuit. Default is 3.
+func WithCircuitBreakerHalfOpenMaxCalls(calls int) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if calls > 0 {
+			cb.halfOpenMaxCalls = calls
+		}
+	}
+}
+
+// How long the circuit waits in half-open state for the outcome of the trial calls. If they did not all report back (see CircuitBreakerPermit) by
+// then - e.g. the caller forgot it or the call hangs - the circuit opens again, so it does not get stuck in half-open state. Default is 30 seconds.
+func WithCircuitBreakerHalfOpenProbeTimeout(d time.Duration) CircuitBreakerOpt {
+	return func(cb *CircuitBreaker) {
+		if d > 0 {
+			cb.halfOpenProbeTimeout = d
+		}
+	}
+}
+
+func (cb *CircuitBreaker) labels() map[string]any {
+	return map[string]any{"of": cb.of, "qualifier": cb.qualifier, "clientId": cb.clientId}
+}
+
+// Returns the current state of the circuit breaker
+func (cb *CircuitBreaker) State() CircuitBreakerState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.refreshOpenState()
+	return cb.state
+}
+
+// Invoke before the call. If the call can go you get back a permit - in this case you must report the outcome of the call with its RecordSuccess() or
+// RecordFailure()! Returns ErrCircuitBreakerOpen if the call must not be made (which is also counted).
+//
+// The permit belongs to the state the call was allowed in - if the state has changed since then (e.g. a slow call allowed while closed finishes when the
+// circuit is half-open already) the outcome is ignored, so it can not be mistaken for the outcome of a trial call.
+func (cb *CircuitBreaker) Allow() (*CircuitBreakerPermit, error) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.refreshOpenState()
+	switch cb.state {
+	case CircuitBreakerOpen:
+		cb.rejectedCounter.Inc()
+		return nil, ErrCircuitBreakerOpen
+	case CircuitBreakerHalfOpen:
+		if cb.halfOpenCalls >= cb.halfOpenMaxCalls {
+			cb.rejectedCounter.Inc()
+			return nil, ErrCircuitBreakerOpen
+		}
+		cb.halfOpenCalls++
+	}
+	return &CircuitBreakerPermit{cb: cb, generation: cb.generation}, nil
+}
+
+// You get this from CircuitBreaker.Allow() - report the outcome of the allowed call with it. Only the first report counts.
+type CircuitBreakerPermit struct {
+	cb         *CircuitBreaker
+	generation uint64
+	reported   atomic.Bool
+}
+
+// Invoke when the allowed call succeeded
+func (p *CircuitBreakerPermit) RecordSuccess() {
+	if p.reported.CompareAndSwap(false, true) {
+		p.cb.record(p.generation, false)
+	}
+}
+
+// Invoke when the allowed call failed
+func (p *CircuitBreakerPermit) RecordFailure() {
+	if p.reported.CompareAndSwap(false, true) {
+		p.cb.record(p.generation, true)
+	}
+}
+
+// Executes the call if the circuit allows it and records its outcome - the call is considered failed if it returned an error. Returns
+// ErrCircuitBreakerOpen without executing the call if the circuit is open.
+func (cb *CircuitBreaker) Execute(call func() error) error {
+	permit, err := cb.Allow()
+	if err != nil {
+		return err
+	}
+	err = call()
+	if err != nil {
+		permit.RecordFailure()
+	} else {
+		permit.RecordSuccess()
+	}
+	return err
+}
+
+// Wraps the given http.RoundTripper (nil means http.DefaultTransport) so all requests go through the circuit breaker. A request is considered failed if it
+// returned a transport error or a 5xx status code. If the circuit is open the request is not sent and ErrCircuitBreakerOpen is returned.
+func (cb *CircuitBreaker) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return circuitBreakerRoundTripper{cb: cb, next: next}
+}
+
+type circuitBreakerRoundTripper struct {
+	cb   *CircuitBreaker
+	next http.RoundTripper
+}
+
+func (rt circuitBreakerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	permit, err := rt.cb.Allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil || resp.StatusCode >= 500 {
+		permit.RecordFailure()
+	} else {
+		permit.RecordSuccess()
+	}
+	return resp, err
+}
+
+func (cb *CircuitBreaker) record(generation uint64, failed bool) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.refreshOpenState()
+	if generation != cb.generation {
+		// the call was allowed in an earlier state - its outcome says nothing about the current one
+		return
+	}
+	switch cb.state {
+	case CircuitBreakerHalfOpen:
+		if failed {
+			cb.transitionTo(CircuitBreakerOpen)
+			return
+		}
+		cb.halfOpenSucceeded++
+		if cb.halfOpenSucceeded >= cb.halfOpenMaxCalls {
+			cb.transitionTo(CircuitBreakerClosed)
+		}
+	case CircuitBreakerClosed:
+		cb.addToWindow(failed)
+		if cb.windowCalls >= cb.minimumCalls && cb.failureRate() >= cb.failureRateThreshold {
+			cb.transitionTo(CircuitBreakerOpen)
+		}
+	}
+}
+
+func (cb *CircuitBreaker) addToWindow(failed bool) {
+	if cb.windowCalls == cb.windowSize {
+		// the window is full - the oldest outcome drops out
+		if cb.window[cb.windowNext] {
+			cb.windowFailures--
+		}
+	} else {
+		cb.windowCalls++
+	}
+	cb.window[cb.windowNext] = failed
+	if failed {
+		cb.windowFailures++
+	}
+	cb.windowNext = (cb.windowNext + 1) % cb.windowSize
+	cb.failureRateGauge.Set(cb.failureRate())
+}
+
+func (cb *CircuitBreaker) resetWindow() {
+	cb.windowNext = 0
+	cb.windowCalls = 0
+	cb.windowFailures = 0
+	cb.failureRateGauge.Set(0)
+}
+
+func (cb *CircuitBreaker) failureRate() float64 {
+	if cb.windowCalls == 0 {
+		return 0
+	}
+	return float64(cb.windowFailures) / float64(cb.windowCalls)
+}
+
+// moves an open circuit to half-open if the open period is over, and a half-open circuit back to open if the trial calls did not report back in time - lock
+// must be held!
+func (cb *CircuitBreaker) refreshOpenState() {
+	switch {
+	case cb.state == CircuitBreakerOpen && time.Since(cb.openedAt) >= cb.openDuration:
+		cb.transitionTo(CircuitBreakerHalfOpen)
+	case cb.state == CircuitBreakerHalfOpen && time.Since(cb.halfOpenedAt) >= cb.halfOpenProbeTimeout:
+		cb.transitionTo(CircuitBreakerOpen)
+	}
+}
+
+// lock must be held!
+func (cb *CircuitBreaker) transitionTo(newState CircuitBreakerState) {
+	if cb.state == newState {
+		return
+	}
+
+	key := cb.state.String() + ">" + newState.String()
+	c, found := cb.transitionCountersByKey[key]
+	if !found {
+		labels := cb.labels()
+		labels["fromState"] = cb.state.String()
+		labels["toState"] = newState.String()
+		c = GetCounterMetricInstance(GetCircuitBreakerTransitionCountTemplate(), labels)
+		cb.transitionCountersByKey[key] = c
+	}
+	c.Inc()
+
+	cb.state = newState
+	cb.generation++
+	cb.stateGauge.Set(float64(newState))
+
+	switch newState {
+	case CircuitBreakerOpen:
+		cb.openedAt = time.Now()
+	case CircuitBreakerHalfOpen:
+		cb.halfOpenedAt = time.Now()
+		cb.halfOpenCalls = 0
+		cb.halfOpenSucceeded = 0
+	case CircuitBreakerClosed:
+		cb.resetWindow()
+	}
+}
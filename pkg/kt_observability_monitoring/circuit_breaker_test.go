@@ -0,0 +1,148 @@
+package kt_observability_monitoring
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testOpenDuration = 20 * time.Millisecond
+
+func newTestCircuitBreaker(t *testing.T, of string, opts ...CircuitBreakerOpt) *CircuitBreaker {
+	t.Helper()
+	InitMetrics()
+	defaults := []CircuitBreakerOpt{WithCircuitBreakerWindow(4, 2), WithCircuitBreakerOpenDuration(testOpenDuration), WithCircuitBreakerHalfOpenMaxCalls(2)}
+	return NewCircuitBreaker(of, append(defaults, opts...)...)
+}
+
+func mustAllow(t *testing.T, cb *CircuitBreaker) *CircuitBreakerPermit {
+	t.Helper()
+	permit, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("expected the call to be allowed in state %v, got %v", cb.State(), err)
+	}
+	return permit
+}
+
+func expectState(t *testing.T, cb *CircuitBreaker, want CircuitBreakerState) {
+	t.Helper()
+	if got := cb.State(); got != want {
+		t.Fatalf("expected state %v, got %v", want, got)
+	}
+	if got := metricValue(t, GetCircuitBreakerStateTemplate(), map[string]string{"of": cb.of}); got != float64(want) {
+		t.Errorf("expected the state gauge to be %v, got %v", float64(want), got)
+	}
+}
+
+// fails enough calls to open the circuit
+func openCircuit(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	mustAllow(t, cb).RecordFailure()
+	mustAllow(t, cb).RecordFailure()
+	expectState(t, cb, CircuitBreakerOpen)
+}
+
+func TestCircuitBreakerOpensAndRejects(t *testing.T) {
+	cb := newTestCircuitBreaker(t, "cbOpenTest")
+
+	mustAllow(t, cb).RecordSuccess()
+	mustAllow(t, cb).RecordFailure()
+	// 1 of 2 failed - that is the 50% threshold
+	expectState(t, cb, CircuitBreakerOpen)
+
+	if _, err := cb.Allow(); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("expected ErrCircuitBreakerOpen, got %v", err)
+	}
+	called := false
+	if err := cb.Execute(func() error { called = true; return nil }); !errors.Is(err, ErrCircuitBreakerOpen) || called {
+		t.Errorf("expected the call to be rejected without executing it, got %v", err)
+	}
+	if got := metricValue(t, GetCircuitBreakerRejectedCountTemplate(), map[string]string{"of": "cbOpenTest"}); got != 2 {
+		t.Errorf("expected 2 rejected calls, got %v", got)
+	}
+	if got := metricValue(t, GetCircuitBreakerTransitionCountTemplate(), map[string]string{"of": "cbOpenTest", "fromState": "closed", "toState": "open"}); got != 1 {
+		t.Errorf("expected 1 closed>open transition, got %v", got)
+	}
+}
+
+func TestCircuitBreakerStaysClosedBelowMinimumCalls(t *testing.T) {
+	cb := newTestCircuitBreaker(t, "cbMinimumCallsTest", WithCircuitBreakerWindow(4, 3))
+
+	mustAllow(t, cb).RecordFailure()
+	mustAllow(t, cb).RecordFailure()
+	expectState(t, cb, CircuitBreakerClosed)
+	if got := metricValue(t, GetCircuitBreakerFailureRateTemplate(), map[string]string{"of": "cbMinimumCallsTest"}); got != 1 {
+		t.Errorf("expected failure rate 1, got %v", got)
+	}
+}
+
+func TestCircuitBreakerClosesAfterSuccessfulTrialCalls(t *testing.T) {
+	cb := newTestCircuitBreaker(t, "cbCloseTest")
+	openCircuit(t, cb)
+
+	time.Sleep(testOpenDuration)
+	expectState(t, cb, CircuitBreakerHalfOpen)
+	first := mustAllow(t, cb)
+	second := mustAllow(t, cb)
+	if _, err := cb.Allow(); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("only 2 trial calls are allowed - expected ErrCircuitBreakerOpen, got %v", err)
+	}
+
+	first.RecordSuccess()
+	// reporting twice does not count twice
+	first.RecordSuccess()
+	expectState(t, cb, CircuitBreakerHalfOpen)
+	second.RecordSuccess()
+	expectState(t, cb, CircuitBreakerClosed)
+	if got := metricValue(t, GetCircuitBreakerFailureRateTemplate(), map[string]string{"of": "cbCloseTest"}); got != 0 {
+		t.Errorf("expected the window to be reset, got failure rate %v", got)
+	}
+}
+
+func TestCircuitBreakerReopensOnFailedTrialCall(t *testing.T) {
+	cb := newTestCircuitBreaker(t, "cbReopenTest")
+	openCircuit(t, cb)
+
+	time.Sleep(testOpenDuration)
+	mustAllow(t, cb).RecordFailure()
+	expectState(t, cb, CircuitBreakerOpen)
+	if got := metricValue(t, GetCircuitBreakerTransitionCountTemplate(), map[string]string{"of": "cbReopenTest", "fromState": "half-open", "toState": "open"}); got != 1 {
+		t.Errorf("expected 1 half-open>open transition, got %v", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresOutcomesOfEarlierStates(t *testing.T) {
+	cb := newTestCircuitBreaker(t, "cbGenerationTest", WithCircuitBreakerHalfOpenMaxCalls(1))
+
+	// a slow call allowed while the circuit is closed
+	slowCall := mustAllow(t, cb)
+	openCircuit(t, cb)
+	time.Sleep(testOpenDuration)
+	expectState(t, cb, CircuitBreakerHalfOpen)
+
+	// it finishes now - this must not be taken as a successful trial call
+	slowCall.RecordSuccess()
+	expectState(t, cb, CircuitBreakerHalfOpen)
+
+	mustAllow(t, cb).RecordSuccess()
+	expectState(t, cb, CircuitBreakerClosed)
+}
+
+func TestCircuitBreakerReopensIfTrialCallsDoNotReportBack(t *testing.T) {
+	probeTimeout := 5 * testOpenDuration
+	cb := newTestCircuitBreaker(t, "cbProbeTimeoutTest", WithCircuitBreakerHalfOpenProbeTimeout(probeTimeout))
+	openCircuit(t, cb)
+
+	time.Sleep(testOpenDuration)
+	forgotten := mustAllow(t, cb)
+	mustAllow(t, cb)
+	expectState(t, cb, CircuitBreakerHalfOpen)
+
+	time.Sleep(probeTimeout)
+	expectState(t, cb, CircuitBreakerOpen)
+	// reporting back too late must not count as a successful trial call
+	forgotten.RecordSuccess()
+	if got := cb.State(); got == CircuitBreakerClosed {
+		t.Errorf("the late outcome must not close the circuit")
+	}
+}
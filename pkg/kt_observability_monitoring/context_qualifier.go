@@ -0,0 +1,68 @@
+package kt_observability_monitoring
+
+import (
+	"context"
+	"sync"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+)
+
+// The max number of distinct "qualifier" values the HTTP lazy sets take from the context if you do not give the allowed values in
+// WithHttpServerContextQualifier() / WithHttpClientContextQualifier(). The values coming after these are reported as "other".
+var DefaultContextQualifierMaxValues = 20
+
+// Takes the "qualifier" of the lazy sets from a context label (see kt_observability.WithLabels()) - only the one label the set was told to use and only the
+// allowed values of it (or the first DefaultContextQualifierMaxValues values if there is no allow-list), so request-scoped labels can not blow up the
+// cardinality
+type contextQualifier struct {
+	labelName     string
+	allowedValues map[string]bool
+	maxValues     int
+
+	lock sync.Mutex
+	// without allowedValues - the values we have handed out so far
+	knownValues map[string]bool
+}
+
+func newContextQualifier(labelName string, allowedValues []string) *contextQualifier {
+	q := &contextQualifier{labelName: labelName}
+	if len(allowedValues) > 0 {
+		q.allowedValues = make(map[string]bool, len(allowedValues))
+		for _, value := range allowedValues {
+			q.allowedValues[value] = true
+		}
+	} else {
+		q.maxValues = DefaultContextQualifierMaxValues
+		q.knownValues = make(map[string]bool)
+	}
+	return q
+}
+
+// "-" if the context does not have the label, OtherLabelValue if the value is not allowed or it is over the max number of values
+func (q *contextQualifier) valueFrom(ctx context.Context) string {
+	if ctx == nil {
+		return "-"
+	}
+	value, found := kt_observability.LabelFrom(ctx, q.labelName)
+	if !found {
+		return "-"
+	}
+	qualifier := kt_observability.LabelValueString(value)
+	if q.allowedValues != nil {
+		if !q.allowedValues[qualifier] {
+			return OtherLabelValue
+		}
+		return qualifier
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.knownValues[qualifier] {
+		return qualifier
+	}
+	if len(q.knownValues) >= q.maxValues {
+		return OtherLabelValue
+	}
+	q.knownValues[qualifier] = true
+	return qualifier
+}
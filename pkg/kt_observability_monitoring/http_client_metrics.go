@@ -1,6 +1,10 @@
 package kt_observability_monitoring
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -10,16 +14,30 @@ import (
 // is why it is "lazy". You can track how many times requests were sent, and how many times they succeeded / failed. You also have the possibility to track
 // Request-Response loop times AND you can do it
 // per each HttpSatatus codes if you want which brings pretty good observability just out of the box.
+//
+// The methods are safe for concurrent use.
 type HttpClientLazyMetricsSet struct {
 	of        string
 	qualifier any
 	clientId  string
 
-	reqSentCounter *prometheus.Counter
+	statusCodePolicy StatusCodePolicy
+	// if set then ForContext() gives back sets with the "qualifier" taken from the context
+	contextQualifier *contextQualifier
+	// the sets ForContext() created so far - key is the qualifier
+	contextSets map[string]*HttpClientLazyMetricsSet
+
+	lock    sync.Mutex
+	created createdMetricInstances
+
+	reqSentCounter   *prometheus.Counter
+	reqInFlightGauge *prometheus.Gauge
 
 	reqSuccessCounterByStatusCode map[string]prometheus.Counter
 	reqProcessingTimeByStatusCode map[string]prometheus.Observer
 	reqFailedCounterByStatusCode  map[string]prometheus.Counter
+	reqSizeByStatusCode           map[string]prometheus.Observer
+	respSizeByStatusCode          map[string]prometheus.Observer
 }
 
 type HttpClientLazyMetricsSetOpt func(m *HttpClientLazyMetricsSet)
@@ -36,9 +54,12 @@ func NewHttpClientLazyMetricsSet(of string, opts ...HttpClientLazyMetricsSetOpt)
 		of:                            of,
 		qualifier:                     "-",
 		clientId:                      "-",
+		statusCodePolicy:              DefaultStatusCodePolicy,
 		reqSuccessCounterByStatusCode: make(map[string]prometheus.Counter),
 		reqProcessingTimeByStatusCode: make(map[string]prometheus.Observer),
 		reqFailedCounterByStatusCode:  make(map[string]prometheus.Counter),
+		reqSizeByStatusCode:           make(map[string]prometheus.Observer),
+		respSizeByStatusCode:          make(map[string]prometheus.Observer),
 	}
 
 	for _, o := range opts {
@@ -72,15 +93,60 @@ func WithHttpClientId(id string) HttpClientLazyMetricsSetOpt {
 	}
 }
 
+// Assigns the StatusCodePolicy the set is using to decide the value of the "statusCode" label. If not assigned then DefaultStatusCodePolicy is used.
+func WithHttpClientStatusCodePolicy(policy StatusCodePolicy) HttpClientLazyMetricsSetOpt {
+	return func(m *HttpClientLazyMetricsSet) {
+		if policy != nil {
+			m.statusCodePolicy = policy
+		}
+	}
+}
+
+// The "qualifier" of the sets ForContext() gives back is taken from the given label of the context (see kt_observability.WithLabels()) - e.g. the tier of the
+// tenant the request is sent for. If you give allowedValues then the rest of the values are reported as "other" - otherwise the first
+// DefaultContextQualifierMaxValues distinct values are used and the ones coming after them are reported as "other". If the context has no such label the
+// "qualifier" is "-".
+func WithHttpClientContextQualifier(labelName string, allowedValues ...string) HttpClientLazyMetricsSetOpt {
+	return func(m *HttpClientLazyMetricsSet) {
+		m.contextQualifier = newContextQualifier(labelName, allowedValues)
+	}
+}
+
 // Deprecated: use WithHttpClientId() instead!
 func WithClientId(id string) HttpClientLazyMetricsSetOpt {
 	return WithHttpClientId(id)
 }
 
+// Returns the set to use for the given context. If the set was created with WithHttpClientContextQualifier() then this is a set with the same "of",
+// "clientId" and StatusCodePolicy but with the "qualifier" taken from the context - the sets are cached, so you get the same one for the same qualifier (the
+// "qualifier" values are bounded, see WithHttpClientContextQualifier(), so is the number of cached sets). Otherwise you get back this set. The RoundTripper() is doing this with the context of the requests automatically.
+func (m *HttpClientLazyMetricsSet) ForContext(ctx context.Context) *HttpClientLazyMetricsSet {
+	if m.contextQualifier == nil {
+		return m
+	}
+	qualifier := m.contextQualifier.valueFrom(ctx)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	set, found := m.contextSets[qualifier]
+	if !found {
+		set = NewHttpClientLazyMetricsSet(m.of, WithHttpClientQualifier(qualifier), WithHttpClientId(m.clientId), WithHttpClientStatusCodePolicy(m.statusCodePolicy))
+		if m.contextSets == nil {
+			m.contextSets = make(map[string]*HttpClientLazyMetricsSet)
+		}
+		m.contextSets[qualifier] = set
+	}
+	return set
+}
+
 // Invoke when client sent the request - will create+increase counter
 func (m *HttpClientLazyMetricsSet) RequestSent() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	if m.reqSentCounter == nil {
-		c := GetCounterMetricInstance(
+		c := m.created.counter(
 			GetClientRequestSentCountTemplate(),
 			map[string]any{"of": m.of, "protocol": "http", "statusCode": "-", "qualifier": m.qualifier, "clientId": m.clientId},
 		)
@@ -91,11 +157,16 @@ func (m *HttpClientLazyMetricsSet) RequestSent() {
 
 // Invoke when client received a success - pass in the httpStatusCode what was returned. This will create+increase the appropriate success counter.
 // The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
-// send "2xx" to represent anything in 2xx range.
+// send "2xx" to represent anything in 2xx range - or even better: use a StatusCodePolicy (see WithHttpClientStatusCodePolicy()).
 func (m *HttpClientLazyMetricsSet) RequestSucceeded(withHttpStatusCode string) {
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	c, found := m.reqSuccessCounterByStatusCode[withHttpStatusCode]
 	if !found {
-		c = GetCounterMetricInstance(
+		c = m.created.counter(
 			GetClientRequestSucceededCountTemplate(),
 			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": m.qualifier, "clientId": m.clientId},
 		)
@@ -106,11 +177,16 @@ func (m *HttpClientLazyMetricsSet) RequestSucceeded(withHttpStatusCode string) {
 
 // Invoke when client received a failure - pass in the httpStatusCode of the failure. This will create+increase the appropriate failure counter
 // The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
-// send "5xx" to represent anything in 5xx range.
+// send "5xx" to represent anything in 5xx range - or even better: use a StatusCodePolicy (see WithHttpClientStatusCodePolicy()).
 func (m *HttpClientLazyMetricsSet) RequestFailed(withHttpStatusCode string) {
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	c, found := m.reqFailedCounterByStatusCode[withHttpStatusCode]
 	if !found {
-		c = GetCounterMetricInstance(
+		c = m.created.counter(
 			GetClientRequestFailedCountTemplate(),
 			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": m.qualifier, "clientId": m.clientId},
 		)
@@ -123,9 +199,14 @@ func (m *HttpClientLazyMetricsSet) RequestFailed(withHttpStatusCode string) {
 // The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
 // send "2xx" to represent anything in 2xx range.
 func (m *HttpClientLazyMetricsSet) RequestTookMillis(httpStatusCode string, millis float64) {
+	httpStatusCode = m.statusCodePolicy.apply(httpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	c, found := m.reqProcessingTimeByStatusCode[httpStatusCode]
 	if !found {
-		c = GetSummaryMetricInstance(
+		c = m.created.summary(
 			GetClientRequestProcessingTimeTemplate(),
 			map[string]any{"of": m.of, "protocol": "http", "statusCode": httpStatusCode, "qualifier": m.qualifier, "clientId": m.clientId},
 		)
@@ -133,3 +214,125 @@ func (m *HttpClientLazyMetricsSet) RequestTookMillis(httpStatusCode string, mill
 	}
 	c.Observe(millis)
 }
+
+// Track the size of the request payload in bytes - pass in the httpStatusCode so we can collect segregated. This will maintain a Summary.
+// The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
+// send "2xx" to represent anything in 2xx range.
+func (m *HttpClientLazyMetricsSet) RequestSizeBytes(httpStatusCode string, bytes float64) {
+	httpStatusCode = m.statusCodePolicy.apply(httpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, found := m.reqSizeByStatusCode[httpStatusCode]
+	if !found {
+		c = m.created.summary(
+			GetClientRequestSizeTemplate(),
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": httpStatusCode, "qualifier": m.qualifier, "clientId": m.clientId},
+		)
+		m.reqSizeByStatusCode[httpStatusCode] = c
+	}
+	c.Observe(bytes)
+}
+
+// Track the size of the response payload in bytes - pass in the httpStatusCode so we can collect segregated. This will maintain a Summary.
+// The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
+// send "2xx" to represent anything in 2xx range.
+func (m *HttpClientLazyMetricsSet) ResponseSizeBytes(httpStatusCode string, bytes float64) {
+	httpStatusCode = m.statusCodePolicy.apply(httpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, found := m.respSizeByStatusCode[httpStatusCode]
+	if !found {
+		c = m.created.summary(
+			GetClientResponseSizeTemplate(),
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": httpStatusCode, "qualifier": m.qualifier, "clientId": m.clientId},
+		)
+		m.respSizeByStatusCode[httpStatusCode] = c
+	}
+	c.Observe(bytes)
+}
+
+func (m *HttpClientLazyMetricsSet) getInFlightGauge() prometheus.Gauge {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.reqInFlightGauge == nil {
+		g := m.created.gauge(
+			GetClientRequestInFlightTemplate(),
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": "-", "qualifier": m.qualifier, "clientId": m.clientId},
+		)
+		m.reqInFlightGauge = &g
+	}
+	return *m.reqInFlightGauge
+}
+
+// Invoke when client is sending the request - this marks the request sent and increases the number of in flight requests. You get back a token - and once
+// the response arrived just invoke End() on that to record everything else in one go.
+func (m *HttpClientLazyMetricsSet) Begin() *HttpClientInFlightToken {
+	m.RequestSent()
+	inFlight := m.getInFlightGauge()
+	inFlight.Inc()
+	return &HttpClientInFlightToken{metrics: m, inFlight: inFlight, startedAt: time.Now()}
+}
+
+// Deletes all the Metric instances the set has created so far - so they are not exposed anymore. Useful if the client is gone (e.g. it belonged to a tenant or
+// partition which is not served by this instance anymore).
+//
+// You can keep using the set after this - the Metric instances will be lazily created again as you invoke its methods.
+func (m *HttpClientLazyMetricsSet) Forget() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.created.deleteAll()
+	for _, set := range m.contextSets {
+		set.Forget()
+	}
+	m.contextSets = nil
+	m.reqSentCounter = nil
+	m.reqInFlightGauge = nil
+	m.reqSuccessCounterByStatusCode = make(map[string]prometheus.Counter)
+	m.reqProcessingTimeByStatusCode = make(map[string]prometheus.Observer)
+	m.reqFailedCounterByStatusCode = make(map[string]prometheus.Counter)
+	m.reqSizeByStatusCode = make(map[string]prometheus.Observer)
+	m.respSizeByStatusCode = make(map[string]prometheus.Observer)
+}
+
+// You get this back from HttpClientLazyMetricsSet.Begin() - represents a request which is in flight.
+type HttpClientInFlightToken struct {
+	metrics   *HttpClientLazyMetricsSet
+	inFlight  prometheus.Gauge
+	startedAt time.Time
+	ended     sync.Once
+}
+
+// Invoke when the response arrived - pass in the httpStatusCode of the response. This decreases the number of in flight requests, records the time the request
+// took and counts the request as succeeded or failed - 1xx, 2xx and 3xx statusCodes are considered success, anything else is a failure.
+//
+// Only the first invocation does anything - so it is safe to invoke it from a defer as well.
+func (t *HttpClientInFlightToken) End(withHttpStatusCode string) {
+	t.ended.Do(func() {
+		t.inFlight.Dec()
+		t.metrics.RequestTookMillis(withHttpStatusCode, float64(time.Since(t.startedAt))/float64(time.Millisecond))
+		if isSuccessHttpStatusCode(withHttpStatusCode) {
+			t.metrics.RequestSucceeded(withHttpStatusCode)
+		} else {
+			t.metrics.RequestFailed(withHttpStatusCode)
+		}
+	})
+}
+
+// 1xx, 2xx and 3xx codes (also in "2xx" like form) are considered success
+func isSuccessHttpStatusCode(httpStatusCode string) bool {
+	if httpStatusCode == "" {
+		return false
+	}
+	switch httpStatusCode[0] {
+	case '1', '2', '3':
+		return true
+	default:
+		return false
+	}
+}
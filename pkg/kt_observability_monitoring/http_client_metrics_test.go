@@ -0,0 +1,86 @@
+package kt_observability_monitoring
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+)
+
+func tierContext(tier string) context.Context {
+	return kt_observability.WithLabels(context.Background(), map[string]any{"tier": tier})
+}
+
+func TestForContextWithoutContextQualifier(t *testing.T) {
+	set := NewHttpClientLazyMetricsSet("forContextNoQualifierTest")
+	if set.ForContext(tierContext("gold")) != set {
+		t.Errorf("without a context qualifier the set itself must be returned")
+	}
+}
+
+func TestForContextWithAllowedValues(t *testing.T) {
+	InitMetrics()
+	set := NewHttpClientLazyMetricsSet("forContextAllowedTest", WithHttpClientContextQualifier("tier", "gold", "silver"), WithHttpClientId("c1"))
+
+	gold := set.ForContext(tierContext("gold"))
+	if gold != set.ForContext(tierContext("gold")) {
+		t.Errorf("expected the cached set for the same qualifier")
+	}
+	gold.RequestSent()
+	set.ForContext(tierContext("bronze")).RequestSent()
+	set.ForContext(tierContext("platinum")).RequestSent()
+	set.ForContext(context.Background()).RequestSent()
+
+	for qualifier, want := range map[string]float64{"gold": 1, OtherLabelValue: 2, "-": 1, "bronze": 0, "platinum": 0} {
+		labels := map[string]string{"of": "forContextAllowedTest", "clientId": "c1", "qualifier": qualifier}
+		if got := metricValue(t, GetClientRequestSentCountTemplate(), labels); got != want {
+			t.Errorf("qualifier %v: expected %v sent requests, got %v", qualifier, want, got)
+		}
+	}
+	if len(set.contextSets) != 3 {
+		t.Errorf("expected 3 cached sets (gold, other, -), got %v", len(set.contextSets))
+	}
+}
+
+func TestForContextWithoutAllowedValuesIsBounded(t *testing.T) {
+	InitMetrics()
+	defaultMaxValues := DefaultContextQualifierMaxValues
+	DefaultContextQualifierMaxValues = 2
+	defer func() { DefaultContextQualifierMaxValues = defaultMaxValues }()
+	set := NewHttpClientLazyMetricsSet("forContextBoundedTest", WithHttpClientContextQualifier("tier"))
+
+	for _, tier := range []string{"t1", "t2", "t3", "t4", "t1", "t5"} {
+		set.ForContext(tierContext(tier)).RequestSent()
+	}
+
+	for qualifier, want := range map[string]float64{"t1": 2, "t2": 1, OtherLabelValue: 3, "t3": 0} {
+		labels := map[string]string{"of": "forContextBoundedTest", "qualifier": qualifier}
+		if got := metricValue(t, GetClientRequestSentCountTemplate(), labels); got != want {
+			t.Errorf("qualifier %v: expected %v sent requests, got %v", qualifier, want, got)
+		}
+	}
+	if len(set.contextSets) != 3 {
+		t.Errorf("expected 3 cached sets (t1, t2, other), got %v", len(set.contextSets))
+	}
+}
+
+func TestRoundTripperTakesTheQualifierFromTheContext(t *testing.T) {
+	InitMetrics()
+	set := NewHttpClientLazyMetricsSet("forContextRoundTripperTest", WithHttpClientContextQualifier("tier", "gold"))
+	client := &http.Client{Transport: set.RoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}))}
+
+	req, _ := http.NewRequestWithContext(tierContext("gold"), "GET", "http://example.invalid/", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	labels := map[string]string{"of": "forContextRoundTripperTest", "qualifier": "gold", "statusCode": "200"}
+	if got := metricValue(t, GetClientRequestSucceededCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 succeeded request with the gold qualifier, got %v", got)
+	}
+}
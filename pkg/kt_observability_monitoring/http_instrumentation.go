@@ -0,0 +1,301 @@
+package kt_observability_monitoring
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Wraps your HTTP handler so every request is recorded in the set automatically - started / in flight / succeeded / failed counts, serving time and also
+// request and response payload sizes.
+//
+// The request size is taken from the Content-Length header if that is present - otherwise the bytes the handler read from the request body are counted. The
+// response size is the number of bytes the handler wrote. If the handler panics the request is recorded as failed with "500" statusCode and the panic goes on.
+//
+// The writer the handler gets supports http.Flusher, http.Hijacker and io.ReaderFrom only if the original writer does - so the handler sees the same
+// capabilities as without the wrapping.
+func (m *HttpServerLazyMetricsSet) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		inFlight := m.Begin(req)
+
+		var body *countingReadCloser
+		if req.Body != nil && req.Body != http.NoBody {
+			body = &countingReadCloser{ReadCloser: req.Body}
+			req.Body = body
+		}
+		writer := &countingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		defer func() {
+			recovered := recover()
+			if recovered != nil {
+				// whatever was written so far - the request did not complete
+				writer.statusCode = http.StatusInternalServerError
+			}
+			statusCode := strconv.Itoa(writer.statusCode)
+			reqSize := req.ContentLength
+			if reqSize < 0 {
+				reqSize = 0
+				if body != nil {
+					reqSize = body.bytes.Load()
+				}
+			}
+			m.ServeRequestSizeBytes(req, statusCode, float64(reqSize))
+			m.ServeResponseSizeBytes(req, statusCode, float64(writer.bytes))
+			inFlight.End(statusCode)
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+
+		next.ServeHTTP(writer.withCapabilitiesOf(w), req)
+	})
+}
+
+// Wraps the given http.RoundTripper (nil means http.DefaultTransport) so every request sent through it is recorded in the set automatically - sent / in
+// flight / succeeded / failed counts, request-response time and also request and response payload sizes.
+//
+// If the request fails with a transport error (no response at all) it is recorded as failed with "-" statusCode. The payload sizes are taken from the
+// Content-Length if that is known - otherwise the bytes going through the bodies are counted. In this latter case the response size is recorded once the
+// response body is read until the end or closed. If the set was created with WithHttpClientContextQualifier() then the "qualifier" is taken from the context
+// of the request.
+func (m *HttpClientLazyMetricsSet) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return httpClientMetricsRoundTripper{metrics: m, next: next}
+}
+
+type httpClientMetricsRoundTripper struct {
+	metrics *HttpClientLazyMetricsSet
+	next    http.RoundTripper
+}
+
+func (rt httpClientMetricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	metrics := rt.metrics.ForContext(req.Context())
+	inFlight := metrics.Begin()
+
+	var reqSize *requestSizeRecorder
+	// note: for outgoing requests 0 Content-Length with a body also means "unknown"
+	if req.ContentLength <= 0 && req.Body != nil && req.Body != http.NoBody {
+		reqSize = &requestSizeRecorder{metrics: metrics}
+		// RoundTrippers must not modify the request - so we work on a copy
+		req = req.Clone(req.Context())
+		// the transport might still be sending the body when the response arrives - it closes the body once it is done with it
+		req.Body = &countingReadCloser{ReadCloser: req.Body, onDone: reqSize.bodyDone, onCloseOnly: true}
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		inFlight.End("-")
+		return resp, err
+	}
+
+	statusCode := strconv.Itoa(resp.StatusCode)
+	if reqSize != nil {
+		reqSize.responded(statusCode)
+	} else {
+		metrics.RequestSizeBytes(statusCode, float64(max(req.ContentLength, 0)))
+	}
+
+	if resp.ContentLength >= 0 || resp.Body == nil {
+		metrics.ResponseSizeBytes(statusCode, float64(max(resp.ContentLength, 0)))
+	} else {
+		respBody := &countingReadCloser{ReadCloser: resp.Body}
+		respBody.onDone = func(bytes int64) {
+			metrics.ResponseSizeBytes(statusCode, float64(bytes))
+		}
+		resp.Body = respBody
+	}
+
+	inFlight.End(statusCode)
+	return resp, nil
+}
+
+// records the size of a request body once both the statusCode is known and the transport has closed the body - whichever comes later
+type requestSizeRecorder struct {
+	metrics *HttpClientLazyMetricsSet
+
+	lock       sync.Mutex
+	statusCode string
+	bytes      int64
+	bodyClosed bool
+}
+
+func (r *requestSizeRecorder) bodyDone(bytes int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.bytes = bytes
+	r.bodyClosed = true
+	if r.statusCode != "" {
+		r.metrics.RequestSizeBytes(r.statusCode, float64(r.bytes))
+	}
+}
+
+func (r *requestSizeRecorder) responded(statusCode string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.statusCode = statusCode
+	if r.bodyClosed {
+		r.metrics.RequestSizeBytes(r.statusCode, float64(r.bytes))
+	}
+}
+
+// counts the bytes read through it - and optionally reports the count once (on EOF or Close, whichever comes first - or only on Close if onCloseOnly)
+type countingReadCloser struct {
+	io.ReadCloser
+	bytes       atomic.Int64
+	onDone      func(bytes int64)
+	onCloseOnly bool
+	done        sync.Once
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.bytes.Add(int64(n))
+	if err == io.EOF && !r.onCloseOnly {
+		r.finish()
+	}
+	return n, err
+}
+
+func (r *countingReadCloser) Close() error {
+	r.finish()
+	return r.ReadCloser.Close()
+}
+
+func (r *countingReadCloser) finish() {
+	if r.onDone != nil {
+		r.done.Do(func() {
+			r.onDone(r.bytes.Load())
+		})
+	}
+}
+
+// counts the bytes written and remembers the statusCode
+type countingResponseWriter struct {
+	http.ResponseWriter
+	statusCode    int
+	headerWritten bool
+	bytes         int64
+}
+
+func (w *countingResponseWriter) WriteHeader(statusCode int) {
+	if !w.headerWritten {
+		w.statusCode = statusCode
+		w.headerWritten = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	w.headerWritten = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *countingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// so websocket upgrades and similar keep working - once the connection is hijacked we do not see what is written to it, the statusCode is recorded as "101"
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the wrapped http.ResponseWriter (%T) does not support hijacking", w.ResponseWriter)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && !w.headerWritten {
+		w.statusCode = http.StatusSwitchingProtocols
+		w.headerWritten = true
+	}
+	return conn, rw, err
+}
+
+// so io.Copy() into the writer (e.g. http.ServeContent()) can still use the sendfile / splice optimizations of the wrapped writer
+func (w *countingResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.headerWritten = true
+	var n int64
+	var err error
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(r)
+	} else {
+		// hiding our ReadFrom - otherwise io.Copy() would come back here
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// so http.ResponseController can reach the original writer
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type responseWriterUnwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// returns the writer exposing only those optional interfaces (http.Flusher, http.Hijacker, io.ReaderFrom) the original writer implements - so type
+// assertions on it give the same answers as on the original
+func (w *countingResponseWriter) withCapabilitiesOf(original http.ResponseWriter) http.ResponseWriter {
+	_, isFlusher := original.(http.Flusher)
+	_, isHijacker := original.(http.Hijacker)
+	_, isReaderFrom := original.(io.ReaderFrom)
+
+	type base interface {
+		http.ResponseWriter
+		responseWriterUnwrapper
+	}
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			base
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w, w}
+	case isFlusher && isHijacker:
+		return struct {
+			base
+			http.Flusher
+			http.Hijacker
+		}{w, w, w}
+	case isFlusher && isReaderFrom:
+		return struct {
+			base
+			http.Flusher
+			io.ReaderFrom
+		}{w, w, w}
+	case isHijacker && isReaderFrom:
+		return struct {
+			base
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w}
+	case isFlusher:
+		return struct {
+			base
+			http.Flusher
+		}{w, w}
+	case isHijacker:
+		return struct {
+			base
+			http.Hijacker
+		}{w, w}
+	case isReaderFrom:
+		return struct {
+			base
+			io.ReaderFrom
+		}{w, w}
+	default:
+		return struct{ base }{w}
+	}
+}
@@ -0,0 +1,229 @@
+package kt_observability_monitoring
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// some values are recorded asynchronously (e.g. once the transport closes the request body) - so we give them some time
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandlerRecordsTheRequest(t *testing.T) {
+	InitMetrics()
+	set := NewHttpServerLazyMetricsSet("handlerTest")
+	handler := set.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.ReadAll(req.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("abc")))
+
+	labels := map[string]string{"of": "handlerTest", "statusCode": "201"}
+	if got := metricValue(t, GetServerServeSucceededCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 succeeded request, got %v", got)
+	}
+	if got := summarySum(t, GetServerServeRequestSizeTemplate(), labels); got != 3 {
+		t.Errorf("expected 3 bytes request size, got %v", got)
+	}
+	if got := summarySum(t, GetServerServeResponseSizeTemplate(), labels); got != 5 {
+		t.Errorf("expected 5 bytes response size, got %v", got)
+	}
+	if got := metricValue(t, GetServerServeInFlightTemplate(), map[string]string{"of": "handlerTest"}); got != 0 {
+		t.Errorf("expected no request in flight, got %v", got)
+	}
+}
+
+func TestHandlerRecordsPanicAsFailure(t *testing.T) {
+	InitMetrics()
+	set := NewHttpServerLazyMetricsSet("handlerPanicTest")
+	handler := set.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Errorf("the panic must go on - got %v", recovered)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if got := metricValue(t, GetServerServeFailedCountTemplate(), map[string]string{"of": "handlerPanicTest", "statusCode": "500"}); got != 1 {
+		t.Errorf("expected 1 failed request with 500, got %v", got)
+	}
+	if got := metricValue(t, GetServerServeSucceededCountTemplate(), map[string]string{"of": "handlerPanicTest"}); got != 0 {
+		t.Errorf("expected no succeeded request, got %v", got)
+	}
+}
+
+func TestHandlerKeepsTheCapabilitiesOfTheWriter(t *testing.T) {
+	InitMetrics()
+	set := NewHttpServerLazyMetricsSet("handlerCapabilitiesTest")
+	type capabilities struct{ flusher, hijacker, readerFrom bool }
+	var got capabilities
+	handler := set.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, got.flusher = w.(http.Flusher)
+		_, got.hijacker = w.(http.Hijacker)
+		_, got.readerFrom = w.(io.ReaderFrom)
+	}))
+
+	// the recorder is a Flusher only
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if want := (capabilities{flusher: true}); got != want {
+		t.Errorf("with a recorder expected %+v, got %+v", want, got)
+	}
+
+	// the writer of net/http has all of them
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if want := (capabilities{flusher: true, hijacker: true, readerFrom: true}); got != want {
+		t.Errorf("with a net/http writer expected %+v, got %+v", want, got)
+	}
+}
+
+func TestHandlerSupportsHijacking(t *testing.T) {
+	InitMetrics()
+	set := NewHttpServerLazyMetricsSet("handlerHijackTest")
+	server := httptest.NewServer(set.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected 101, got %v", resp.StatusCode)
+	}
+
+	eventually(t, "the hijacked request to be recorded", func() bool {
+		return metricValue(t, GetServerServeSucceededCountTemplate(), map[string]string{"of": "handlerHijackTest", "statusCode": "101"}) == 1
+	})
+}
+
+func TestHandlerCountsTheBytesOfReadFrom(t *testing.T) {
+	InitMetrics()
+	set := NewHttpServerLazyMetricsSet("handlerReadFromTest")
+	server := httptest.NewServer(set.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// hiding the WriterTo of the reader - so io.Copy() goes through the ReadFrom of the writer
+		io.Copy(w, struct{ io.Reader }{strings.NewReader("payload")})
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "payload" {
+		t.Errorf("expected 'payload', got %q", body)
+	}
+
+	labels := map[string]string{"of": "handlerReadFromTest", "statusCode": "200"}
+	eventually(t, "the request to be recorded", func() bool {
+		return metricValue(t, GetServerServeResponseSizeTemplate(), labels) == 1
+	})
+	if got := summarySum(t, GetServerServeResponseSizeTemplate(), labels); got != 7 {
+		t.Errorf("expected 7 bytes response size, got %v", got)
+	}
+}
+
+func TestRoundTripperRecordsTheRequest(t *testing.T) {
+	InitMetrics()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.ReadAll(req.Body)
+		w.WriteHeader(http.StatusAccepted)
+		// flushing before writing the body - so the response has no Content-Length
+		w.(http.Flusher).Flush()
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	set := NewHttpClientLazyMetricsSet("roundTripperTest")
+	client := &http.Client{Transport: set.RoundTripper(nil)}
+	// the length of the body is not known - so it is counted
+	req, _ := http.NewRequest("POST", server.URL, io.NopCloser(strings.NewReader("abcd")))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	labels := map[string]string{"of": "roundTripperTest", "statusCode": "202"}
+	if got := metricValue(t, GetClientRequestSucceededCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 succeeded request, got %v", got)
+	}
+	if got := summarySum(t, GetClientResponseSizeTemplate(), labels); got != 5 {
+		t.Errorf("expected 5 bytes response size, got %v", got)
+	}
+	eventually(t, "the request size to be recorded", func() bool {
+		return metricValue(t, GetClientRequestSizeTemplate(), labels) == 1
+	})
+	if got := summarySum(t, GetClientRequestSizeTemplate(), labels); got != 4 {
+		t.Errorf("expected 4 bytes request size, got %v", got)
+	}
+	if got := metricValue(t, GetClientRequestInFlightTemplate(), map[string]string{"of": "roundTripperTest"}); got != 0 {
+		t.Errorf("expected no request in flight, got %v", got)
+	}
+}
+
+func TestRoundTripperRecordsTransportErrors(t *testing.T) {
+	InitMetrics()
+	set := NewHttpClientLazyMetricsSet("roundTripperErrorTest")
+	transportErr := errors.New("connection refused")
+	client := &http.Client{Transport: set.RoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, transportErr
+	}))}
+
+	if _, err := client.Get("http://example.invalid/"); !errors.Is(err, transportErr) {
+		t.Errorf("expected the transport error, got %v", err)
+	}
+	if got := metricValue(t, GetClientRequestFailedCountTemplate(), map[string]string{"of": "roundTripperErrorTest", "statusCode": "-"}); got != 1 {
+		t.Errorf("expected 1 failed request with '-', got %v", got)
+	}
+}
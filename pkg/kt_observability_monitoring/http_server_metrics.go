@@ -2,6 +2,8 @@ package kt_observability_monitoring
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -12,14 +14,26 @@ import (
 // is why it is "lazy". You can track how many times requests were sent, and how many times they succeeded / failed. You also have the possibility to track
 // Request-Response loop times AND you can do it
 // per each HttpSatatus codes/ Methods which brings pretty good observability just out of the box.
+//
+// The methods are safe for concurrent use.
 type HttpServerLazyMetricsSet struct {
 	of       string
 	serverId string
 
+	statusCodePolicy StatusCodePolicy
+	// if set then the "qualifier" is taken from the context of the request - otherwise it is the method of the request
+	contextQualifier *contextQualifier
+
+	lock    sync.Mutex
+	created createdMetricInstances
+
 	serveStartedCounter             map[string]prometheus.Counter
+	serveInFlightGauge              map[string]prometheus.Gauge
 	serveSuccessCounterByStatusCode map[string]prometheus.Counter
 	serveProcessingTimeByStatusCode map[string]prometheus.Observer
 	serveFailedCounterByStatusCode  map[string]prometheus.Counter
+	serveReqSizeByStatusCode        map[string]prometheus.Observer
+	serveRespSizeByStatusCode       map[string]prometheus.Observer
 }
 
 type HttpServerLazyMetricsSetOpt func(m *HttpServerLazyMetricsSet)
@@ -35,10 +49,14 @@ func NewHttpServerLazyMetricsSet(of string, opts ...HttpServerLazyMetricsSetOpt)
 	metrics := HttpServerLazyMetricsSet{
 		of:                              of,
 		serverId:                        "-",
+		statusCodePolicy:                DefaultStatusCodePolicy,
 		serveStartedCounter:             make(map[string]prometheus.Counter),
+		serveInFlightGauge:              make(map[string]prometheus.Gauge),
 		serveSuccessCounterByStatusCode: make(map[string]prometheus.Counter),
 		serveProcessingTimeByStatusCode: make(map[string]prometheus.Observer),
 		serveFailedCounterByStatusCode:  make(map[string]prometheus.Counter),
+		serveReqSizeByStatusCode:        make(map[string]prometheus.Observer),
+		serveRespSizeByStatusCode:       make(map[string]prometheus.Observer),
 	}
 
 	for _, o := range opts {
@@ -57,6 +75,25 @@ func WithHttpServerId(id string) HttpServerLazyMetricsSetOpt {
 	}
 }
 
+// Assigns the StatusCodePolicy the set is using to decide the value of the "statusCode" label. If not assigned then DefaultStatusCodePolicy is used.
+func WithHttpServerStatusCodePolicy(policy StatusCodePolicy) HttpServerLazyMetricsSetOpt {
+	return func(m *HttpServerLazyMetricsSet) {
+		if policy != nil {
+			m.statusCodePolicy = policy
+		}
+	}
+}
+
+// The "qualifier" is taken from the given label of the request context (see kt_observability.WithLabels()) instead of the method of the request - e.g. the
+// tier of the tenant a middleware before this set has put into the context. If you give allowedValues then the rest of the values are reported as "other" -
+// otherwise the first DefaultContextQualifierMaxValues distinct values are used and the ones coming after them are reported as "other". If the context has
+// no such label the "qualifier" is "-".
+func WithHttpServerContextQualifier(labelName string, allowedValues ...string) HttpServerLazyMetricsSetOpt {
+	return func(m *HttpServerLazyMetricsSet) {
+		m.contextQualifier = newContextQualifier(labelName, allowedValues)
+	}
+}
+
 func getReqMethod(req *http.Request) string {
 	if req == nil {
 		return "-"
@@ -64,31 +101,50 @@ func getReqMethod(req *http.Request) string {
 	return req.Method
 }
 
+// the value of the "qualifier" label for the request
+func (m *HttpServerLazyMetricsSet) qualifierOf(req *http.Request) string {
+	if m.contextQualifier == nil {
+		return getReqMethod(req)
+	}
+	if req == nil {
+		return "-"
+	}
+	return m.contextQualifier.valueFrom(req.Context())
+}
+
 // Invoke when server started to process the request - will create+increase counter
 func (m *HttpServerLazyMetricsSet) ServeStarted(req *http.Request) {
-	method := getReqMethod(req)
-	c, found := m.serveStartedCounter[method]
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	c, found := m.serveStartedCounter[qualifier]
 	if !found {
-		c = GetCounterMetricInstance(
+		c = m.created.counter(
 			GetServerServeStartedCountTemplate(),
-			map[string]any{"of": m.of, "protocol": "http", "statusCode": "-", "qualifier": method, "serverId": m.serverId},
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": "-", "qualifier": qualifier, "serverId": m.serverId},
 		)
-		m.serveStartedCounter[method] = c
+		m.serveStartedCounter[qualifier] = c
 	}
 	c.Inc()
 }
 
 // Invoke when server successfully served the request - pass in the httpStatusCode was returned to client. This will create+increase the appropriate success
 // counter. The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say
-// you can send "2xx" to represent anything in 2xx range.
+// you can send "2xx" to represent anything in 2xx range - or even better: use a StatusCodePolicy (see WithHttpServerStatusCodePolicy()).
 func (m *HttpServerLazyMetricsSet) ServeSucceeded(req *http.Request, withHttpStatusCode string) {
-	method := getReqMethod(req)
-	key := method + withHttpStatusCode
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	key := qualifier + withHttpStatusCode
 	c, found := m.serveSuccessCounterByStatusCode[key]
 	if !found {
-		c = GetCounterMetricInstance(
+		c = m.created.counter(
 			GetServerServeSucceededCountTemplate(),
-			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": method, "serverId": m.serverId},
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": qualifier, "serverId": m.serverId},
 		)
 		m.serveSuccessCounterByStatusCode[key] = c
 	}
@@ -97,15 +153,20 @@ func (m *HttpServerLazyMetricsSet) ServeSucceeded(req *http.Request, withHttpSta
 
 // Invoke when server failed to server the request - pass in the httpStatusCode was returned to client. This will create+increase the appropriate failure
 // counter The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say
-// you can send "5xx" to represent anything in 5xx range.
+// you can send "5xx" to represent anything in 5xx range - or even better: use a StatusCodePolicy (see WithHttpServerStatusCodePolicy()).
 func (m *HttpServerLazyMetricsSet) ServeFailed(req *http.Request, withHttpStatusCode string) {
-	method := getReqMethod(req)
-	key := method + withHttpStatusCode
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	key := qualifier + withHttpStatusCode
 	c, found := m.serveFailedCounterByStatusCode[key]
 	if !found {
-		c = GetCounterMetricInstance(
+		c = m.created.counter(
 			GetServerServeFailedCountTemplate(),
-			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": method, "serverId": m.serverId},
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": qualifier, "serverId": m.serverId},
 		)
 		m.serveFailedCounterByStatusCode[key] = c
 	}
@@ -116,15 +177,132 @@ func (m *HttpServerLazyMetricsSet) ServeFailed(req *http.Request, withHttpStatus
 // The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
 // send "2xx" to represent anything in 2xx range.
 func (m *HttpServerLazyMetricsSet) ServeTookMillis(req *http.Request, withHttpStatusCode string, millis float64) {
-	method := getReqMethod(req)
-	key := method + withHttpStatusCode
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	key := qualifier + withHttpStatusCode
 	c, found := m.serveProcessingTimeByStatusCode[key]
 	if !found {
-		c = GetSummaryMetricInstance(
+		c = m.created.summary(
 			GetServerServeProcessingTimeTemplate(),
-			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": method, "serverId": m.serverId},
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": qualifier, "serverId": m.serverId},
 		)
 		m.serveProcessingTimeByStatusCode[key] = c
 	}
 	c.Observe(millis)
 }
+
+// Track the size of the request payload in bytes - pass in the httpStatusCode was returned to client so we can collect segregated. This will maintain a Summary.
+// The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say you can
+// send "2xx" to represent anything in 2xx range.
+func (m *HttpServerLazyMetricsSet) ServeRequestSizeBytes(req *http.Request, withHttpStatusCode string, bytes float64) {
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	key := qualifier + withHttpStatusCode
+	c, found := m.serveReqSizeByStatusCode[key]
+	if !found {
+		c = m.created.summary(
+			GetServerServeRequestSizeTemplate(),
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": qualifier, "serverId": m.serverId},
+		)
+		m.serveReqSizeByStatusCode[key] = c
+	}
+	c.Observe(bytes)
+}
+
+// Track the size of the response payload in bytes - pass in the httpStatusCode was returned to client so we can collect segregated. This will maintain a
+// Summary. The statusCode is taken as a string although normally it is int. Reason: this way if you do not want to distinguish fully just by ranges let's say
+// you can send "2xx" to represent anything in 2xx range.
+func (m *HttpServerLazyMetricsSet) ServeResponseSizeBytes(req *http.Request, withHttpStatusCode string, bytes float64) {
+	withHttpStatusCode = m.statusCodePolicy.apply(withHttpStatusCode)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	key := qualifier + withHttpStatusCode
+	c, found := m.serveRespSizeByStatusCode[key]
+	if !found {
+		c = m.created.summary(
+			GetServerServeResponseSizeTemplate(),
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": withHttpStatusCode, "qualifier": qualifier, "serverId": m.serverId},
+		)
+		m.serveRespSizeByStatusCode[key] = c
+	}
+	c.Observe(bytes)
+}
+
+func (m *HttpServerLazyMetricsSet) getInFlightGauge(req *http.Request) prometheus.Gauge {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	qualifier := m.qualifierOf(req)
+	g, found := m.serveInFlightGauge[qualifier]
+	if !found {
+		g = m.created.gauge(
+			GetServerServeInFlightTemplate(),
+			map[string]any{"of": m.of, "protocol": "http", "statusCode": "-", "qualifier": qualifier, "serverId": m.serverId},
+		)
+		m.serveInFlightGauge[qualifier] = g
+	}
+	return g
+}
+
+// Invoke when server started to process the request - this marks the serving started and increases the number of in flight requests. You get back a token -
+// and once you are done with serving just invoke End() on that to record everything else in one go.
+func (m *HttpServerLazyMetricsSet) Begin(req *http.Request) *HttpServerInFlightToken {
+	m.ServeStarted(req)
+	inFlight := m.getInFlightGauge(req)
+	inFlight.Inc()
+	return &HttpServerInFlightToken{metrics: m, req: req, inFlight: inFlight, startedAt: time.Now()}
+}
+
+// Deletes all the Metric instances the set has created so far - so they are not exposed anymore. Useful if the endpoint is gone (e.g. it belonged to a tenant
+// or partition which is not served by this instance anymore).
+//
+// You can keep using the set after this - the Metric instances will be lazily created again as you invoke its methods.
+func (m *HttpServerLazyMetricsSet) Forget() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.created.deleteAll()
+	m.serveStartedCounter = make(map[string]prometheus.Counter)
+	m.serveInFlightGauge = make(map[string]prometheus.Gauge)
+	m.serveSuccessCounterByStatusCode = make(map[string]prometheus.Counter)
+	m.serveProcessingTimeByStatusCode = make(map[string]prometheus.Observer)
+	m.serveFailedCounterByStatusCode = make(map[string]prometheus.Counter)
+	m.serveReqSizeByStatusCode = make(map[string]prometheus.Observer)
+	m.serveRespSizeByStatusCode = make(map[string]prometheus.Observer)
+}
+
+// You get this back from HttpServerLazyMetricsSet.Begin() - represents a request which is being served.
+type HttpServerInFlightToken struct {
+	metrics   *HttpServerLazyMetricsSet
+	req       *http.Request
+	inFlight  prometheus.Gauge
+	startedAt time.Time
+	ended     sync.Once
+}
+
+// Invoke when serving the request is done - pass in the httpStatusCode returned to the client. This decreases the number of in flight requests, records the
+// time serving took and counts the request as succeeded or failed - 1xx, 2xx and 3xx statusCodes are considered success, anything else is a failure.
+//
+// Only the first invocation does anything - so it is safe to invoke it from a defer as well.
+func (t *HttpServerInFlightToken) End(withHttpStatusCode string) {
+	t.ended.Do(func() {
+		t.inFlight.Dec()
+		t.metrics.ServeTookMillis(t.req, withHttpStatusCode, float64(time.Since(t.startedAt))/float64(time.Millisecond))
+		if isSuccessHttpStatusCode(withHttpStatusCode) {
+			t.metrics.ServeSucceeded(t.req, withHttpStatusCode)
+		} else {
+			t.metrics.ServeFailed(t.req, withHttpStatusCode)
+		}
+	})
+}
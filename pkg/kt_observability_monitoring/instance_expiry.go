@@ -0,0 +1,419 @@
+package kt_observability_monitoring
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func newMetricInstanceEntry(labels prometheus.Labels) *metricInstanceEntry {
+	entry := &metricInstanceEntry{labels: labels}
+	entry.touch()
+	return entry
+}
+
+func (e *metricInstanceEntry) touch() {
+	e.lastTouched.Store(time.Now().UnixNano())
+}
+
+// lock must be held!
+func (s *metricTemplateState) addInstance(key string, labels prometheus.Labels) *metricInstanceEntry {
+	entry := newMetricInstanceEntry(labels)
+	s.instances[key] = entry
+	return entry
+}
+
+// Sets the time to live of the Metric instances (label value combinations) of this template. Instances which were not touched (not used) for this long are
+// deleted - so they disappear from the exposed Metrics. Pass in 0 (this is the default) if instances should live forever.
+//
+// This is useful if your label values are coming and going - e.g. per tenant or per partition Metrics - and you do not want to expose the series forever.
+//
+// Please note: set the TTL before you create instances of the template! Instances you got back from Get*MetricInstance() methods before the TTL was set do
+// not report when they are used - so they will expire even if they are still in use.
+func (tpl *MetricTemplate) SetInstanceTTL(ttl time.Duration) {
+	if tpl.state == nil {
+		return
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+
+	tpl.state.instanceTTL = ttl
+	tpl.state.updateTracksInstances()
+	// the sweep interval depends on the TTL - so we start a new goroutine with the new TTL
+	tpl.state.stopSweeper()
+	if ttl > 0 {
+		tpl.state.stopSweeping = make(chan struct{})
+		go tpl.sweepExpiredInstances(ttl, tpl.state.stopSweeping)
+
+		sweepingTemplatesLock.Lock()
+		sweepingTemplates[tpl.state] = true
+		sweepingTemplatesLock.Unlock()
+	}
+}
+
+var (
+	// the templates having a goroutine deleting their expired instances - so forgetMetricTemplates() can stop them all
+	sweepingTemplates     = make(map[*metricTemplateState]bool)
+	sweepingTemplatesLock sync.Mutex
+)
+
+// lock must be held!
+func (s *metricTemplateState) stopSweeper() {
+	if s.stopSweeping == nil {
+		return
+	}
+	close(s.stopSweeping)
+	s.stopSweeping = nil
+
+	sweepingTemplatesLock.Lock()
+	delete(sweepingTemplates, s)
+	sweepingTemplatesLock.Unlock()
+}
+
+// stops the goroutines deleting the expired instances of all templates - the TTLs of the templates remain but the instances do not expire anymore
+func stopAllSweepers() {
+	sweepingTemplatesLock.Lock()
+	states := sweepingTemplates
+	sweepingTemplates = make(map[*metricTemplateState]bool)
+	sweepingTemplatesLock.Unlock()
+
+	for state := range states {
+		state.lock.Lock()
+		state.stopSweeper()
+		state.lock.Unlock()
+	}
+}
+
+// Returns the time to live of the Metric instances of this template - 0 means they live forever
+func (tpl *MetricTemplate) InstanceTTL() time.Duration {
+	if tpl.state == nil {
+		return 0
+	}
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+	return tpl.state.instanceTTL
+}
+
+// runs until the stop channel is closed - see stopSweeper()
+func (tpl MetricTemplate) sweepExpiredInstances(ttl time.Duration, stop <-chan struct{}) {
+	// we check a few times within the TTL - but not too often and not too rarely
+	ticker := time.NewTicker(min(max(ttl/4, time.Second), time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			tpl.deleteInstancesNotTouchedSince(time.Now().Add(-ttl))
+		}
+	}
+}
+
+func (tpl *MetricTemplate) deleteInstancesNotTouchedSince(cutoff time.Time) {
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+
+	cutoffNanos := cutoff.UnixNano()
+	for key, entry := range tpl.state.instances {
+		if entry.lastTouched.Load() < cutoffNanos {
+			delete(tpl.state.instances, key)
+			tpl.deleteSeries(entry)
+		}
+	}
+	if tpl.state.overflow != nil && tpl.state.overflow.lastTouched.Load() < cutoffNanos {
+		tpl.deleteSeries(tpl.state.overflow)
+		tpl.state.overflow = nil
+	}
+	tpl._LOGGER.Debug("%v: expired instances deleted - %d instances remained", tpl.ToString(), len(tpl.state.instances))
+}
+
+func (tpl *MetricTemplate) deleteSeries(entry *metricInstanceEntry) bool {
+	entry.isDeleted.Store(true)
+	switch tpl.metricType {
+	case "summary":
+		return tpl.summaryVec.Delete(entry.labels)
+	case "counter":
+		return tpl.counterVec.Delete(entry.labels)
+	case "gauge":
+		return tpl.gaugeVec.Delete(entry.labels)
+	case "histogram":
+		return tpl.histogramVec.Delete(entry.labels)
+	default:
+		return false
+	}
+}
+
+// Deletes a concrete instance of the template - the one you have created with the same customLabels. After this the instance is not exposed anymore (until you
+// create it again). Returns TRUE if the instance existed and was deleted.
+func DeleteMetricInstance(metricTemplate MetricTemplate, customLabels map[string]any) bool {
+	if metricTemplate.state == nil {
+		return false
+	}
+	customLabels["metricType"] = metricTemplate.metricType
+	labels := metricTemplate.foldNotAllowedLabelValues(BuildMetricLabels(customLabels))
+	key := metricTemplate.instanceKey(labels)
+
+	metricTemplate.state.lock.Lock()
+	defer metricTemplate.state.lock.Unlock()
+
+	entry, found := metricTemplate.state.instances[key]
+	if !found {
+		entry = &metricInstanceEntry{labels: labels}
+	}
+	delete(metricTemplate.state.instances, key)
+	return metricTemplate.deleteSeries(entry)
+}
+
+// lock must NOT be held! Returns the entry of the label value combination - adds it again if it was deleted meanwhile (not minding the cardinality limit as it
+// was accepted before)
+func (tpl *MetricTemplate) reviveInstance(labels prometheus.Labels) *metricInstanceEntry {
+	key := tpl.instanceKey(labels)
+
+	tpl.state.lock.Lock()
+	defer tpl.state.lock.Unlock()
+
+	if entry, found := tpl.state.instances[key]; found {
+		entry.touch()
+		return entry
+	}
+	if key == tpl.instanceKey(overflowLabelsOf(labels)) {
+		// this is the overflow series - that does not count into the limit
+		if tpl.state.overflow == nil {
+			tpl.state.overflow = newMetricInstanceEntry(labels)
+		}
+		tpl.state.overflow.touch()
+		return tpl.state.overflow
+	}
+	return tpl.state.addInstance(key, labels)
+}
+
+func (tpl *MetricTemplate) instanceMetric(labels prometheus.Labels) prometheus.Metric {
+	switch tpl.metricType {
+	case "summary":
+		return tpl.summaryVec.With(labels).(prometheus.Metric)
+	case "counter":
+		return tpl.counterVec.With(labels)
+	case "histogram":
+		return tpl.histogramVec.With(labels).(prometheus.Metric)
+	default:
+		return tpl.gaugeVec.With(labels)
+	}
+}
+
+// Instances of templates with TTL are wrapped into this - so every usage touches the instance. And if the instance was deleted meanwhile (as it expired) but
+// somebody still holds it then the instance is revived on the next usage.
+type expiringInstance struct {
+	tpl    MetricTemplate
+	labels prometheus.Labels
+
+	lock   sync.Mutex
+	entry  *metricInstanceEntry
+	metric prometheus.Metric
+}
+
+// the entry is revived (touched) first and the Metric is taken only after - so a sweep can not delete the series in between
+func newExpiringInstance(tpl MetricTemplate, labels prometheus.Labels) *expiringInstance {
+	entry := tpl.reviveInstance(labels)
+	return &expiringInstance{
+		tpl:    tpl,
+		labels: labels,
+		entry:  entry,
+		metric: tpl.instanceMetric(labels),
+	}
+}
+
+// touches the instance and returns the current underlying Metric
+func (i *expiringInstance) current() prometheus.Metric {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	// touching and checking under the template lock - so a sweep can not delete the series between the two
+	i.tpl.state.lock.Lock()
+	i.entry.touch()
+	isDeleted := i.entry.isDeleted.Load()
+	i.tpl.state.lock.Unlock()
+
+	if isDeleted {
+		i.entry = i.tpl.reviveInstance(i.labels)
+		i.metric = i.tpl.instanceMetric(i.labels)
+	}
+	return i.metric
+}
+
+// returns the current underlying Metric without touching it - collecting the Metric does not count as usage
+func (i *expiringInstance) peek() prometheus.Metric {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.metric
+}
+
+func (i *expiringInstance) Desc() *prometheus.Desc {
+	return i.peek().Desc()
+}
+
+func (i *expiringInstance) Write(out *dto.Metric) error {
+	return i.peek().Write(out)
+}
+
+func (i *expiringInstance) Describe(ch chan<- *prometheus.Desc) {
+	ch <- i.Desc()
+}
+
+func (i *expiringInstance) Collect(ch chan<- prometheus.Metric) {
+	ch <- i.peek()
+}
+
+type expiringObserver struct {
+	*expiringInstance
+}
+
+func (o expiringObserver) Observe(value float64) {
+	o.current().(prometheus.Observer).Observe(value)
+}
+
+type expiringCounter struct {
+	*expiringInstance
+}
+
+func (c expiringCounter) Inc() {
+	c.current().(prometheus.Counter).Inc()
+}
+
+func (c expiringCounter) Add(value float64) {
+	c.current().(prometheus.Counter).Add(value)
+}
+
+type expiringGauge struct {
+	*expiringInstance
+}
+
+func (g expiringGauge) Set(value float64) {
+	g.current().(prometheus.Gauge).Set(value)
+}
+
+func (g expiringGauge) Inc() {
+	g.current().(prometheus.Gauge).Inc()
+}
+
+func (g expiringGauge) Dec() {
+	g.current().(prometheus.Gauge).Dec()
+}
+
+func (g expiringGauge) Add(value float64) {
+	g.current().(prometheus.Gauge).Add(value)
+}
+
+func (g expiringGauge) Sub(value float64) {
+	g.current().(prometheus.Gauge).Sub(value)
+}
+
+func (g expiringGauge) SetToCurrentTime() {
+	g.current().(prometheus.Gauge).SetToCurrentTime()
+}
+
+// The sets (e.g. HttpClientLazyMetricsSet) are using this to create their Metric instances - this remembers what was created so the set can delete them all
+// when it is asked to forget them.
+//
+// Different sets can end up with the same instance (same template and label values, e.g. two sets with the same "of") - so a set deletes an instance only if
+// no other set is holding it anymore.
+type createdMetricInstances struct {
+	lock sync.Mutex
+	// key is built by ownedInstanceKey()
+	instances map[string]createdMetricInstance
+}
+
+type createdMetricInstance struct {
+	template     MetricTemplate
+	customLabels map[string]any
+	// the setOwnedInstancesGeneration it was counted in
+	generation int
+}
+
+var (
+	// how many sets are holding the instance - key is built by ownedInstanceKey()
+	setOwnedInstances = make(map[string]int)
+	// increased when the counts are reset (see resetSetOwnedInstances()) - instances counted before are not counted anymore
+	setOwnedInstancesGeneration int
+	setOwnedInstancesLock       sync.Mutex
+)
+
+// forgets how many sets are holding the instances - the sets created before do not delete anything anymore, the registry of their instances is gone
+func resetSetOwnedInstances() {
+	setOwnedInstancesLock.Lock()
+	defer setOwnedInstancesLock.Unlock()
+	setOwnedInstances = make(map[string]int)
+	setOwnedInstancesGeneration++
+}
+
+// the name of the template plus the instanceKey() of the labels
+func ownedInstanceKey(metricTemplate MetricTemplate, customLabels map[string]any) string {
+	labels := make(map[string]any, len(customLabels)+1)
+	for name, value := range customLabels {
+		labels[name] = value
+	}
+	labels["metricType"] = metricTemplate.metricType
+	return metricTemplate.fullyQualifiedName + "\xff" + metricTemplate.instanceKey(metricTemplate.foldNotAllowedLabelValues(BuildMetricLabels(labels)))
+}
+
+func (c *createdMetricInstances) remember(metricTemplate MetricTemplate, customLabels map[string]any) {
+	key := ownedInstanceKey(metricTemplate, customLabels)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, found := c.instances[key]; found {
+		return
+	}
+	if c.instances == nil {
+		c.instances = make(map[string]createdMetricInstance)
+	}
+
+	setOwnedInstancesLock.Lock()
+	setOwnedInstances[key]++
+	c.instances[key] = createdMetricInstance{template: metricTemplate, customLabels: customLabels, generation: setOwnedInstancesGeneration}
+	setOwnedInstancesLock.Unlock()
+}
+
+func (c *createdMetricInstances) counter(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Counter {
+	c.remember(metricTemplate, customLabels)
+	return GetCounterMetricInstance(metricTemplate, customLabels)
+}
+
+func (c *createdMetricInstances) summary(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Observer {
+	c.remember(metricTemplate, customLabels)
+	return GetSummaryMetricInstance(metricTemplate, customLabels)
+}
+
+func (c *createdMetricInstances) gauge(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Gauge {
+	c.remember(metricTemplate, customLabels)
+	return GetGaugeMetricInstance(metricTemplate, customLabels)
+}
+
+// deletes the remembered instances no other set is holding
+func (c *createdMetricInstances) deleteAll() {
+	c.lock.Lock()
+	instances := c.instances
+	c.instances = nil
+	c.lock.Unlock()
+
+	// we keep the lock while deleting - so another set can not take the instance meanwhile
+	setOwnedInstancesLock.Lock()
+	defer setOwnedInstancesLock.Unlock()
+	for key, instance := range instances {
+		if instance.generation != setOwnedInstancesGeneration {
+			continue
+		}
+		setOwnedInstances[key]--
+		if setOwnedInstances[key] > 0 {
+			continue
+		}
+		delete(setOwnedInstances, key)
+		DeleteMetricInstance(instance.template, instance.customLabels)
+	}
+}
@@ -0,0 +1,101 @@
+package kt_observability_monitoring
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestForgetKeepsInstancesOtherSetsAreHolding(t *testing.T) {
+	InitMetrics()
+	template := GetServerServeStartedCountTemplate()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	first := NewHttpServerLazyMetricsSet("forgetTestEndpoint")
+	second := NewHttpServerLazyMetricsSet("forgetTestEndpoint")
+	first.ServeStarted(req)
+	second.ServeStarted(req)
+
+	first.Forget()
+	if got := counterValue(t, template, "forgetTestEndpoint"); got != 2 {
+		t.Errorf("the series must survive as the second set is still holding it - expected 2, got %v", got)
+	}
+
+	second.Forget()
+	for _, instance := range template.liveInstanceLabels() {
+		if instance["of"] == "forgetTestEndpoint" {
+			t.Errorf("the series must be deleted once no set is holding it")
+		}
+	}
+}
+
+func TestSweeperStopsWithTheTTL(t *testing.T) {
+	InitMetrics()
+	tpl := newTestCounterTemplate(t, "sweeperStopTest")
+
+	tpl.SetInstanceTTL(time.Hour)
+	first := tpl.state.stopSweeping
+	// a new TTL means a new sweeper
+	tpl.SetInstanceTTL(time.Minute)
+	second := tpl.state.stopSweeping
+	expectClosed(t, "the sweeper of the previous TTL", first)
+
+	tpl.SetInstanceTTL(0)
+	expectClosed(t, "the sweeper after the TTL was set to 0", second)
+	if tpl.state.stopSweeping != nil {
+		t.Errorf("no sweeper must be running without TTL")
+	}
+}
+
+func TestInitMetricsStopsTheSweepers(t *testing.T) {
+	InitMetrics()
+	tpl := newTestCounterTemplate(t, "sweeperInitTest")
+	tpl.SetInstanceTTL(time.Hour)
+	stop := tpl.state.stopSweeping
+
+	InitMetrics()
+	expectClosed(t, "the sweeper of the forgotten template", stop)
+	sweepingTemplatesLock.Lock()
+	defer sweepingTemplatesLock.Unlock()
+	if len(sweepingTemplates) != 0 {
+		t.Errorf("expected no sweeping templates, got %v", len(sweepingTemplates))
+	}
+}
+
+func TestInitMetricsResetsWhichSetsAreHoldingInstances(t *testing.T) {
+	InitMetrics()
+	req := httptest.NewRequest("GET", "/", nil)
+	before := NewHttpServerLazyMetricsSet("ownedResetTest")
+	before.ServeStarted(req)
+
+	InitMetrics()
+	setOwnedInstancesLock.Lock()
+	if len(setOwnedInstances) != 0 {
+		t.Errorf("expected the counts to be reset, got %v", setOwnedInstances)
+	}
+	setOwnedInstancesLock.Unlock()
+
+	after := NewHttpServerLazyMetricsSet("ownedResetTest")
+	after.ServeStarted(req)
+	// the set of the previous registry must not take the instance of the new one with it
+	before.Forget()
+	if got := counterValue(t, GetServerServeStartedCountTemplate(), "ownedResetTest"); got != 1 {
+		t.Errorf("expected the series of the new set to survive with 1, got %v", got)
+	}
+	after.Forget()
+	if got := counterValue(t, GetServerServeStartedCountTemplate(), "ownedResetTest"); got != 0 {
+		t.Errorf("expected the series to be deleted, got %v", got)
+	}
+}
+
+func expectClosed(t *testing.T, what string, ch chan struct{}) {
+	t.Helper()
+	if ch == nil {
+		t.Fatalf("%v: there is no sweeper", what)
+	}
+	select {
+	case <-ch:
+	default:
+		t.Errorf("%v must be stopped", what)
+	}
+}
@@ -0,0 +1,182 @@
+package kt_observability_monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// The URL scheme of the Zap sink counting the log events - see InstallLogEventCounter()
+const LogEventCounterSinkScheme = "kt-log-event-count"
+
+// The max number of distinct "of" values (logger names after the LoggerNamePolicy) the "logEventCount" Metric is using if you do not set it explicitly with
+// WithLogEventMaxLoggerNames(). Events of further loggers are counted with "of"=OtherLabelValue.
+var DefaultLogEventMaxLoggerNames = 50
+
+// the counter InstallLogEventCounter() has installed last - the sinks are always counting with this one
+var activeLogEventCounter atomic.Pointer[logEventCounter]
+
+func init() {
+	// registered right away - so a kt_logging config referring to it can be loaded any time
+	err := zap.RegisterSink(LogEventCounterSinkScheme, newLogEventCountingSink)
+	if err != nil {
+		panic(fmt.Sprintf("failed to register the '%v' Zap sink! error was: %v", LogEventCounterSinkScheme, err))
+	}
+}
+
+type logEventCounter struct {
+	template       MetricTemplate
+	policy         LoggerNamePolicy
+	maxLoggerNames int
+
+	lock sync.Mutex
+	// the "of" values we have handed out so far
+	knownLoggerNames map[string]bool
+}
+
+type LogEventCounterOpt func(*logEventCounter)
+
+// The policy deciding the "of" label from the logger name - by default DefaultLoggerNamePolicy
+func WithLogEventLoggerNamePolicy(policy LoggerNamePolicy) LogEventCounterOpt {
+	return func(c *logEventCounter) {
+		c.policy = policy
+	}
+}
+
+// The max number of distinct "of" values - by default DefaultLogEventMaxLoggerNames. Pass in 0 to remove the limit.
+func WithLogEventMaxLoggerNames(maxLoggerNames int) LogEventCounterOpt {
+	return func(c *logEventCounter) {
+		c.maxLoggerNames = maxLoggerNames
+	}
+}
+
+// Starts counting the log events - from now on every log event written increases the predefined "logEventCount" counter labeled with the logger name
+// ("of"), the level and the handler. This way you can alert on spikes of errors and warnings without having a log pipeline, e.g.
+//
+//	sum by (serviceName, of) (rate(logEventCount{level="error"}[5m])) > 0
+//
+// The events are counted by a Zap sink the kt_logging handlers are writing to - so you need to add it to the 'outputPaths' of the handlers you want to count
+// in your kt_logging config, with the name of the handler as host, e.g.
+//
+//	handlers:
+//	  stdout_json:
+//	    level: info
+//	    encoding: json
+//	    outputPaths: [stdout, "kt-log-event-count://stdout_json"]
+//
+// Only the events which pass the level of the Logger and the handler are counted - so what you see in the logs. Handlers using 'rollingFile' can not have
+// 'outputPaths' - these can not be counted.
+//
+// The "of" label is bounded: the logger name goes through the LoggerNamePolicy, and once there are more distinct values than the max (see
+// WithLogEventMaxLoggerNames()) further loggers are counted as "other".
+//
+// Nothing is changed in kt_logging - so it is safe to invoke it any time (also while logging is going on) and more times, the last options win. Until it is
+// invoked the sinks do nothing.
+func InstallLogEventCounter(opts ...LogEventCounterOpt) {
+	counter := &logEventCounter{
+		template:         GetLogEventCountTemplate(),
+		policy:           DefaultLoggerNamePolicy,
+		maxLoggerNames:   DefaultLogEventMaxLoggerNames,
+		knownLoggerNames: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(counter)
+	}
+	activeLogEventCounter.Store(counter)
+}
+
+// Stops counting the log events - the sinks stay in the handlers but they do nothing until InstallLogEventCounter() is invoked again
+func UninstallLogEventCounter() {
+	activeLogEventCounter.Store(nil)
+}
+
+func (c *logEventCounter) count(handlerName string, loggerName string, level string) {
+	// the template logs a warning if it is not registered - which would come back here... so we better do nothing
+	if !c.template.IsRegistered() {
+		return
+	}
+	GetCounterMetricInstanceByLabelValues(c.template, c.boundedLoggerName(loggerName), level, handlerName).Inc()
+}
+
+func (c *logEventCounter) boundedLoggerName(loggerName string) string {
+	name := c.policy(loggerName)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.knownLoggerNames[name] {
+		return name
+	}
+	if c.maxLoggerNames > 0 && len(c.knownLoggerNames) >= c.maxLoggerNames {
+		return OtherLabelValue
+	}
+	c.knownLoggerNames[name] = true
+	return name
+}
+
+// a zap.Sink which is not writing anywhere - it counts the encoded log events written into it
+type logEventCountingSink struct {
+	handlerName string
+}
+
+func newLogEventCountingSink(sinkUrl *url.URL) (zap.Sink, error) {
+	if sinkUrl.Host == "" {
+		return nil, fmt.Errorf("the name of the handler is missing from '%v' - it should look like '%v://<handler name>'", sinkUrl, LogEventCounterSinkScheme)
+	}
+	return &logEventCountingSink{handlerName: sinkUrl.Host}, nil
+}
+
+func (s *logEventCountingSink) Write(p []byte) (int, error) {
+	if counter := activeLogEventCounter.Load(); counter != nil {
+		if level, loggerName, ok := parseLogEvent(p); ok {
+			counter.count(s.handlerName, loggerName, level)
+		}
+	}
+	return len(p), nil
+}
+
+func (s *logEventCountingSink) Sync() error {
+	return nil
+}
+
+func (s *logEventCountingSink) Close() error {
+	return nil
+}
+
+// the parts of the log events written by kt_logging we are interested in
+type logEventFields struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger"`
+}
+
+// takes the level and the logger name out of the given encoded log event - kt_logging handlers are using either "json" or "console" encoding
+func parseLogEvent(event []byte) (level string, loggerName string, ok bool) {
+	event = bytes.TrimSpace(event)
+	var fields logEventFields
+	if bytes.HasPrefix(event, []byte("{")) {
+		if json.Unmarshal(event, &fields) != nil {
+			return "", "", false
+		}
+	} else {
+		// console encoding looks like: <time>\t<level>\t<message>\t<fields as JSON>
+		parts := bytes.SplitN(event, []byte("\t"), 3)
+		if len(parts) < 3 {
+			return "", "", false
+		}
+		fields.Level = string(parts[1])
+		if fieldsIdx := bytes.LastIndex(event, []byte("\t{")); fieldsIdx >= 0 {
+			json.Unmarshal(event[fieldsIdx+1:], &fields)
+		}
+	}
+	if fields.Level == "" {
+		return "", "", false
+	}
+	if fields.Logger == "" {
+		fields.Logger = "-"
+	}
+	return fields.Level, fields.Logger, true
+}
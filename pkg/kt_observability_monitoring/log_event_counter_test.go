@@ -0,0 +1,190 @@
+package kt_observability_monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+)
+
+// inits kt_logging from the given config - and restores the default config of kt_logging once the test is done
+func initLogging(t *testing.T, config string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeConfig := func(fileName string, content string) string {
+		path := filepath.Join(dir, fileName)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %v: %v", path, err)
+		}
+		return path
+	}
+	defaultConfig := writeConfig("default.yaml", `
+loggers:
+  root: {level: info, handlers: [stdout_json]}
+handlers:
+  stdout_json: {level: info, encoding: json, outputPaths: [stdout]}
+`)
+	if err := kt_logging.InitFromConfig(writeConfig("test.yaml", config)); err != nil {
+		t.Fatalf("failed to init logging: %v", err)
+	}
+	t.Cleanup(func() {
+		UninstallLogEventCounter()
+		kt_logging.InitFromConfig(defaultConfig)
+	})
+}
+
+func logEventCount(t *testing.T, of string, level string, handler string) float64 {
+	t.Helper()
+	return metricValue(t, GetLogEventCountTemplate(), map[string]string{"of": of, "level": level, "handler": handler})
+}
+
+func TestLogEventCounterCountsTheWrittenEvents(t *testing.T) {
+	InitMetrics()
+	initLogging(t, `
+loggers:
+  root: {level: info, handlers: [json, console]}
+  logCounterTest.debug: {level: debug, handlers: [json, console]}
+handlers:
+  json: {level: debug, encoding: json, outputPaths: ["kt-log-event-count://json"]}
+  console: {level: warn, encoding: console, outputPaths: ["kt-log-event-count://console"]}
+`)
+
+	// not counting until it is installed
+	kt_logging.GetLogger("logCounterTest").Error("not counted")
+	InstallLogEventCounter()
+
+	LOG := kt_logging.GetLogger("logCounterTest")
+	LOG.Error("tabs\tand {braces} in the message")
+	LOG.Warn("warning")
+	LOG.Info("info")
+	// filtered out by the Logger
+	LOG.Debug("debug")
+	kt_logging.GetLogger("logCounterTest.debug").Debug("debug")
+
+	for _, tc := range []struct {
+		of, level, handler string
+		want               float64
+	}{
+		{"logCounterTest", "error", "json", 1},
+		{"logCounterTest", "warn", "json", 1},
+		{"logCounterTest", "info", "json", 1},
+		{"logCounterTest", "debug", "json", 0},
+		{"logCounterTest.debug", "debug", "json", 1},
+		{"logCounterTest", "error", "console", 1},
+		{"logCounterTest", "warn", "console", 1},
+		// filtered out by the handler
+		{"logCounterTest", "info", "console", 0},
+		{"logCounterTest.debug", "debug", "console", 0},
+	} {
+		if got := logEventCount(t, tc.of, tc.level, tc.handler); got != tc.want {
+			t.Errorf("of=%v level=%v handler=%v: expected %v, got %v", tc.of, tc.level, tc.handler, tc.want, got)
+		}
+	}
+
+	// and stops counting once it is uninstalled
+	UninstallLogEventCounter()
+	LOG.Error("not counted")
+	if got := logEventCount(t, "logCounterTest", "error", "json"); got != 1 {
+		t.Errorf("expected the count to stay 1 after uninstall, got %v", got)
+	}
+}
+
+func TestLogEventCounterBoundsTheLoggerNames(t *testing.T) {
+	InitMetrics()
+	initLogging(t, `
+loggers:
+  root: {level: info, handlers: [counted]}
+handlers:
+  counted: {level: info, encoding: json, outputPaths: ["kt-log-event-count://maxNamesTest"]}
+`)
+	InstallLogEventCounter(WithLogEventLoggerNamePolicy(TruncatedLoggerNames(2)), WithLogEventMaxLoggerNames(2))
+
+	kt_logging.GetLogger("maxNamesTest.a.one").Error("first")
+	kt_logging.GetLogger("maxNamesTest.a.two").Error("same name after the policy")
+	kt_logging.GetLogger("maxNamesTest.b").Error("second")
+	kt_logging.GetLogger("maxNamesTest.c").Error("over the limit")
+	kt_logging.GetLogger("maxNamesTest.d").Error("over the limit")
+	// a name we have seen already keeps its own value
+	kt_logging.GetLogger("maxNamesTest.b").Error("second again")
+
+	for of, want := range map[string]float64{"maxNamesTest.a": 2, "maxNamesTest.b": 2, OtherLabelValue: 2, "maxNamesTest.c": 0, "maxNamesTest.d": 0} {
+		if got := logEventCount(t, of, "error", "maxNamesTest"); got != want {
+			t.Errorf("of=%v: expected %v, got %v", of, want, got)
+		}
+	}
+}
+
+// run it with -race: installing the counter must not touch anything the loggers are using
+func TestLogEventCounterCanBeInstalledWhileLogging(t *testing.T) {
+	InitMetrics()
+	initLogging(t, `
+loggers:
+  root: {level: info, handlers: [counted]}
+handlers:
+  counted: {level: info, encoding: json, outputPaths: ["kt-log-event-count://concurrencyTest"]}
+`)
+	LOG := kt_logging.GetLogger("logCounterConcurrencyTest")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				LOG.Info("logging")
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		InstallLogEventCounter()
+	}
+	wg.Wait()
+
+	InstallLogEventCounter()
+	LOG.Info("logging")
+	if got := logEventCount(t, "logCounterConcurrencyTest", "info", "concurrencyTest"); got < 1 {
+		t.Errorf("expected the events to be counted, got %v", got)
+	}
+}
+
+func TestLogEventCounterSinkNeedsTheHandlerName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.yaml")
+	os.WriteFile(path, []byte(`
+loggers:
+  root: {level: info, handlers: [counted]}
+handlers:
+  counted: {level: info, encoding: json, outputPaths: ["kt-log-event-count://"]}
+`), 0o644)
+
+	defer func() {
+		// kt_logging panics if the Zap logger of a handler can not be built
+		if recover() == nil {
+			t.Errorf("expected the sink to be refused without a handler name")
+		}
+	}()
+	kt_logging.InitFromConfig(path)
+}
+
+func TestParseLogEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name, event, wantLevel, wantLogger string
+		wantOk                             bool
+	}{
+		{"json", `{"level":"warn","time":"2024-01-01T00:00:00Z","message":"hi","logger":"a.b","x":1}` + "\n", "warn", "a.b", true},
+		{"json without logger", `{"level":"info","message":"hi"}`, "info", "-", true},
+		{"console", "2024-01-01T00:00:00Z\terror\thi\t{\"logger\": \"a.b\"}\n", "error", "a.b", true},
+		{"console without fields", "2024-01-01T00:00:00Z\tinfo\thi\n", "info", "-", true},
+		{"broken json", `{"level":`, "", "", false},
+		{"garbage", "something", "", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			level, logger, ok := parseLogEvent([]byte(tc.event))
+			if level != tc.wantLevel || logger != tc.wantLogger || ok != tc.wantOk {
+				t.Errorf("expected (%q, %q, %v), got (%q, %q, %v)", tc.wantLevel, tc.wantLogger, tc.wantOk, level, logger, ok)
+			}
+		})
+	}
+}
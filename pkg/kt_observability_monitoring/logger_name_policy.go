@@ -0,0 +1,62 @@
+package kt_observability_monitoring
+
+import (
+	"strings"
+)
+
+// A LoggerNamePolicy decides what value ends up in the "of" label of the "logEventCount" Metric (see InstallLogEventCounter()) - it gets the name of the
+// kt_logging Logger the event was logged with and returns the label value.
+//
+// Logger names are usually well known constants (like "keytiles.observability.monitoring.MetricTemplate") but nothing stops anyone to build them dynamically -
+// so the cardinality of the label is under central control here.
+type LoggerNamePolicy func(loggerName string) string
+
+// The LoggerNamePolicy InstallLogEventCounter() is using if you do not assign one explicitly with WithLogEventLoggerNamePolicy(). By default this is
+// ExactLoggerNames() - the number of distinct names is bounded anyways, see DefaultLogEventMaxLoggerNames.
+var DefaultLoggerNamePolicy LoggerNamePolicy = ExactLoggerNames()
+
+// The logger name is used as it is - e.g. "keytiles.observability.monitoring.MetricTemplate"
+func ExactLoggerNames() LoggerNamePolicy {
+	return func(loggerName string) string {
+		return loggerName
+	}
+}
+
+// Only the first "depth" dot separated parts of the logger name are used - e.g. with depth 3 "keytiles.observability.monitoring.MetricTemplate" becomes
+// "keytiles.observability.monitoring". Names with less parts are used as they are.
+func TruncatedLoggerNames(depth int) LoggerNamePolicy {
+	if depth < 1 {
+		panic("Can not create TruncatedLoggerNames policy - depth must be at least 1!")
+	}
+	return func(loggerName string) string {
+		parts := strings.SplitN(loggerName, ".", depth+1)
+		if len(parts) <= depth {
+			return loggerName
+		}
+		return strings.Join(parts[:depth], ".")
+	}
+}
+
+// Only the listed loggers (and their child loggers) are used - any other logger name becomes "other". Just like kt_logging Loggers the names are hierarchical:
+// if you list "keytiles.observability" then "keytiles.observability.monitoring.MetricTemplate" is reported as "keytiles.observability" - unless you list
+// a longer matching name too.
+func AllowListedLoggerNames(allowedLoggerNames ...string) LoggerNamePolicy {
+	allowed := make(map[string]bool, len(allowedLoggerNames))
+	for _, name := range allowedLoggerNames {
+		allowed[name] = true
+	}
+	return func(loggerName string) string {
+		// walking up the hierarchy - so the longest listed name wins
+		for name := loggerName; name != ""; {
+			if allowed[name] {
+				return name
+			}
+			dotIdx := strings.LastIndex(name, ".")
+			if dotIdx < 0 {
+				break
+			}
+			name = name[:dotIdx]
+		}
+		return OtherLabelValue
+	}
+}
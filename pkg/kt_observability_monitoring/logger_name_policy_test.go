@@ -0,0 +1,42 @@
+package kt_observability_monitoring
+
+import (
+	"testing"
+)
+
+func TestLoggerNamePolicies(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		policy     LoggerNamePolicy
+		loggerName string
+		want       string
+	}{
+		{"exact", ExactLoggerNames(), "keytiles.observability.monitoring", "keytiles.observability.monitoring"},
+		{"truncated longer", TruncatedLoggerNames(2), "keytiles.observability.monitoring.MetricTemplate", "keytiles.observability"},
+		{"truncated same depth", TruncatedLoggerNames(2), "keytiles.observability", "keytiles.observability"},
+		{"truncated shorter", TruncatedLoggerNames(2), "main", "main"},
+		{"truncated depth 1", TruncatedLoggerNames(1), "keytiles.observability", "keytiles"},
+		{"allow-listed exact", AllowListedLoggerNames("keytiles.observability"), "keytiles.observability", "keytiles.observability"},
+		{"allow-listed child", AllowListedLoggerNames("keytiles.observability"), "keytiles.observability.monitoring.MetricTemplate", "keytiles.observability"},
+		{"allow-listed longest wins", AllowListedLoggerNames("keytiles", "keytiles.observability.monitoring"), "keytiles.observability.monitoring.MetricTemplate", "keytiles.observability.monitoring"},
+		{"allow-listed parent", AllowListedLoggerNames("keytiles.observability.monitoring"), "keytiles.observability", OtherLabelValue},
+		{"allow-listed prefix only", AllowListedLoggerNames("keytiles.obs"), "keytiles.observability", OtherLabelValue},
+		{"allow-listed unknown", AllowListedLoggerNames("keytiles"), "main", OtherLabelValue},
+		{"allow-listed nothing", AllowListedLoggerNames(), "main", OtherLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy(tc.loggerName); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTruncatedLoggerNamesRejectsInvalidDepth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for depth 0")
+		}
+	}()
+	TruncatedLoggerNames(0)
+}
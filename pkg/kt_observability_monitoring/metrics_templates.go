@@ -1,11 +1,19 @@
 package kt_observability_monitoring
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	metricTemplatesAvailable bool
+	// TRUE once the predefined templates are created into the current MetricRegistry - they are created under the lock, the flag is the fast path
+	metricTemplatesAvailable atomic.Bool
+	metricTemplatesLock      sync.Mutex
+
+	// Counts label value combinations folded into the overflow series because the cardinality limit of the template was reached - "of" is the template name
+	cardinalityLimitExceeded_template MetricTemplate
 
 	// Generic execution counter - "of" something/anything
 	execCount_template MetricTemplate
@@ -26,6 +34,12 @@ var (
 	clientReqRetriedWarnCount_template MetricTemplate
 	// Generic "req took time" (summary - observer) for synchronous request clients (HTTP, gRPC, etc)
 	clientReqProcessingTime_template MetricTemplate
+	// Generic "req sent but no response yet" (gauge) for synchronous request clients (HTTP, gRPC, etc)
+	clientReqInFlight_template MetricTemplate
+	// Generic "size of the req payload" (summary - observer) for synchronous request clients (HTTP, gRPC, etc)
+	clientReqSize_template MetricTemplate
+	// Generic "size of the response payload" (summary - observer) for synchronous request clients (HTTP, gRPC, etc)
+	clientRespSize_template MetricTemplate
 
 	// Generic "req arrived" counter for servers (HTTP, gRPC, etc)
 	serverServeStartedCount_template MetricTemplate
@@ -35,14 +49,89 @@ var (
 	serverServeFailedCount_template MetricTemplate
 	// Generic "req took time" (summary - observer) for servers (HTTP, gRPC, etc)
 	serverServeProcessingTime_template MetricTemplate
+	// Generic "req being served right now" (gauge) for servers (HTTP, gRPC, etc)
+	serverServeInFlight_template MetricTemplate
+	// Generic "size of the req payload" (summary - observer) for servers (HTTP, gRPC, etc)
+	serverServeReqSize_template MetricTemplate
+	// Generic "size of the response payload" (summary - observer) for servers (HTTP, gRPC, etc)
+	serverServeRespSize_template MetricTemplate
+
+	// Worker pool "tasks waiting in queue" (gauge)
+	workerPoolQueueDepth_template MetricTemplate
+	// Worker pool "workers busy with a task right now" (gauge)
+	workerPoolActiveWorkers_template MetricTemplate
+	// Worker pool "task waited in queue" time (summary - observer)
+	workerPoolTaskWaitTime_template MetricTemplate
+	// Worker pool "task execution took time" (summary - observer)
+	workerPoolTaskProcessingTime_template MetricTemplate
+	// Worker pool "task executed" counter
+	workerPoolTaskExecCount_template MetricTemplate
+	// Worker pool "task failed" counter
+	workerPoolTaskFailedCount_template MetricTemplate
+	// Worker pool "task rejected as queue was full" counter
+	workerPoolTaskRejectedCount_template MetricTemplate
+
+	// Scheduled job "last run started at" unix timestamp in seconds (gauge)
+	scheduledJobLastStartTime_template MetricTemplate
+	// Scheduled job "last successful run finished at" unix timestamp in seconds (gauge)
+	scheduledJobLastSuccessTime_template MetricTemplate
+	// Scheduled job "seconds elapsed since last successful run" (gauge) - calculated when Metrics are collected
+	scheduledJobSecondsSinceLastSuccess_template MetricTemplate
+	// Scheduled job "run took time" (summary - observer)
+	scheduledJobProcessingTime_template MetricTemplate
+	// Scheduled job "run executed" counter
+	scheduledJobRunCount_template MetricTemplate
+	// Scheduled job "run failed" counter
+	scheduledJobFailedCount_template MetricTemplate
+	// Scheduled job "run skipped as previous one was still running" counter
+	scheduledJobSkippedCount_template MetricTemplate
+
+	// Circuit breaker "current state" (gauge) - 0: closed, 1: half-open, 2: open
+	circuitBreakerState_template MetricTemplate
+	// Circuit breaker "failure rate in the current window" (gauge)
+	circuitBreakerFailureRate_template MetricTemplate
+	// Circuit breaker "state has changed" counter
+	circuitBreakerTransitionCount_template MetricTemplate
+	// Circuit breaker "call was rejected as circuit is open" counter
+	circuitBreakerRejectedCount_template MetricTemplate
+
+	// Rate limiter "call was allowed" counter
+	rateLimiterAllowedCount_template MetricTemplate
+	// Rate limiter "call was throttled" counter
+	rateLimiterThrottledCount_template MetricTemplate
+	// Rate limiter "call had to wait for a token" time (summary - observer)
+	rateLimiterWaitTime_template MetricTemplate
+	// Rate limiter "tokens currently available" (gauge)
+	rateLimiterTokens_template MetricTemplate
+
+	// Counts the log events - "of" is the logger name, see InstallLogEventCounter()
+	logEventCount_template MetricTemplate
 )
 
 func createMetricTemplatesIfNotCreatedYet(reg prometheus.Registerer) {
-	if metricTemplatesAvailable {
+	if metricTemplatesAvailable.Load() {
 		// we have them already - skip
 		return
 	}
-	metricTemplatesAvailable = true
+	metricTemplatesLock.Lock()
+	defer metricTemplatesLock.Unlock()
+	if metricTemplatesAvailable.Load() {
+		// somebody else has created them while we were waiting
+		return
+	}
+	// only set once all of them are there - so nobody takes the fast path to a half created set
+	defer metricTemplatesAvailable.Store(true)
+
+	cardinalityLimitExceeded_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "cardinalityLimitExceeded",
+			Help:      "Reports count of label value combinations folded into the overflow series because the cardinality limit of the metric template was reached (check 'of' attribute - that is the name of the template!)",
+		}, []string{"of"},
+	)
+	// it is counting the overflows of the other templates - so it must not have a limit itself
+	cardinalityLimitExceeded_template.SetCardinalityLimit(0)
+	cardinalityLimitExceeded_template.Register(reg)
 
 	// "of" - you can add the name of the endpoint here you are invoking
 	// "protocol" - protocol of your client, e.g. "http" or "grpc" or whatever
@@ -97,6 +186,33 @@ func createMetricTemplatesIfNotCreatedYet(reg prometheus.Registerer) {
 	)
 	clientReqFailedCount_template.Register(reg)
 
+	clientReqInFlight_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "clientReqInFlight",
+			Help:      "Client (HTTP, gRPC, etc) metric. Reports count of sync client requests sent but not completed yet (check 'of' attribute!)",
+		}, customClientMetricsLabels,
+	)
+	clientReqInFlight_template.Register(reg)
+
+	clientReqSize_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "clientReqSize",
+			Help:      "Client (HTTP, gRPC, etc) metric. Reports the size of the payload (bytes) of a sync client request (check 'of' attribute!)",
+		}, customClientMetricsLabels,
+	)
+	clientReqSize_template.Register(reg)
+
+	clientRespSize_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "clientRespSize",
+			Help:      "Client (HTTP, gRPC, etc) metric. Reports the size of the response payload (bytes) of a sync client request (check 'of' attribute!)",
+		}, customClientMetricsLabels,
+	)
+	clientRespSize_template.Register(reg)
+
 	// "serverId" - can identify which of your concrete server (sometimes there are multiple) this metrics belong to
 	// "of" - you can add the name of the endpoint here server is serving
 	// "protocol" - protocol of your server, e.g. "http" or "grpc" or whatever
@@ -141,6 +257,33 @@ func createMetricTemplatesIfNotCreatedYet(reg prometheus.Registerer) {
 	)
 	serverServeFailedCount_template.Register(reg)
 
+	serverServeInFlight_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "serverServeInFlight",
+			Help:      "Server (HTTP, gRPC, etc) metric. Reports count of requests of a specific type being served right now (check 'of' attribute!)",
+		}, customServerMetricsLabels,
+	)
+	serverServeInFlight_template.Register(reg)
+
+	serverServeReqSize_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "serverServeReqSize",
+			Help:      "Server (HTTP, gRPC, etc) metric. Reports the size of the request payload (bytes) of a specific request type (check 'of' attribute!)",
+		}, customServerMetricsLabels,
+	)
+	serverServeReqSize_template.Register(reg)
+
+	serverServeRespSize_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "serverServeRespSize",
+			Help:      "Server (HTTP, gRPC, etc) metric. Reports the size of the response payload (bytes) of a specific request type (check 'of' attribute!)",
+		}, customServerMetricsLabels,
+	)
+	serverServeRespSize_template.Register(reg)
+
 	customGenericLabels := []string{"of", "qualifier"}
 
 	processingTime_template = GetSummaryMetricTemplate(
@@ -178,6 +321,241 @@ func createMetricTemplatesIfNotCreatedYet(reg prometheus.Registerer) {
 		}, customGenericLabels,
 	)
 	warningCount_template.Register(reg)
+
+	// "of" - the name of the worker pool
+	// "qualifier" - anything else your use case finds useful - or leave empty ""
+	customWorkerPoolLabels := []string{"of", "qualifier"}
+
+	workerPoolQueueDepth_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "workerPoolQueueDepth",
+			Help:      "Worker pool metric. Reports the number of tasks waiting in the queue of the pool (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolQueueDepth_template.Register(reg)
+
+	workerPoolActiveWorkers_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "workerPoolActiveWorkers",
+			Help:      "Worker pool metric. Reports the number of workers executing a task right now (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolActiveWorkers_template.Register(reg)
+
+	workerPoolTaskWaitTime_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "workerPoolTaskWaitTime",
+			Help:      "Worker pool metric. Reports time a task spent in the queue from enqueue until execution started (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolTaskWaitTime_template.Register(reg)
+
+	workerPoolTaskProcessingTime_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "workerPoolTaskProcessingTime",
+			Help:      "Worker pool metric. Reports execution time of a task (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolTaskProcessingTime_template.Register(reg)
+
+	workerPoolTaskExecCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "workerPoolTaskExecCount",
+			Help:      "Worker pool metric. Reports count of executed tasks (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolTaskExecCount_template.Register(reg)
+
+	workerPoolTaskFailedCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "workerPoolTaskFailedCount",
+			Help:      "Worker pool metric. Reports count of tasks which returned with an error (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolTaskFailedCount_template.Register(reg)
+
+	workerPoolTaskRejectedCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "workerPoolTaskRejectedCount",
+			Help:      "Worker pool metric. Reports count of tasks rejected because the queue of the pool was full (check 'of' attribute!)",
+		}, customWorkerPoolLabels,
+	)
+	workerPoolTaskRejectedCount_template.Register(reg)
+
+	// "of" - the name of the scheduled job
+	// "qualifier" - anything else your use case finds useful - or leave empty ""
+	customScheduledJobLabels := []string{"of", "qualifier"}
+
+	scheduledJobLastStartTime_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "scheduledJobLastStartTime",
+			Help:      "Scheduled job metric. Reports the unix timestamp (seconds) when the last run of the job started (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	scheduledJobLastStartTime_template.Register(reg)
+
+	scheduledJobLastSuccessTime_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "scheduledJobLastSuccessTime",
+			Help:      "Scheduled job metric. Reports the unix timestamp (seconds) when the last successful run of the job finished (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	scheduledJobLastSuccessTime_template.Register(reg)
+
+	scheduledJobSecondsSinceLastSuccess_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "scheduledJobSecondsSinceLastSuccess",
+			Help:      "Scheduled job metric. Reports the seconds elapsed since the last successful run of the job finished (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	// this one is not maintained by the jobs but calculated right before collecting
+	scheduledJobSecondsSinceLastSuccess_template.beforeCollect = refreshScheduledJobsSecondsSinceLastSuccess
+	scheduledJobSecondsSinceLastSuccess_template.Register(reg)
+
+	scheduledJobProcessingTime_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "scheduledJobProcessingTime",
+			Help:      "Scheduled job metric. Reports the time a run of the job took (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	scheduledJobProcessingTime_template.Register(reg)
+
+	scheduledJobRunCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "scheduledJobRunCount",
+			Help:      "Scheduled job metric. Reports count of runs of the job (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	scheduledJobRunCount_template.Register(reg)
+
+	scheduledJobFailedCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "scheduledJobFailedCount",
+			Help:      "Scheduled job metric. Reports count of failed runs of the job (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	scheduledJobFailedCount_template.Register(reg)
+
+	scheduledJobSkippedCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "scheduledJobSkippedCount",
+			Help:      "Scheduled job metric. Reports count of runs skipped because the previous run was still in progress (check 'of' attribute!)",
+		}, customScheduledJobLabels,
+	)
+	scheduledJobSkippedCount_template.Register(reg)
+
+	// "of", "qualifier", "clientId" - the same as in case of the client metrics, so circuit breaker metrics can be matched with the client metrics
+	customCircuitBreakerLabels := []string{"of", "qualifier", "clientId"}
+
+	circuitBreakerState_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "circuitBreakerState",
+			Help:      "Circuit breaker metric. Reports the current state of the circuit breaker - 0: closed, 1: half-open, 2: open (check 'of' attribute!)",
+		}, customCircuitBreakerLabels,
+	)
+	circuitBreakerState_template.Register(reg)
+
+	circuitBreakerFailureRate_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "circuitBreakerFailureRate",
+			Help:      "Circuit breaker metric. Reports the failure rate (0..1) of the calls in the current window of the circuit breaker (check 'of' attribute!)",
+		}, customCircuitBreakerLabels,
+	)
+	circuitBreakerFailureRate_template.Register(reg)
+
+	circuitBreakerRejectedCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "circuitBreakerRejectedCount",
+			Help:      "Circuit breaker metric. Reports count of calls rejected because the circuit was open (check 'of' attribute!)",
+		}, customCircuitBreakerLabels,
+	)
+	circuitBreakerRejectedCount_template.Register(reg)
+
+	// "fromState", "toState" - the state the circuit breaker has left and entered, e.g. "closed" -> "open"
+	circuitBreakerTransitionCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "circuitBreakerTransitionCount",
+			Help:      "Circuit breaker metric. Reports count of state transitions of the circuit breaker (check 'of' attribute!)",
+		}, append([]string{"fromState", "toState"}, customCircuitBreakerLabels...),
+	)
+	circuitBreakerTransitionCount_template.Register(reg)
+
+	// "of" - the name of the rate limiter
+	// "keyClass" - the class of keys the limit is applied to, e.g. "anonymous" or "premium" - do not put concrete user ids etc here!
+	customRateLimiterLabels := []string{"of", "keyClass"}
+
+	rateLimiterAllowedCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "rateLimiterAllowedCount",
+			Help:      "Rate limiter metric. Reports count of calls the rate limiter allowed (check 'of' attribute!)",
+		}, customRateLimiterLabels,
+	)
+	rateLimiterAllowedCount_template.Register(reg)
+
+	rateLimiterThrottledCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "rateLimiterThrottledCount",
+			Help:      "Rate limiter metric. Reports count of calls the rate limiter rejected (check 'of' attribute!)",
+		}, customRateLimiterLabels,
+	)
+	rateLimiterThrottledCount_template.Register(reg)
+
+	rateLimiterWaitTime_template = GetSummaryMetricTemplate(
+		prometheus.SummaryOpts{
+			Namespace: "",
+			Name:      "rateLimiterWaitTime",
+			Help:      "Rate limiter metric. Reports the time calls had to wait for a token (check 'of' attribute!)",
+		}, customRateLimiterLabels,
+	)
+	rateLimiterWaitTime_template.Register(reg)
+
+	rateLimiterTokens_template = GetGaugeMetricTemplate(
+		prometheus.GaugeOpts{
+			Namespace: "",
+			Name:      "rateLimiterTokens",
+			Help:      "Rate limiter metric. Reports the number of tokens currently available in the bucket (check 'of' attribute!)",
+		}, customRateLimiterLabels,
+	)
+	rateLimiterTokens_template.Register(reg)
+
+	// "of" - the name of the Logger the event was logged with (see LoggerNamePolicy)
+	// "level" - the level of the event: "error", "warn", "info" or "debug"
+	// "handler" - the kt_logging handler (output) which has written the event - if a Logger has more handlers then each event is counted once per handler
+	logEventCount_template = GetCounterMetricTemplate(
+		prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "logEventCount",
+			Help:      "Logging metric. Reports count of log events written (check 'of' attribute - that is the name of the logger - and 'level'!)",
+		}, []string{"of", "level", "handler"},
+	)
+	logEventCount_template.Register(reg)
+}
+
+// Returns the pre-defined template of the Counter which is counting how many times a label value combination was folded into the overflow series because the
+// cardinality limit of a template was reached. See MetricTemplate.SetCardinalityLimit()!
+func GetCardinalityLimitExceededTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return cardinalityLimitExceeded_template
 }
 
 // Returns a pre-defined template of a Counter which you can use to "count executions of something". Something which is part of your normal business logic. And you just want to be able to monitor it.
@@ -234,6 +612,24 @@ func GetClientRequestProcessingTimeTemplate() MetricTemplate {
 	return clientReqProcessingTime_template
 }
 
+// Returns a pre-defined Gauge template you can use in any synchronous clients (http, grpc, etc) to report "how many requests are in flight right now".
+func GetClientRequestInFlightTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return clientReqInFlight_template
+}
+
+// Returns a pre-defined template you can use in any synchronous clients (http, grpc, etc) to report "how big the payload of the specific req was".
+func GetClientRequestSizeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return clientReqSize_template
+}
+
+// Returns a pre-defined template you can use in any synchronous clients (http, grpc, etc) to report "how big the response payload of the specific req was".
+func GetClientResponseSizeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return clientRespSize_template
+}
+
 // Returns a pre-defined template you can use in servers (http, grpc, etc) to "count how many times a specific req has arrived".
 func GetServerServeStartedCountTemplate() MetricTemplate {
 	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
@@ -257,3 +653,162 @@ func GetServerServeProcessingTimeTemplate() MetricTemplate {
 	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
 	return serverServeProcessingTime_template
 }
+
+// Returns a pre-defined Gauge template you can use in servers (http, grpc, etc) to report "how many requests are being served right now".
+func GetServerServeInFlightTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return serverServeInFlight_template
+}
+
+// Returns a pre-defined template you can use in servers (http, grpc, etc) to report "how big the payload of the specific req was".
+func GetServerServeRequestSizeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return serverServeReqSize_template
+}
+
+// Returns a pre-defined template you can use in servers (http, grpc, etc) to report "how big the response payload of the specific req was".
+func GetServerServeResponseSizeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return serverServeRespSize_template
+}
+
+// Returns a pre-defined Gauge template you can use in worker pools to report "how many tasks are waiting in the queue".
+func GetWorkerPoolQueueDepthTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolQueueDepth_template
+}
+
+// Returns a pre-defined Gauge template you can use in worker pools to report "how many workers are busy right now".
+func GetWorkerPoolActiveWorkersTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolActiveWorkers_template
+}
+
+// Returns a pre-defined template you can use in worker pools to report "how much time a task was waiting in the queue".
+func GetWorkerPoolTaskWaitTimeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolTaskWaitTime_template
+}
+
+// Returns a pre-defined template you can use in worker pools to report "how much time the execution of a task took".
+func GetWorkerPoolTaskProcessingTimeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolTaskProcessingTime_template
+}
+
+// Returns a pre-defined template you can use in worker pools to "count how many tasks were executed".
+func GetWorkerPoolTaskExecCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolTaskExecCount_template
+}
+
+// Returns a pre-defined template you can use in worker pools to "count how many tasks have failed".
+func GetWorkerPoolTaskFailedCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolTaskFailedCount_template
+}
+
+// Returns a pre-defined template you can use in worker pools to "count how many tasks were rejected because the queue was full".
+func GetWorkerPoolTaskRejectedCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return workerPoolTaskRejectedCount_template
+}
+
+// Returns a pre-defined Gauge template you can use in scheduled jobs to report "when the last run started" (unix timestamp in seconds).
+func GetScheduledJobLastStartTimeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobLastStartTime_template
+}
+
+// Returns a pre-defined Gauge template you can use in scheduled jobs to report "when the last successful run finished" (unix timestamp in seconds).
+func GetScheduledJobLastSuccessTimeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobLastSuccessTime_template
+}
+
+// Returns a pre-defined Gauge template you can use in scheduled jobs to report "how many seconds elapsed since the last successful run".
+//
+// Please note: values of this template are only maintained for jobs tracked by a ScheduledJobMetricsSet!
+func GetScheduledJobSecondsSinceLastSuccessTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobSecondsSinceLastSuccess_template
+}
+
+// Returns a pre-defined template you can use in scheduled jobs to report "how much time a run took".
+func GetScheduledJobProcessingTimeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobProcessingTime_template
+}
+
+// Returns a pre-defined template you can use in scheduled jobs to "count how many times the job was run".
+func GetScheduledJobRunCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobRunCount_template
+}
+
+// Returns a pre-defined template you can use in scheduled jobs to "count how many runs of the job have failed".
+func GetScheduledJobFailedCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobFailedCount_template
+}
+
+// Returns a pre-defined template you can use in scheduled jobs to "count how many runs were skipped because the previous one was still running".
+func GetScheduledJobSkippedCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return scheduledJobSkippedCount_template
+}
+
+// Returns a pre-defined Gauge template you can use in circuit breakers to report "the current state" - 0: closed, 1: half-open, 2: open.
+func GetCircuitBreakerStateTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return circuitBreakerState_template
+}
+
+// Returns a pre-defined Gauge template you can use in circuit breakers to report "the failure rate in the current window".
+func GetCircuitBreakerFailureRateTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return circuitBreakerFailureRate_template
+}
+
+// Returns a pre-defined template you can use in circuit breakers to "count how many times the state has changed" (from one state to another).
+func GetCircuitBreakerTransitionCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return circuitBreakerTransitionCount_template
+}
+
+// Returns a pre-defined template you can use in circuit breakers to "count how many calls were rejected because the circuit was open".
+func GetCircuitBreakerRejectedCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return circuitBreakerRejectedCount_template
+}
+
+// Returns a pre-defined template you can use in rate limiters to "count how many calls were allowed".
+func GetRateLimiterAllowedCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return rateLimiterAllowedCount_template
+}
+
+// Returns a pre-defined template you can use in rate limiters to "count how many calls were throttled / rejected".
+func GetRateLimiterThrottledCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return rateLimiterThrottledCount_template
+}
+
+// Returns a pre-defined template you can use in rate limiters to report "how much time a call had to wait for a token".
+func GetRateLimiterWaitTimeTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return rateLimiterWaitTime_template
+}
+
+// Returns a pre-defined Gauge template you can use in rate limiters to report "how many tokens are available right now".
+func GetRateLimiterTokensTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return rateLimiterTokens_template
+}
+
+// Returns the pre-defined template of the Counter which is counting the log events per logger and level. You do not need to use it directly - see
+// InstallLogEventCounter()!
+func GetLogEventCountTemplate() MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+	return logEventCount_template
+}
@@ -3,6 +3,8 @@ package kt_observability_monitoring
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
@@ -18,6 +20,8 @@ var (
 
 	// The global key-value pairs used for each Metric - due to our Monitoring Standards
 	globalLabels map[string]any
+	// guards globalLabels and globalMetricLabels - they are replaced as a whole, never modified, so holding the lock while taking them is enough
+	globalLabelsLock sync.RWMutex
 
 	DefaultSummaryObjectives = map[float64]float64{
 		0:    0.02,
@@ -26,15 +30,24 @@ var (
 		0.99: 0.02,
 		1:    0.02,
 	}
+
+	// Summary templates keep the observations for this long - in DefaultSummaryAgeBuckets rotating buckets
+	DefaultSummaryMaxAge = 60 * time.Second
+	// The number of rotating buckets summary templates are using over DefaultSummaryMaxAge
+	DefaultSummaryAgeBuckets uint32 = 6
+
+	// Used by histogram templates if they do not define their buckets - as our time values are in millis these are good for typical processing times
+	DefaultHistogramBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
 )
 
-// Builds a list of Prometheus metric labels from the given key-value map
+// Builds a list of Prometheus metric labels from the given key-value map. The values are formatted with kt_observability.LabelValueString() - so they look
+// the same as in the log labels.
 func BuildMetricLabels(labels map[string]any) prometheus.Labels {
 
 	metricLabels := prometheus.Labels{}
 
 	for key, value := range labels {
-		metricLabels[key] = fmt.Sprintf("%v", value)
+		metricLabels[key] = kt_observability.LabelValueString(value)
 	}
 
 	return metricLabels
@@ -42,46 +55,140 @@ func BuildMetricLabels(labels map[string]any) prometheus.Labels {
 
 // returns the current GlobalLabels - key-value pairs attached to all log events
 func GetGlobalLabels() map[string]any {
+	globalLabelsLock.RLock()
+	defer globalLabelsLock.RUnlock()
 	return globalLabels
 }
 
 // you can change the GlobalLabels with this - the key-value pairs attached to all log events
 func SetGlobalLabels(labels map[string]any) {
-	globalLabels = labels
 	// transform immediately to Prometheus labels
-	globalMetricLabels = BuildMetricLabels(labels)
+	metricLabels := BuildMetricLabels(labels)
+
+	globalLabelsLock.Lock()
+	defer globalLabelsLock.Unlock()
+	globalLabels = labels
+	globalMetricLabels = metricLabels
+}
+
+// the current global labels as Prometheus labels - do not modify the returned map!
+func getGlobalMetricLabels() prometheus.Labels {
+	globalLabelsLock.RLock()
+	defer globalLabelsLock.RUnlock()
+	return globalMetricLabels
 }
 
 // Initializing the Prometheus MetricRegistry. After this 'MetricRegistry' is available and global metric labels are set according to our Monitoring Standards.
 // But feel free to change them via
 // GetGlobalLabels() and SetGlobalLabels() methods!
+//
+// If you invoke it again then the templates created so far are bound to the previous registry: they are forgotten (and not registered anymore) and the
+// predefined templates are re-created into the new registry on first use. Instances you took from the previous templates keep reporting into the previous
+// registry - so take them after InitMetrics().
 func InitMetrics() {
 	// let's create Metric registry
 	MetricRegistry = prometheus.NewRegistry()
+	forgetMetricTemplates()
 	// let's build up the global labels
 	globalLabelsMap := kt_observability.BuildGlobalLabelsMap()
 	SetGlobalLabels(globalLabelsMap)
+	// and the "buildInfo" gauge is always there
+	MetricRegistry.MustRegister(newBuildInfoCollector())
 }
 
-// You get back a struct like this when you invoke GetSummaryMetricTemplate(), GetCounterMetricTemplate() or GetGaugeMetricTemplate() methods.
+// You get back a struct like this when you invoke GetSummaryMetricTemplate(), GetCounterMetricTemplate(), GetGaugeMetricTemplate() or
+// GetHistogramMetricTemplate() methods.
 //
 // Once you created the template you can register it into a MetricRegistry using .Register() method of it.
-// After that you can use GetSummaryMetricInstance(), GetCounterMetricInstance() or GetGaugeMetricInstance() methods with corresponding parametrization
-// to get back a concrete instance of your metric which is ready to be used to collect insights.
+// After that you can use GetSummaryMetricInstance(), GetCounterMetricInstance(), GetGaugeMetricInstance() or GetHistogramMetricInstance() methods with
+// corresponding parametrization to get back a concrete instance of your metric which is ready to be used to collect insights.
 type MetricTemplate struct {
 	fullyQualifiedName string
 	customLabelNames   []string
 	metricType         string
+	help               string
+	// the quantiles of a summary / the buckets of a histogram - sorted, the tools generating alerts and dashboards need them
+	quantiles []float64
+	buckets   []float64
 
-	isRegistered bool
-	summaryVec   *prometheus.SummaryVec
+	summaryVec *prometheus.SummaryVec
 	//summaryOpts  *prometheus.SummaryOpts
-	counterVec *prometheus.CounterVec
-	gaugeVec   *prometheus.GaugeVec
+	counterVec   *prometheus.CounterVec
+	gaugeVec     *prometheus.GaugeVec
+	histogramVec *prometheus.HistogramVec
+
+	// optional - if set it is invoked every time right before the Metric instances of the template are collected
+	beforeCollect func()
+
+	// templates are passed around by value - everything which must be shared between the copies lives here
+	state *metricTemplateState
 
 	_LOGGER *kt_logging.Logger
 }
 
+// the mutable part of a MetricTemplate - shared by all copies of the template
+type metricTemplateState struct {
+	lock sync.Mutex
+
+	isRegistered atomic.Bool
+	// TRUE if we have to keep track of the label value combinations - so there is a cardinality limit or an instance TTL. If not then creating instances
+	// does not have to go through the lock
+	tracksInstances atomic.Bool
+	// TRUE if there are allowedLabelValues - same reason
+	hasAllowedLabelValues atomic.Bool
+
+	// max number of distinct label value combinations - 0 means no limit
+	cardinalityLimit int
+	// the label value combinations we have handed out instances for - key is built by instanceKey()
+	instances map[string]*metricInstanceEntry
+	// the series combinations are folded into once the cardinality limit is reached - if we have it
+	overflow *metricInstanceEntry
+	// when we have warned about exceeding the cardinality limit last time
+	cardinalityWarnedAt time.Time
+
+	// instances not touched for this long are deleted - 0 means they live forever
+	instanceTTL time.Duration
+	// closing it stops the goroutine deleting the expired instances - nil if there is no such goroutine
+	stopSweeping chan struct{}
+
+	// label name -> allowed values - values not allowed are replaced with OtherLabelValue
+	allowedLabelValues map[string]map[string]bool
+}
+
+// one label value combination of the template
+type metricInstanceEntry struct {
+	labels prometheus.Labels
+	// unix nanos
+	lastTouched atomic.Int64
+	// set once the instance was deleted - so whoever still holds it knows it has to get a new one
+	isDeleted atomic.Bool
+}
+
+func newMetricTemplateState() *metricTemplateState {
+	state := &metricTemplateState{
+		cardinalityLimit: max(DefaultCardinalityLimit, 0),
+		instances:        make(map[string]*metricInstanceEntry),
+	}
+	state.updateTracksInstances()
+	return state
+}
+
+// lock must be held (or the state must not be shared yet)!
+func (s *metricTemplateState) updateTracksInstances() {
+	s.tracksInstances.Store(s.cardinalityLimit > 0 || s.instanceTTL > 0)
+}
+
+// wraps a Collector so we can do something right before the Metric instances are collected
+type beforeCollectCollector struct {
+	prometheus.Collector
+	beforeCollect func()
+}
+
+func (c beforeCollectCollector) Collect(ch chan<- prometheus.Metric) {
+	c.beforeCollect()
+	c.Collector.Collect(ch)
+}
+
 func (tpl *MetricTemplate) FullyQualifiedName() string {
 	return tpl.fullyQualifiedName
 }
@@ -94,8 +201,13 @@ func (tpl *MetricTemplate) MetricType() string {
 	return tpl.metricType
 }
 
+func (tpl *MetricTemplate) Help() string {
+	return tpl.help
+}
+
+// Returns TRUE if the template was registered successfully - it does not matter on which copy of the template Register() was invoked
 func (tpl *MetricTemplate) IsRegistered() bool {
-	return tpl.isRegistered
+	return tpl.state != nil && tpl.state.isRegistered.Load()
 }
 
 // Use this method to register this template into a prometheus MetricRegistry.
@@ -106,16 +218,25 @@ func (tpl *MetricTemplate) Register(reg prometheus.Registerer) {
 	// if MetricRegistry was not initialized then the Registrer we get will point to a Nil instance - we have to detect that
 	isNil := reflect.ValueOf(reg).IsNil()
 	if !isNil {
+		var collector prometheus.Collector
 		switch tpl.metricType {
 		case "summary":
-			err = reg.Register(tpl.summaryVec)
+			collector = tpl.summaryVec
 		case "counter":
-			err = reg.Register(tpl.counterVec)
+			collector = tpl.counterVec
 		case "gauge":
-			err = reg.Register(tpl.gaugeVec)
+			collector = tpl.gaugeVec
+		case "histogram":
+			collector = tpl.histogramVec
 		default:
 			err = fmt.Errorf("unknown metric type: %v - don't know how to register", tpl.metricType)
 		}
+		if collector != nil {
+			if tpl.beforeCollect != nil {
+				collector = beforeCollectCollector{Collector: collector, beforeCollect: tpl.beforeCollect}
+			}
+			err = reg.Register(collector)
+		}
 	} else {
 		// oops it looks the registry was not initialized...
 		err = fmt.Errorf("registry is Nil... was MetricRegistry initialized?")
@@ -123,10 +244,56 @@ func (tpl *MetricTemplate) Register(reg prometheus.Registerer) {
 	if err != nil {
 		tpl._LOGGER.Warn("failed to register %v into registry - error: %v", tpl.ToString(), err)
 	} else {
-		tpl.isRegistered = true
+		tpl.state.isRegistered.Store(true)
+		rememberRegisteredMetricTemplate(*tpl)
 	}
 }
 
+// panics if the template is not of the expected type - and warns if it is not registered yet
+func (tpl *MetricTemplate) checkInstanceCreation(method string, expectedMetricType string) {
+	if tpl.metricType != expectedMetricType {
+		err := fmt.Sprintf("%v is invoked on %v but type of metric is different", method, tpl.ToString())
+		tpl._LOGGER.Error("ciritical error! app will panic - %v", err)
+		panic(err)
+	}
+	if !tpl.IsRegistered() {
+		tpl._LOGGER.Warn("%v: metric instance creation was invoked but this template was not registered yet...", tpl.ToString())
+	}
+}
+
+// panics if the number of values does not match the customLabelNames
+func (tpl *MetricTemplate) checkLabelValueCount(labelValues []string) {
+	// the last custom label is always the "metricType"
+	if len(labelValues) != len(tpl.customLabelNames)-1 {
+		err := fmt.Sprintf("%v: got %d label values but the template has %d custom labels %v", tpl.ToString(), len(labelValues), len(tpl.customLabelNames)-1, tpl.customLabelNames[:len(tpl.customLabelNames)-1])
+		tpl._LOGGER.Error("ciritical error! app will panic - %v", err)
+		panic(err)
+	}
+}
+
+// TRUE if the instances can be taken from the vec directly by the label values - so the template has no cardinality limit, no TTL and no allowed label
+// values which would need the labels as a map
+func (tpl *MetricTemplate) takesLabelValuesDirectly() bool {
+	return tpl.state == nil || (!tpl.state.tracksInstances.Load() && !tpl.state.hasAllowedLabelValues.Load())
+}
+
+// the values of the customLabelNames (in order) with the "metricType" - panics if the number of values does not match
+func (tpl *MetricTemplate) allLabelValues(labelValues []string) []string {
+	tpl.checkLabelValueCount(labelValues)
+	return append(labelValues[:len(labelValues):len(labelValues)], tpl.metricType)
+}
+
+// builds the labels from the values of the customLabelNames (in order) - panics if the number of values does not match
+func (tpl *MetricTemplate) labelsOfValues(labelValues []string) prometheus.Labels {
+	tpl.checkLabelValueCount(labelValues)
+	labels := make(prometheus.Labels, len(tpl.customLabelNames))
+	for i, value := range labelValues {
+		labels[tpl.customLabelNames[i]] = value
+	}
+	labels["metricType"] = tpl.metricType
+	return labels
+}
+
 func (tpl *MetricTemplate) ToString() string {
 	return fmt.Sprintf("MetricTemplate[metricType: %v, name: %v]", tpl.metricType, tpl.fullyQualifiedName)
 }
@@ -135,97 +302,186 @@ func (tpl *MetricTemplate) ToString() string {
 // customLabelNames by which filling them up with concrete values you will create your concrete metric instances.
 // See: GetSummaryMetricInstance() method!
 func GetSummaryMetricTemplate(opts prometheus.SummaryOpts, customLabelNames []string) MetricTemplate {
-	opts.ConstLabels = globalMetricLabels
-	opts.MaxAge = 60 * time.Second
-	opts.AgeBuckets = 6
 	opts.Objectives = DefaultSummaryObjectives
+	return newSummaryMetricTemplate(opts, customLabelNames)
+}
+
+// creates the template with the objectives given in the opts
+func newSummaryMetricTemplate(opts prometheus.SummaryOpts, customLabelNames []string) MetricTemplate {
+	opts.ConstLabels = getGlobalMetricLabels()
+	opts.MaxAge = DefaultSummaryMaxAge
+	opts.AgeBuckets = DefaultSummaryAgeBuckets
 
 	customLabelNames = append(customLabelNames, "metricType")
 
-	return MetricTemplate{
+	return rememberCreatedMetricTemplate(MetricTemplate{
 		fullyQualifiedName: prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		help:               opts.Help,
 		summaryVec:         prometheus.NewSummaryVec(opts, customLabelNames),
 		//summaryOpts:        &opts,
+		quantiles:        objectiveQuantiles(opts.Objectives),
 		customLabelNames: customLabelNames,
 		metricType:       "summary",
+		state:            newMetricTemplateState(),
 		_LOGGER:          kt_logging.GetLogger("keytiles.observability.monitoring.MetricTemplate"),
-	}
+	})
 }
 
 // Creates a concrete instance of a previously created Summary template by requiring you to provide concrete values
 // for the customLabelNames you created the template with.
 func GetSummaryMetricInstance(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Observer {
-	if metricTemplate.metricType != "summary" {
-		err := fmt.Sprintf(".GetSummaryMetricInstance() is invoked on %v but type of metric is different", metricTemplate.ToString())
-		metricTemplate._LOGGER.Error("ciritical error! app will panic - %v", err)
-		panic(err)
-	}
-	if !metricTemplate.isRegistered {
-		metricTemplate._LOGGER.Warn("%v: metric instance creation was invoked but this template was not registered yet...", metricTemplate.ToString())
-	}
+	metricTemplate.checkInstanceCreation(".GetSummaryMetricInstance()", "summary")
 	customLabels["metricType"] = metricTemplate.metricType
+	return metricTemplate.summaryInstance(BuildMetricLabels(customLabels))
+}
 
-	// this is not working for some reason
-	// summaryInstance := prometheus.NewSummary(*metricTemplate.summaryOpts)
-	// MetricRegistry.Register(summaryInstance)
-	// return summaryInstance
+// Same as GetSummaryMetricInstance() but you pass in the values of the customLabelNames in the same order you created the template with. This is faster
+// as there is no need for building a map (unless the template has a cardinality limit, an instance TTL or allowed label values - these need the labels as a
+// map) - and this is what the accessors generated by kt-metricsgen are using.
+func GetSummaryMetricInstanceByLabelValues(metricTemplate MetricTemplate, labelValues ...string) prometheus.Observer {
+	metricTemplate.checkInstanceCreation(".GetSummaryMetricInstanceByLabelValues()", "summary")
+	if metricTemplate.takesLabelValuesDirectly() {
+		return metricTemplate.summaryVec.WithLabelValues(metricTemplate.allLabelValues(labelValues)...)
+	}
+	return metricTemplate.summaryInstance(metricTemplate.labelsOfValues(labelValues))
+}
 
-	observerInstance := metricTemplate.summaryVec.With(BuildMetricLabels(customLabels))
-	return observerInstance
+func (tpl MetricTemplate) summaryInstance(labels prometheus.Labels) prometheus.Observer {
+	labels = tpl.guardCardinality(tpl.foldNotAllowedLabelValues(labels))
+	if tpl.InstanceTTL() > 0 {
+		return expiringObserver{newExpiringInstance(tpl, labels)}
+	}
+	return tpl.summaryVec.With(labels)
 }
 
 func GetCounterMetricTemplate(opts prometheus.CounterOpts, customLabelNames []string) MetricTemplate {
-	opts.ConstLabels = globalMetricLabels
+	opts.ConstLabels = getGlobalMetricLabels()
 
 	customLabelNames = append(customLabelNames, "metricType")
 
-	return MetricTemplate{
+	return rememberCreatedMetricTemplate(MetricTemplate{
 		fullyQualifiedName: prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		help:               opts.Help,
 		counterVec:         prometheus.NewCounterVec(opts, customLabelNames),
 		customLabelNames:   customLabelNames,
 		metricType:         "counter",
+		state:              newMetricTemplateState(),
 		_LOGGER:            kt_logging.GetLogger("keytiles.observability.monitoring.MetricTemplate"),
-	}
+	})
 }
 
 func GetCounterMetricInstance(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Counter {
-	if metricTemplate.metricType != "counter" {
-		err := fmt.Sprintf(".GetCounterMetricInstance() is invoked on %v but type of metric is different", metricTemplate.ToString())
-		metricTemplate._LOGGER.Error("ciritical error! app will panic - %v", err)
-		panic(err)
-	}
-	if !metricTemplate.isRegistered {
-		metricTemplate._LOGGER.Warn("%v: metric instance creation was invoked but this template was not registered yet...", metricTemplate.ToString())
+	metricTemplate.checkInstanceCreation(".GetCounterMetricInstance()", "counter")
+	customLabels["metricType"] = metricTemplate.metricType
+	return metricTemplate.counterInstance(BuildMetricLabels(customLabels))
+}
+
+// Same as GetCounterMetricInstance() but you pass in the values of the customLabelNames in the same order you created the template with. This is faster
+// as there is no need for building a map (unless the template has a cardinality limit, an instance TTL or allowed label values - these need the labels as a
+// map) - and this is what the accessors generated by kt-metricsgen are using.
+func GetCounterMetricInstanceByLabelValues(metricTemplate MetricTemplate, labelValues ...string) prometheus.Counter {
+	metricTemplate.checkInstanceCreation(".GetCounterMetricInstanceByLabelValues()", "counter")
+	if metricTemplate.takesLabelValuesDirectly() {
+		return metricTemplate.counterVec.WithLabelValues(metricTemplate.allLabelValues(labelValues)...)
 	}
+	return metricTemplate.counterInstance(metricTemplate.labelsOfValues(labelValues))
+}
 
-	customLabels["metricType"] = metricTemplate.metricType
-	return metricTemplate.counterVec.With(BuildMetricLabels(customLabels))
+func (tpl MetricTemplate) counterInstance(labels prometheus.Labels) prometheus.Counter {
+	labels = tpl.guardCardinality(tpl.foldNotAllowedLabelValues(labels))
+	if tpl.InstanceTTL() > 0 {
+		return expiringCounter{newExpiringInstance(tpl, labels)}
+	}
+	return tpl.counterVec.With(labels)
 }
 
 func GetGaugeMetricTemplate(opts prometheus.GaugeOpts, customLabelNames []string) MetricTemplate {
-	opts.ConstLabels = globalMetricLabels
+	opts.ConstLabels = getGlobalMetricLabels()
 
 	customLabelNames = append(customLabelNames, "metricType")
 
-	return MetricTemplate{
+	return rememberCreatedMetricTemplate(MetricTemplate{
 		fullyQualifiedName: prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		help:               opts.Help,
 		gaugeVec:           prometheus.NewGaugeVec(opts, customLabelNames),
 		customLabelNames:   customLabelNames,
 		metricType:         "gauge",
+		state:              newMetricTemplateState(),
 		_LOGGER:            kt_logging.GetLogger("keytiles.observability.monitoring.MetricTemplate"),
-	}
+	})
 }
 
 func GetGaugeMetricInstance(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Gauge {
-	if metricTemplate.metricType != "gauge" {
-		err := fmt.Sprintf(".GetGaugeMetricInstance() is invoked on %v but type of metric is different", metricTemplate.ToString())
-		metricTemplate._LOGGER.Error("ciritical error! app will panic - %v", err)
-		panic(err)
+	metricTemplate.checkInstanceCreation(".GetGaugeMetricInstance()", "gauge")
+	customLabels["metricType"] = metricTemplate.metricType
+	return metricTemplate.gaugeInstance(BuildMetricLabels(customLabels))
+}
+
+// Same as GetGaugeMetricInstance() but you pass in the values of the customLabelNames in the same order you created the template with. This is faster
+// as there is no need for building a map (unless the template has a cardinality limit, an instance TTL or allowed label values - these need the labels as a
+// map) - and this is what the accessors generated by kt-metricsgen are using.
+func GetGaugeMetricInstanceByLabelValues(metricTemplate MetricTemplate, labelValues ...string) prometheus.Gauge {
+	metricTemplate.checkInstanceCreation(".GetGaugeMetricInstanceByLabelValues()", "gauge")
+	if metricTemplate.takesLabelValuesDirectly() {
+		return metricTemplate.gaugeVec.WithLabelValues(metricTemplate.allLabelValues(labelValues)...)
 	}
-	if !metricTemplate.isRegistered {
-		metricTemplate._LOGGER.Warn("%v: metric instance creation was invoked but this template was not registered yet...", metricTemplate.ToString())
+	return metricTemplate.gaugeInstance(metricTemplate.labelsOfValues(labelValues))
+}
+
+func (tpl MetricTemplate) gaugeInstance(labels prometheus.Labels) prometheus.Gauge {
+	labels = tpl.guardCardinality(tpl.foldNotAllowedLabelValues(labels))
+	if tpl.InstanceTTL() > 0 {
+		return expiringGauge{newExpiringInstance(tpl, labels)}
 	}
+	return tpl.gaugeVec.With(labels)
+}
 
+// Creates a new Histogram metric type template which is already using all GlobalMetricLabels plus you can pass in a set of customLabelNames by which
+// filling them up with concrete values you will create your concrete metric instances. If the opts have no Buckets then DefaultHistogramBuckets is used.
+// See: GetHistogramMetricInstance() method!
+func GetHistogramMetricTemplate(opts prometheus.HistogramOpts, customLabelNames []string) MetricTemplate {
+	opts.ConstLabels = getGlobalMetricLabels()
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = DefaultHistogramBuckets
+	}
+
+	customLabelNames = append(customLabelNames, "metricType")
+
+	return rememberCreatedMetricTemplate(MetricTemplate{
+		fullyQualifiedName: prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		help:               opts.Help,
+		histogramVec:       prometheus.NewHistogramVec(opts, customLabelNames),
+		buckets:            sortedCopy(opts.Buckets),
+		customLabelNames:   customLabelNames,
+		metricType:         "histogram",
+		state:              newMetricTemplateState(),
+		_LOGGER:            kt_logging.GetLogger("keytiles.observability.monitoring.MetricTemplate"),
+	})
+}
+
+// Creates a concrete instance of a previously created Histogram template by requiring you to provide concrete values
+// for the customLabelNames you created the template with.
+func GetHistogramMetricInstance(metricTemplate MetricTemplate, customLabels map[string]any) prometheus.Observer {
+	metricTemplate.checkInstanceCreation(".GetHistogramMetricInstance()", "histogram")
 	customLabels["metricType"] = metricTemplate.metricType
-	return metricTemplate.gaugeVec.With(BuildMetricLabels(customLabels))
+	return metricTemplate.histogramInstance(BuildMetricLabels(customLabels))
+}
+
+// Same as GetHistogramMetricInstance() but you pass in the values of the customLabelNames in the same order you created the template with. This is faster
+// as there is no need for building a map (unless the template has a cardinality limit, an instance TTL or allowed label values - these need the labels as a
+// map) - and this is what the accessors generated by kt-metricsgen are using.
+func GetHistogramMetricInstanceByLabelValues(metricTemplate MetricTemplate, labelValues ...string) prometheus.Observer {
+	metricTemplate.checkInstanceCreation(".GetHistogramMetricInstanceByLabelValues()", "histogram")
+	if metricTemplate.takesLabelValuesDirectly() {
+		return metricTemplate.histogramVec.WithLabelValues(metricTemplate.allLabelValues(labelValues)...)
+	}
+	return metricTemplate.histogramInstance(metricTemplate.labelsOfValues(labelValues))
+}
+
+func (tpl MetricTemplate) histogramInstance(labels prometheus.Labels) prometheus.Observer {
+	labels = tpl.guardCardinality(tpl.foldNotAllowedLabelValues(labels))
+	if tpl.InstanceTTL() > 0 {
+		return expiringObserver{newExpiringInstance(tpl, labels)}
+	}
+	return tpl.histogramVec.With(labels)
 }
@@ -0,0 +1,232 @@
+package kt_observability_monitoring
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A token bucket rate limiter - fully instrumented with the standard rate limiter Metrics.
+//
+// The limiter maintains a separate bucket for each "key class" you use. Key classes are also used as a label in the Metrics so use a few well known classes
+// (e.g. "anonymous", "authenticated", "premium") and not concrete user ids or similar!
+//
+// The number of key classes is bounded anyways (see WithRateLimiterMaxKeyClasses()) - once the limit is reached new key classes share one "other" bucket. And
+// the buckets of key classes which were not used for a while (see WithRateLimiterKeyClassTTL()) are dropped - together with their Metric instances, so the
+// exposed series do not grow with the churn of key classes either.
+//
+// You can use it inline in your code with Allow() or Wait() - or in front of your HTTP handlers using HttpMiddleware(). The methods are safe for concurrent use.
+type RateLimiter struct {
+	of string
+	// tokens added per second
+	rate float64
+	// the size of the bucket
+	burst float64
+	// max number of buckets - 0 means no limit
+	maxKeyClasses int
+	// full buckets not used for this long are dropped - 0 means they live forever
+	keyClassTTL time.Duration
+
+	lock           sync.Mutex
+	bucketsByClass map[string]*rateLimiterBucket
+	// when we have dropped the unused buckets last time
+	sweptAt time.Time
+}
+
+type rateLimiterBucket struct {
+	tokens    float64
+	updatedAt time.Time
+
+	// so we can delete the Metric instances of the bucket when it is dropped
+	created          createdMetricInstances
+	allowedCounter   prometheus.Counter
+	throttledCounter prometheus.Counter
+	waitTime         prometheus.Observer
+	tokensGauge      prometheus.Gauge
+}
+
+type RateLimiterOpt func(l *RateLimiter)
+
+// The max number of key classes the limiter keeps a bucket for - once it is reached new key classes share one extra bucket of OtherLabelValue (and are
+// reported with that "keyClass" too). Default is 100. Pass in 0 to remove the limit.
+func WithRateLimiterMaxKeyClasses(maxKeyClasses int) RateLimiterOpt {
+	return func(l *RateLimiter) {
+		if maxKeyClasses >= 0 {
+			l.maxKeyClasses = maxKeyClasses
+		}
+	}
+}
+
+// The buckets of key classes not used for this long are dropped (once they have refilled - so dropping them does not change what the limiter allows).
+// Default is 10 minutes. Pass in 0 to keep the buckets forever.
+func WithRateLimiterKeyClassTTL(ttl time.Duration) RateLimiterOpt {
+	return func(l *RateLimiter) {
+		if ttl >= 0 {
+			l.keyClassTTL = ttl
+		}
+	}
+}
+
+// Creates a new rate limiter which allows "ratePerSecond" calls per second on average with bursts up to "burst" calls - per key class.
+//
+// Pass in "of" as the best name (meaningful) of the thing the limiter protects!
+func NewRateLimiter(of string, ratePerSecond float64, burst int, opts ...RateLimiterOpt) *RateLimiter {
+	if of == "" {
+		panic("Can not create RateLimiter with empty 'of' parameter!")
+	}
+	if ratePerSecond <= 0 || burst < 1 {
+		panic("Can not create RateLimiter - ratePerSecond must be positive and burst must be at least 1!")
+	}
+
+	l := &RateLimiter{
+		of:             of,
+		rate:           ratePerSecond,
+		burst:          float64(burst),
+		maxKeyClasses:  100,
+		keyClassTTL:    10 * time.Minute,
+		bucketsByClass: make(map[string]*rateLimiterBucket),
+		sweptAt:        time.Now(),
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	return l
+}
+
+// returns the bucket of the key class refilled to the current time - lock must be held!
+func (l *RateLimiter) getBucket(keyClass string) *rateLimiterBucket {
+	if keyClass == "" {
+		keyClass = "-"
+	}
+
+	now := time.Now()
+	b, found := l.bucketsByClass[keyClass]
+	if !found {
+		l.dropUnusedBuckets(now)
+		if l.maxKeyClasses > 0 && len(l.bucketsByClass) >= l.maxKeyClasses {
+			keyClass = OtherLabelValue
+			b, found = l.bucketsByClass[keyClass]
+		}
+	}
+	if !found {
+		b = &rateLimiterBucket{tokens: l.burst, updatedAt: now}
+		b.allowedCounter = b.created.counter(GetRateLimiterAllowedCountTemplate(), l.bucketLabels(keyClass))
+		b.throttledCounter = b.created.counter(GetRateLimiterThrottledCountTemplate(), l.bucketLabels(keyClass))
+		b.waitTime = b.created.summary(GetRateLimiterWaitTimeTemplate(), l.bucketLabels(keyClass))
+		b.tokensGauge = b.created.gauge(GetRateLimiterTokensTemplate(), l.bucketLabels(keyClass))
+		l.bucketsByClass[keyClass] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate)
+	b.updatedAt = now
+	return b
+}
+
+// a new map every time - the instances remember the labels they were created with
+func (l *RateLimiter) bucketLabels(keyClass string) map[string]any {
+	return map[string]any{"of": l.of, "keyClass": keyClass}
+}
+
+// drops the buckets not used for keyClassTTL which are full anyways - not more often than keyClassTTL, or once a second if we are at the limit. Lock must be
+// held!
+func (l *RateLimiter) dropUnusedBuckets(now time.Time) {
+	if l.keyClassTTL == 0 {
+		return
+	}
+	sweepInterval := l.keyClassTTL
+	if l.maxKeyClasses > 0 && len(l.bucketsByClass) >= l.maxKeyClasses {
+		sweepInterval = min(sweepInterval, time.Second)
+	}
+	if now.Sub(l.sweptAt) < sweepInterval {
+		return
+	}
+	l.sweptAt = now
+	for keyClass, b := range l.bucketsByClass {
+		idleFor := now.Sub(b.updatedAt)
+		if idleFor >= l.keyClassTTL && b.tokens+idleFor.Seconds()*l.rate >= l.burst {
+			delete(l.bucketsByClass, keyClass)
+			// the series would stay exposed with stale values otherwise - if the key class comes back it starts from scratch
+			b.created.deleteAll()
+		}
+	}
+}
+
+// Takes a token for the key class if there is one available right now. Returns TRUE if the call is allowed, FALSE if it should be throttled.
+func (l *RateLimiter) Allow(keyClass string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	b := l.getBucket(keyClass)
+	if b.tokens < 1 {
+		b.throttledCounter.Inc()
+		b.tokensGauge.Set(b.tokens)
+		return false
+	}
+	b.tokens--
+	b.allowedCounter.Inc()
+	b.tokensGauge.Set(b.tokens)
+	return true
+}
+
+// Blocks until a token for the key class is available (and takes it) or the context is done. The time spent waiting is recorded. Returns the error of the
+// context if it is done before a token became available - this case is counted as throttled.
+func (l *RateLimiter) Wait(ctx context.Context, keyClass string) error {
+	startedAt := time.Now()
+	for {
+		l.lock.Lock()
+		b := l.getBucket(keyClass)
+		if b.tokens >= 1 {
+			b.tokens--
+			b.allowedCounter.Inc()
+			b.tokensGauge.Set(b.tokens)
+			b.waitTime.Observe(float64(time.Since(startedAt)) / float64(time.Millisecond))
+			l.lock.Unlock()
+			return nil
+		}
+		b.tokensGauge.Set(b.tokens)
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		l.lock.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			l.lock.Lock()
+			b.throttledCounter.Inc()
+			b.waitTime.Observe(float64(time.Since(startedAt)) / float64(time.Millisecond))
+			l.lock.Unlock()
+			return ctx.Err()
+		case <-timer.C:
+			// let's try again - others might have taken the token meanwhile
+		}
+	}
+}
+
+// Returns a middleware you can put in front of your HTTP handlers. Requests which are throttled get a 429 (Too Many Requests) response and are not passed to
+// the handler.
+//
+// The keyClassFunc decides which key class the request belongs to - if nil then all requests are in the same class. If you pass in the HttpServerLazyMetricsSet
+// of the handler then the throttled requests are recorded in that too (as started and failed with "429" statusCode).
+func (l *RateLimiter) HttpMiddleware(serverMetrics *HttpServerLazyMetricsSet, keyClassFunc func(req *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			keyClass := "-"
+			if keyClassFunc != nil {
+				keyClass = keyClassFunc(req)
+			}
+			if !l.Allow(keyClass) {
+				if serverMetrics != nil {
+					serverMetrics.ServeStarted(req)
+					serverMetrics.ServeFailed(req, "429")
+				}
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
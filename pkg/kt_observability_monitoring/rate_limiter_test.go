@@ -0,0 +1,126 @@
+package kt_observability_monitoring
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	InitMetrics()
+	// practically no refill during the test
+	limiter := NewRateLimiter("rlAllowTest", 0.001, 2)
+
+	for i, want := range []bool{true, true, false} {
+		if got := limiter.Allow("anonymous"); got != want {
+			t.Errorf("call #%d: expected %v, got %v", i+1, want, got)
+		}
+	}
+	// the other key class has its own bucket
+	if !limiter.Allow("premium") {
+		t.Errorf("the premium key class must have its own bucket")
+	}
+
+	labels := map[string]string{"of": "rlAllowTest", "keyClass": "anonymous"}
+	if got := metricValue(t, GetRateLimiterAllowedCountTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 allowed calls, got %v", got)
+	}
+	if got := metricValue(t, GetRateLimiterThrottledCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 throttled call, got %v", got)
+	}
+	if got := metricValue(t, GetRateLimiterTokensTemplate(), labels); got >= 1 {
+		t.Errorf("expected less than 1 token, got %v", got)
+	}
+}
+
+func TestRateLimiterBoundsTheKeyClasses(t *testing.T) {
+	InitMetrics()
+	limiter := NewRateLimiter("rlMaxKeyClassesTest", 0.001, 1, WithRateLimiterMaxKeyClasses(2))
+
+	limiter.Allow("a")
+	limiter.Allow("b")
+	limiter.Allow("c")
+	// "c" and "d" are sharing the bucket of "other" - so "d" is throttled already
+	if limiter.Allow("d") {
+		t.Errorf("the key classes over the limit must share one bucket")
+	}
+	if got := metricValue(t, GetRateLimiterAllowedCountTemplate(), map[string]string{"of": "rlMaxKeyClassesTest", "keyClass": OtherLabelValue}); got != 1 {
+		t.Errorf("expected 1 allowed call of %v, got %v", OtherLabelValue, got)
+	}
+}
+
+func TestRateLimiterDropsUnusedKeyClassesWithTheirMetrics(t *testing.T) {
+	InitMetrics()
+	ttl := 20 * time.Millisecond
+	// refills in 1ms - so the bucket of "a" is full by the time it expires
+	limiter := NewRateLimiter("rlEvictionTest", 1000, 1, WithRateLimiterKeyClassTTL(ttl))
+
+	limiter.Allow("a")
+	labelsOfA := map[string]string{"of": "rlEvictionTest", "keyClass": "a"}
+	if got := metricValue(t, GetRateLimiterAllowedCountTemplate(), labelsOfA); got != 1 {
+		t.Fatalf("expected 1 allowed call, got %v", got)
+	}
+
+	time.Sleep(2 * ttl)
+	// a new key class triggers the sweep
+	limiter.Allow("b")
+
+	limiter.lock.Lock()
+	_, found := limiter.bucketsByClass["a"]
+	limiter.lock.Unlock()
+	if found {
+		t.Errorf("the bucket of the unused key class must be dropped")
+	}
+	for _, tpl := range []MetricTemplate{
+		GetRateLimiterAllowedCountTemplate(), GetRateLimiterThrottledCountTemplate(), GetRateLimiterWaitTimeTemplate(), GetRateLimiterTokensTemplate(),
+	} {
+		if metrics := collectMetrics(t, tpl, labelsOfA); len(metrics) != 0 {
+			t.Errorf("%v: the series of the dropped key class must be deleted", tpl.fullyQualifiedName)
+		}
+	}
+
+	// if it comes back it starts from scratch
+	limiter.Allow("a")
+	if got := metricValue(t, GetRateLimiterAllowedCountTemplate(), labelsOfA); got != 1 {
+		t.Errorf("expected 1 allowed call after the key class came back, got %v", got)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	InitMetrics()
+	// a new token in 20ms
+	limiter := NewRateLimiter("rlWaitTest", 50, 1)
+	limiter.Allow("-")
+
+	startedAt := time.Now()
+	if err := limiter.Wait(context.Background(), "-"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if waited := time.Since(startedAt); waited < 10*time.Millisecond {
+		t.Errorf("expected to wait for the next token, waited only %v", waited)
+	}
+
+	labels := map[string]string{"of": "rlWaitTest", "keyClass": "-"}
+	if got := metricValue(t, GetRateLimiterWaitTimeTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 wait time observation, got %v", got)
+	}
+	if got := metricValue(t, GetRateLimiterAllowedCountTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 allowed calls, got %v", got)
+	}
+}
+
+func TestRateLimiterWaitGivesUpWithTheContext(t *testing.T) {
+	InitMetrics()
+	limiter := NewRateLimiter("rlWaitTimeoutTest", 0.001, 1)
+	limiter.Allow("-")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, "-"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error of the context, got %v", err)
+	}
+	if got := metricValue(t, GetRateLimiterThrottledCountTemplate(), map[string]string{"of": "rlWaitTimeoutTest", "keyClass": "-"}); got != 1 {
+		t.Errorf("expected 1 throttled call, got %v", got)
+	}
+}
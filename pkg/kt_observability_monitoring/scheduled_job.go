@@ -0,0 +1,208 @@
+package kt_observability_monitoring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Returned by ScheduledJobMetricsSet.Run() if the run was skipped because the previous run was still in progress
+var ErrScheduledJobSkipped = errors.New("scheduled job run skipped - previous run is still in progress")
+
+var (
+	// the "seconds since last success" instances of the sets - so we can calculate them when Metrics are collected. Key is the ownedInstanceKey() of the
+	// instance. The sets are not referenced from here - a set nobody holds anymore can be garbage collected, only its Metric instances stay (just like the
+	// ones of any other set until it is closed)
+	scheduledJobLastSuccesses     = map[string]*scheduledJobLastSuccess{}
+	scheduledJobLastSuccessesLock sync.Mutex
+)
+
+type scheduledJobLastSuccess struct {
+	secondsSinceGauge prometheus.Gauge
+	// unix millis
+	at atomic.Int64
+	// how many sets are using it - sets with the same labels are sharing it, just like they share the Metric instances
+	sets int
+}
+
+// If you have periodic jobs (e.g. a loop driven by a time.Ticker, or a cron like scheduler) you can use this class to attach the standard scheduled job Metrics
+// to them.
+//
+// The easiest is to run your job through Run() method - that records everything. But if this does not fit your code you can also invoke the RunStarted(),
+// RunSucceeded(), RunFailed() and RunSkipped() methods yourself.
+//
+// Beyond the counters and the run time the set maintains the "last start" and "last success" timestamps plus the "seconds since last success" gauge - so
+// the standard staleness alerts can be built on top of it. Until the first successful run "seconds since last success" is counted from the creation of the set.
+//
+// The methods are safe for concurrent use.
+type ScheduledJobMetricsSet struct {
+	of        string
+	qualifier any
+
+	isRunning   atomic.Bool
+	lastSuccess *scheduledJobLastSuccess
+
+	created createdMetricInstances
+
+	lastStartTimeGauge           prometheus.Gauge
+	lastSuccessTimeGauge         prometheus.Gauge
+	secondsSinceLastSuccessGauge prometheus.Gauge
+	processingTime               prometheus.Observer
+	runCounter                   prometheus.Counter
+	failedCounter                prometheus.Counter
+	skippedCounter               prometheus.Counter
+}
+
+type ScheduledJobMetricsSetOpt func(m *ScheduledJobMetricsSet)
+
+// Creates a new metrics set for a scheduled job.
+//
+// Pass in "of" as the best name (meaningful) of the job! And feel free to use the optional setup too!
+func NewScheduledJobMetricsSet(of string, opts ...ScheduledJobMetricsSetOpt) *ScheduledJobMetricsSet {
+	if of == "" {
+		panic("Can not create ScheduledJobMetricsSet with empty 'of' parameter!")
+	}
+
+	metrics := &ScheduledJobMetricsSet{
+		of:        of,
+		qualifier: "-",
+	}
+
+	for _, o := range opts {
+		o(metrics)
+	}
+
+	metrics.lastStartTimeGauge = metrics.created.gauge(GetScheduledJobLastStartTimeTemplate(), metrics.labels())
+	metrics.lastSuccessTimeGauge = metrics.created.gauge(GetScheduledJobLastSuccessTimeTemplate(), metrics.labels())
+	metrics.secondsSinceLastSuccessGauge = metrics.created.gauge(GetScheduledJobSecondsSinceLastSuccessTemplate(), metrics.labels())
+	metrics.processingTime = metrics.created.summary(GetScheduledJobProcessingTimeTemplate(), metrics.labels())
+	metrics.runCounter = metrics.created.counter(GetScheduledJobRunCountTemplate(), metrics.labels())
+	metrics.failedCounter = metrics.created.counter(GetScheduledJobFailedCountTemplate(), metrics.labels())
+	metrics.skippedCounter = metrics.created.counter(GetScheduledJobSkippedCountTemplate(), metrics.labels())
+
+	metrics.lastSuccess = rememberScheduledJobLastSuccess(metrics.secondsSinceLastSuccessGauge, metrics.labels())
+
+	return metrics
+}
+
+func rememberScheduledJobLastSuccess(secondsSinceGauge prometheus.Gauge, labels map[string]any) *scheduledJobLastSuccess {
+	key := ownedInstanceKey(GetScheduledJobSecondsSinceLastSuccessTemplate(), labels)
+
+	scheduledJobLastSuccessesLock.Lock()
+	defer scheduledJobLastSuccessesLock.Unlock()
+	lastSuccess, found := scheduledJobLastSuccesses[key]
+	if !found {
+		lastSuccess = &scheduledJobLastSuccess{secondsSinceGauge: secondsSinceGauge}
+		lastSuccess.at.Store(time.Now().UnixMilli())
+		scheduledJobLastSuccesses[key] = lastSuccess
+	}
+	lastSuccess.sets++
+	return lastSuccess
+}
+
+// the sets created before are not refreshed anymore - the registry of their Metric instances is gone
+func forgetScheduledJobLastSuccesses() {
+	scheduledJobLastSuccessesLock.Lock()
+	defer scheduledJobLastSuccessesLock.Unlock()
+	scheduledJobLastSuccesses = map[string]*scheduledJobLastSuccess{}
+}
+
+// Assigns a "qualifier" to all Metric instances in your set of your choice.
+func WithScheduledJobQualifier(qualifier any) ScheduledJobMetricsSetOpt {
+	return func(m *ScheduledJobMetricsSet) {
+		if qualifier != nil {
+			m.qualifier = qualifier
+		}
+	}
+}
+
+func (m *ScheduledJobMetricsSet) labels() map[string]any {
+	return map[string]any{"of": m.of, "qualifier": m.qualifier}
+}
+
+// Deletes all the Metric instances of the set - so they are not exposed anymore. Invoke it if the job is gone for good - the set must not be used after this!
+func (m *ScheduledJobMetricsSet) Close() {
+	key := ownedInstanceKey(GetScheduledJobSecondsSinceLastSuccessTemplate(), m.labels())
+	scheduledJobLastSuccessesLock.Lock()
+	// it might be from a previous registry already - see forgetScheduledJobLastSuccesses()
+	if scheduledJobLastSuccesses[key] == m.lastSuccess {
+		m.lastSuccess.sets--
+		if m.lastSuccess.sets == 0 {
+			delete(scheduledJobLastSuccesses, key)
+		}
+	}
+	scheduledJobLastSuccessesLock.Unlock()
+
+	m.created.deleteAll()
+}
+
+// invoked before the "seconds since last success" Metrics are collected
+func refreshScheduledJobsSecondsSinceLastSuccess() {
+	scheduledJobLastSuccessesLock.Lock()
+	defer scheduledJobLastSuccessesLock.Unlock()
+
+	for _, lastSuccess := range scheduledJobLastSuccesses {
+		lastSuccess.refresh()
+	}
+}
+
+func (s *scheduledJobLastSuccess) refresh() {
+	elapsedMillis := time.Now().UnixMilli() - s.at.Load()
+	s.secondsSinceGauge.Set(float64(elapsedMillis) / 1000)
+}
+
+// Invoke when a run of the job started - will set the "last start" timestamp and increase the run counter
+func (m *ScheduledJobMetricsSet) RunStarted() {
+	m.lastStartTimeGauge.SetToCurrentTime()
+	m.runCounter.Inc()
+}
+
+// Invoke when a run of the job succeeded - pass in how many millis the run took. Will set the "last success" timestamp.
+func (m *ScheduledJobMetricsSet) RunSucceeded(tookMillis float64) {
+	m.processingTime.Observe(tookMillis)
+	m.lastSuccess.at.Store(time.Now().UnixMilli())
+	m.lastSuccessTimeGauge.SetToCurrentTime()
+	m.lastSuccess.refresh()
+}
+
+// Invoke when a run of the job failed - pass in how many millis the run took. Will increase the failure counter.
+func (m *ScheduledJobMetricsSet) RunFailed(tookMillis float64) {
+	m.processingTime.Observe(tookMillis)
+	m.failedCounter.Inc()
+}
+
+// Invoke when a run of the job was skipped (e.g. because the previous run is still in progress) - will increase the skipped counter
+func (m *ScheduledJobMetricsSet) RunSkipped() {
+	m.skippedCounter.Inc()
+}
+
+// Runs the job and records everything about it. If the previous Run() is still in progress the run is skipped (and counted) and ErrScheduledJobSkipped is
+// returned. Otherwise the error the job returned is returned. A panic in the job is recorded as a failure and then re-panicked.
+func (m *ScheduledJobMetricsSet) Run(ctx context.Context, job func(ctx context.Context) error) (err error) {
+	if !m.isRunning.CompareAndSwap(false, true) {
+		m.RunSkipped()
+		return ErrScheduledJobSkipped
+	}
+	defer m.isRunning.Store(false)
+
+	m.RunStarted()
+	startedAt := time.Now()
+	defer func() {
+		tookMillis := float64(time.Since(startedAt)) / float64(time.Millisecond)
+		if r := recover(); r != nil {
+			m.RunFailed(tookMillis)
+			panic(r)
+		}
+		if err != nil {
+			m.RunFailed(tookMillis)
+		} else {
+			m.RunSucceeded(tookMillis)
+		}
+	}()
+
+	return job(ctx)
+}
@@ -0,0 +1,175 @@
+package kt_observability_monitoring
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// gathers the MetricRegistry - so the "seconds since last success" gauges are refreshed
+func gather(t *testing.T) {
+	t.Helper()
+	if _, err := MetricRegistry.Gather(); err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+}
+
+func TestScheduledJobRun(t *testing.T) {
+	InitMetrics()
+	set := NewScheduledJobMetricsSet("jobRunTest")
+	labels := map[string]string{"of": "jobRunTest"}
+
+	beforeRun := float64(time.Now().Unix())
+	if err := set.Run(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	failure := errors.New("failed")
+	if err := set.Run(context.Background(), func(ctx context.Context) error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("expected the error of the job, got %v", err)
+	}
+
+	if got := metricValue(t, GetScheduledJobRunCountTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 runs, got %v", got)
+	}
+	if got := metricValue(t, GetScheduledJobFailedCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 failed run, got %v", got)
+	}
+	if got := metricValue(t, GetScheduledJobProcessingTimeTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 processing time observations, got %v", got)
+	}
+	if got := metricValue(t, GetScheduledJobLastStartTimeTemplate(), labels); got < beforeRun {
+		t.Errorf("expected the last start time to be set, got %v", got)
+	}
+	// only the successful run counts
+	if got := metricValue(t, GetScheduledJobLastSuccessTimeTemplate(), labels); got < beforeRun {
+		t.Errorf("expected the last success time to be set, got %v", got)
+	}
+}
+
+func TestScheduledJobSkipsOverlappingRuns(t *testing.T) {
+	InitMetrics()
+	set := NewScheduledJobMetricsSet("jobSkipTest")
+	labels := map[string]string{"of": "jobSkipTest"}
+
+	started := make(chan bool)
+	release := make(chan bool)
+	done := make(chan error)
+	go func() {
+		done <- set.Run(context.Background(), func(ctx context.Context) error { started <- true; <-release; return nil })
+	}()
+	<-started
+	if err := set.Run(context.Background(), func(ctx context.Context) error { return nil }); !errors.Is(err, ErrScheduledJobSkipped) {
+		t.Errorf("expected ErrScheduledJobSkipped, got %v", err)
+	}
+	close(release)
+	<-done
+
+	// once the previous one finished the next run goes
+	if err := set.Run(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("expected the next run to go, got %v", err)
+	}
+	if got := metricValue(t, GetScheduledJobSkippedCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 skipped run, got %v", got)
+	}
+	if got := metricValue(t, GetScheduledJobRunCountTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 runs, got %v", got)
+	}
+}
+
+func TestScheduledJobRecordsPanicAsFailure(t *testing.T) {
+	InitMetrics()
+	set := NewScheduledJobMetricsSet("jobPanicTest")
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Errorf("the panic must go on - got %v", recovered)
+			}
+		}()
+		set.Run(context.Background(), func(ctx context.Context) error { panic("boom") })
+	}()
+
+	if got := metricValue(t, GetScheduledJobFailedCountTemplate(), map[string]string{"of": "jobPanicTest"}); got != 1 {
+		t.Errorf("expected 1 failed run, got %v", got)
+	}
+	// and it is not running anymore
+	if err := set.Run(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("expected the next run to go, got %v", err)
+	}
+}
+
+func TestScheduledJobSecondsSinceLastSuccess(t *testing.T) {
+	InitMetrics()
+	set := NewScheduledJobMetricsSet("jobSinceSuccessTest")
+	labels := map[string]string{"of": "jobSinceSuccessTest"}
+
+	// pretending the last success was 5 seconds ago
+	set.lastSuccess.at.Store(time.Now().Add(-5 * time.Second).UnixMilli())
+	gather(t)
+	if got := metricValue(t, GetScheduledJobSecondsSinceLastSuccessTemplate(), labels); math.Abs(got-5) > 1 {
+		t.Errorf("expected ~5 seconds since the last success, got %v", got)
+	}
+
+	set.Run(context.Background(), func(ctx context.Context) error { return nil })
+	gather(t)
+	if got := metricValue(t, GetScheduledJobSecondsSinceLastSuccessTemplate(), labels); got > 1 {
+		t.Errorf("expected ~0 seconds right after a success, got %v", got)
+	}
+}
+
+func TestScheduledJobCloseStopsRefreshing(t *testing.T) {
+	InitMetrics()
+	first := NewScheduledJobMetricsSet("jobCloseTest")
+	second := NewScheduledJobMetricsSet("jobCloseTest")
+	labels := map[string]string{"of": "jobCloseTest"}
+
+	first.Close()
+	if got := len(collectMetrics(t, GetScheduledJobSecondsSinceLastSuccessTemplate(), labels)); got != 1 {
+		t.Errorf("the second set still holds the series - expected 1, got %v", got)
+	}
+	second.Close()
+	if got := len(collectMetrics(t, GetScheduledJobSecondsSinceLastSuccessTemplate(), labels)); got != 0 {
+		t.Errorf("expected the series to be deleted, got %v", got)
+	}
+	scheduledJobLastSuccessesLock.Lock()
+	defer scheduledJobLastSuccessesLock.Unlock()
+	for _, lastSuccess := range scheduledJobLastSuccesses {
+		if lastSuccess == first.lastSuccess {
+			t.Errorf("the closed sets must not be refreshed anymore")
+		}
+	}
+}
+
+func TestScheduledJobSetsCanBeGarbageCollected(t *testing.T) {
+	InitMetrics()
+	collected := make(chan bool, 1)
+	func() {
+		set := NewScheduledJobMetricsSet("jobGcTest")
+		runtime.SetFinalizer(set, func(*ScheduledJobMetricsSet) { collected <- true })
+	}()
+
+	eventually(t, "the set nobody holds to be garbage collected", func() bool {
+		runtime.GC()
+		select {
+		case <-collected:
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+func TestInitMetricsForgetsTheScheduledJobs(t *testing.T) {
+	InitMetrics()
+	NewScheduledJobMetricsSet("jobForgetTest")
+
+	InitMetrics()
+	scheduledJobLastSuccessesLock.Lock()
+	defer scheduledJobLastSuccessesLock.Unlock()
+	if len(scheduledJobLastSuccesses) != 0 {
+		t.Errorf("the sets of the previous registry must not be refreshed anymore - got %v", len(scheduledJobLastSuccesses))
+	}
+}
@@ -0,0 +1,79 @@
+package kt_observability_monitoring
+
+// A StatusCodePolicy decides what value ends up in the "statusCode" label of the Metrics the HTTP lazy sets create - it gets the statusCode the caller has
+// passed in and returns the label value.
+//
+// This way the cardinality of the "statusCode" label is under central control - and callers do not have to do the bucketing by hand.
+type StatusCodePolicy func(httpStatusCode string) string
+
+// The StatusCodePolicy the HTTP lazy sets are using if you do not assign one explicitly with WithHttpClientStatusCodePolicy() or
+// WithHttpServerStatusCodePolicy(). By default this is ExactStatusCodes() but you can change it centrally - before the sets are created.
+var DefaultStatusCodePolicy StatusCodePolicy = ExactStatusCodes()
+
+// The statusCode is used as it is - e.g. "200", "404", "503"
+func ExactStatusCodes() StatusCodePolicy {
+	return func(httpStatusCode string) string {
+		return httpStatusCode
+	}
+}
+
+// Only the class of the statusCode is used - e.g. "200" becomes "2xx", "404" becomes "4xx". Classes (like "2xx") and "-" (no statusCode) are used as they
+// are - anything else (including codes out of the 100-599 range like "600" or "099") becomes OtherLabelValue.
+func StatusCodeClasses() StatusCodePolicy {
+	return func(httpStatusCode string) string {
+		switch {
+		case httpStatusCode == "-" || isStatusCodeClass(httpStatusCode):
+			return httpStatusCode
+		case isStatusCode(httpStatusCode):
+			return httpStatusCode[0:1] + "xx"
+		default:
+			return OtherLabelValue
+		}
+	}
+}
+
+// Only the listed statusCodes are used as they are - any other value becomes OtherLabelValue, except "-" (no statusCode) which is used as it is. You can also
+// list classes e.g. "5xx" - in this case every statusCode in that class (not listed explicitly) is reported as the class.
+func AllowListedStatusCodes(allowedStatusCodes ...string) StatusCodePolicy {
+	allowed := make(map[string]bool, len(allowedStatusCodes))
+	for _, code := range allowedStatusCodes {
+		allowed[code] = true
+	}
+	return func(httpStatusCode string) string {
+		if httpStatusCode == "-" || allowed[httpStatusCode] {
+			return httpStatusCode
+		}
+		if isStatusCode(httpStatusCode) {
+			if class := httpStatusCode[0:1] + "xx"; allowed[class] {
+				return class
+			}
+		}
+		return OtherLabelValue
+	}
+}
+
+// 3 digits between 100 and 599 - the classes isStatusCodeClass() accepts
+func isStatusCode(httpStatusCode string) bool {
+	if len(httpStatusCode) != 3 || httpStatusCode[0] < '1' || httpStatusCode[0] > '5' {
+		return false
+	}
+	for _, c := range httpStatusCode {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// e.g. "2xx" or "5xx"
+func isStatusCodeClass(httpStatusCode string) bool {
+	return len(httpStatusCode) == 3 && httpStatusCode[0] >= '1' && httpStatusCode[0] <= '5' && httpStatusCode[1:] == "xx"
+}
+
+// applies the policy - nil policy means ExactStatusCodes()
+func (p StatusCodePolicy) apply(httpStatusCode string) string {
+	if p == nil {
+		return httpStatusCode
+	}
+	return p(httpStatusCode)
+}
@@ -0,0 +1,75 @@
+package kt_observability_monitoring
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusCodePolicies(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		policy     StatusCodePolicy
+		statusCode string
+		want       string
+	}{
+		{"exact", ExactStatusCodes(), "404", "404"},
+		{"exact anything", ExactStatusCodes(), "whatever", "whatever"},
+		{"nil is exact", nil, "404", "404"},
+
+		{"classes 1xx", StatusCodeClasses(), "101", "1xx"},
+		{"classes 2xx", StatusCodeClasses(), "200", "2xx"},
+		{"classes 5xx", StatusCodeClasses(), "599", "5xx"},
+		{"classes keeps class", StatusCodeClasses(), "4xx", "4xx"},
+		{"classes keeps no statusCode", StatusCodeClasses(), "-", "-"},
+		{"classes above range", StatusCodeClasses(), "600", OtherLabelValue},
+		{"classes below range", StatusCodeClasses(), "099", OtherLabelValue},
+		{"classes class above range", StatusCodeClasses(), "6xx", OtherLabelValue},
+		{"classes class below range", StatusCodeClasses(), "0xx", OtherLabelValue},
+		{"classes too short", StatusCodeClasses(), "20", OtherLabelValue},
+		{"classes too long", StatusCodeClasses(), "2000", OtherLabelValue},
+		{"classes not a number", StatusCodeClasses(), "2a0", OtherLabelValue},
+		{"classes empty", StatusCodeClasses(), "", OtherLabelValue},
+
+		{"allow-listed code", AllowListedStatusCodes("200", "404"), "404", "404"},
+		{"allow-listed not listed", AllowListedStatusCodes("200", "404"), "403", OtherLabelValue},
+		{"allow-listed no statusCode", AllowListedStatusCodes("200"), "-", "-"},
+		{"allow-listed class", AllowListedStatusCodes("503", "5xx"), "500", "5xx"},
+		{"allow-listed code wins over class", AllowListedStatusCodes("503", "5xx"), "503", "503"},
+		{"allow-listed class itself", AllowListedStatusCodes("5xx"), "5xx", "5xx"},
+		{"allow-listed out of range", AllowListedStatusCodes("6xx"), "600", OtherLabelValue},
+		{"allow-listed garbage", AllowListedStatusCodes("2xx"), "2ab", OtherLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.apply(tc.statusCode); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStatusCodeClassesAreAcceptedAsTheyAreProduced(t *testing.T) {
+	policy := StatusCodeClasses()
+	for code := 0; code < 1000; code++ {
+		statusCode := string([]byte{byte('0' + code/100), byte('0' + code/10%10), byte('0' + code%10)})
+		class := policy(statusCode)
+		// whatever a statusCode becomes must stay the same if it goes through the policy again
+		if again := policy(class); again != class {
+			t.Fatalf("%v became %v - and then %v", statusCode, class, again)
+		}
+	}
+}
+
+func TestSetsApplyTheStatusCodePolicy(t *testing.T) {
+	InitMetrics()
+	server := NewHttpServerLazyMetricsSet("statusCodePolicyTest", WithHttpServerStatusCodePolicy(StatusCodeClasses()))
+	server.ServeSucceeded(httptest.NewRequest("GET", "/", nil), "204")
+	client := NewHttpClientLazyMetricsSet("statusCodePolicyTest", WithHttpClientStatusCodePolicy(AllowListedStatusCodes("404")))
+	client.RequestFailed("503")
+
+	if got := metricValue(t, GetServerServeSucceededCountTemplate(), map[string]string{"of": "statusCodePolicyTest", "statusCode": "2xx"}); got != 1 {
+		t.Errorf("expected 1 succeeded request with 2xx, got %v", got)
+	}
+	if got := metricValue(t, GetClientRequestFailedCountTemplate(), map[string]string{"of": "statusCodePolicyTest", "statusCode": OtherLabelValue}); got != 1 {
+		t.Errorf("expected 1 failed request with %v, got %v", OtherLabelValue, got)
+	}
+}
@@ -0,0 +1,128 @@
+package kt_observability_monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// One entry of the template catalog - see ListTemplates()
+type MetricTemplateStatus struct {
+	MetricTemplateInfo
+	IsRegistered bool `json:"isRegistered"`
+	// how many label value combinations (so Metric instances) are live right now
+	InstanceCount int `json:"instanceCount"`
+	// 0 means no limit
+	CardinalityLimit int `json:"cardinalityLimit"`
+	// the live label value combinations (custom labels only) - only filled if you asked for them
+	Instances []map[string]string `json:"instances,omitempty"`
+}
+
+// Returns all the templates created in this service since the last InitMetrics() - registered or not (the predefined ones are included too) - sorted by
+// name. This is useful to debug "why is my metric not showing" situations e.g. a template which was never registered.
+//
+// If withInstances is TRUE then the live label value combinations are listed too - this can be a long list for templates with high cardinality!
+func ListTemplates(withInstances bool) []MetricTemplateStatus {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+
+	createdMetricTemplatesLock.Lock()
+	templates := make([]MetricTemplate, 0, len(createdMetricTemplates))
+	for _, tpl := range createdMetricTemplates {
+		templates = append(templates, tpl)
+	}
+	createdMetricTemplatesLock.Unlock()
+
+	statuses := make([]MetricTemplateStatus, 0, len(templates))
+	for _, tpl := range templates {
+		status := MetricTemplateStatus{
+			MetricTemplateInfo: tpl.Info(),
+			IsRegistered:       tpl.IsRegistered(),
+			InstanceCount:      tpl.InstanceCount(),
+			CardinalityLimit:   tpl.CardinalityLimit(),
+		}
+		if withInstances {
+			status.Instances = tpl.liveInstanceLabels()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// the custom label values of the live instances - the overflow series included, sorted. They are taken from the underlying vec - as templates without
+// cardinality limit and TTL do not keep track of their instances.
+func (tpl *MetricTemplate) liveInstanceLabels() []map[string]string {
+	var collector prometheus.Collector
+	switch tpl.metricType {
+	case "summary":
+		collector = tpl.summaryVec
+	case "counter":
+		collector = tpl.counterVec
+	case "gauge":
+		collector = tpl.gaugeVec
+	case "histogram":
+		collector = tpl.histogramVec
+	}
+	if collector == nil || reflect.ValueOf(collector).IsNil() {
+		return nil
+	}
+
+	customLabelNames := make(map[string]bool, len(tpl.customLabelNames))
+	for _, name := range tpl.customLabelNames {
+		if name != "metricType" {
+			customLabelNames[name] = true
+		}
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collector.Collect(ch)
+		close(ch)
+	}()
+	keys := []string{}
+	instancesByKey := make(map[string]map[string]string)
+	for metric := range ch {
+		var out dto.Metric
+		if err := metric.Write(&out); err != nil {
+			continue
+		}
+		values := make(map[string]string, len(customLabelNames))
+		for _, pair := range out.GetLabel() {
+			if customLabelNames[pair.GetName()] {
+				values[pair.GetName()] = pair.GetValue()
+			}
+		}
+		key := tpl.instanceKey(values)
+		if _, found := instancesByKey[key]; !found {
+			keys = append(keys, key)
+			instancesByKey[key] = values
+		}
+	}
+
+	sort.Strings(keys)
+	instances := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		instances = append(instances, instancesByKey[key])
+	}
+	return instances
+}
+
+// Returns an HTTP handler serving the template catalog (see ListTemplates()) as JSON - you can expose it next to /metrics. Add "?instances=true" to the
+// request to get the live label value combinations too.
+func MetricTemplatesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		withInstances := req.URL.Query().Get("instances") == "true"
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ListTemplates(withInstances)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
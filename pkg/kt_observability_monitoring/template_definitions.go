@@ -0,0 +1,242 @@
+package kt_observability_monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	metricNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegex  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// the templates created by LoadMetricTemplates() - key is the fully qualified name
+	loadedMetricTemplates     = make(map[string]MetricTemplate)
+	loadedMetricTemplatesLock sync.Mutex
+)
+
+// for json/yaml template definitions file parsing - this is the root level object
+type MetricTemplateDefinitionsModel struct {
+	Templates []MetricTemplateDefinitionModel `json:"templates" yaml:"templates"`
+}
+
+// for json/yaml template definitions file parsing - this is the entries in /templates path
+type MetricTemplateDefinitionModel struct {
+	// "summary", "counter", "gauge" or "histogram"
+	Type      string `json:"type" yaml:"type"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Subsystem string `json:"subsystem" yaml:"subsystem"`
+	Name      string `json:"name" yaml:"name"`
+	Help      string `json:"help" yaml:"help"`
+	// the custom label names - the global labels and "metricType" are added automatically
+	Labels []string `json:"labels" yaml:"labels"`
+	// only for "histogram" - if not given then DefaultHistogramBuckets is used
+	Buckets []float64 `json:"buckets" yaml:"buckets"`
+	// only for "summary" - if not given then DefaultSummaryObjectives is used
+	Objectives []SummaryObjectiveModel `json:"objectives" yaml:"objectives"`
+	// label name -> allowed values, see MetricTemplate.SetAllowedLabelValues()
+	AllowedLabelValues map[string][]string `json:"allowedLabelValues" yaml:"allowedLabelValues"`
+}
+
+// for json/yaml template definitions file parsing - one quantile of a summary with its allowed error
+type SummaryObjectiveModel struct {
+	Quantile float64 `json:"quantile" yaml:"quantile"`
+	Error    float64 `json:"error" yaml:"error"`
+}
+
+// Returns the fully qualified name of the defined template - this is the name the Metric is exposed with
+func (def MetricTemplateDefinitionModel) FullyQualifiedName() string {
+	return prometheus.BuildFQName(def.Namespace, def.Subsystem, def.Name)
+}
+
+// Parses the template definitions from the given file path - which must be either JSON or Yaml file. The definitions are also validated.
+func ParseMetricTemplateDefinitions(defsPath string) (MetricTemplateDefinitionsModel, error) {
+
+	byteValue, readErr := os.ReadFile(defsPath)
+	if readErr != nil {
+		return MetricTemplateDefinitionsModel{}, fmt.Errorf("failed to read metric template definitions! error was: %v", readErr)
+	}
+
+	var defs MetricTemplateDefinitionsModel
+
+	// json or yaml?
+	extension := path.Ext(strings.ToLower(defsPath))
+	switch extension {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(byteValue, &defs); err != nil {
+			return MetricTemplateDefinitionsModel{}, fmt.Errorf("failed to parse metric template definitions! error was: %v", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(byteValue, &defs); err != nil {
+			return MetricTemplateDefinitionsModel{}, fmt.Errorf("failed to parse metric template definitions! error was: %v", err)
+		}
+	default:
+		return MetricTemplateDefinitionsModel{}, fmt.Errorf("unknown metric template definitions file extension '%v'! Only .json or .yaml is supported!", extension)
+	}
+
+	if err := defs.Validate(); err != nil {
+		return MetricTemplateDefinitionsModel{}, err
+	}
+	return defs, nil
+}
+
+// Checks the definitions - returns all problems found joined into one error, or nil if the definitions are fine
+func (defs MetricTemplateDefinitionsModel) Validate() error {
+	var errs []error
+	seenNames := make(map[string]bool)
+	globalMetricLabels := getGlobalMetricLabels()
+
+	for i, def := range defs.Templates {
+		fqName := def.FullyQualifiedName()
+		where := fmt.Sprintf("template #%d (%v)", i+1, fqName)
+
+		if def.Name == "" {
+			errs = append(errs, fmt.Errorf("%v: 'name' is missing", where))
+		} else if !metricNameRegex.MatchString(fqName) {
+			errs = append(errs, fmt.Errorf("%v: '%v' is not a valid metric name", where, fqName))
+		} else if seenNames[fqName] {
+			errs = append(errs, fmt.Errorf("%v: the name is defined more than once", where))
+		}
+		seenNames[fqName] = true
+
+		switch def.Type {
+		case "summary", "counter", "gauge", "histogram":
+		default:
+			errs = append(errs, fmt.Errorf("%v: unknown 'type' '%v' - must be one of summary, counter, gauge, histogram", where, def.Type))
+		}
+		if len(def.Buckets) > 0 && def.Type != "histogram" {
+			errs = append(errs, fmt.Errorf("%v: 'buckets' can be used only with histogram type", where))
+		}
+		if len(def.Objectives) > 0 && def.Type != "summary" {
+			errs = append(errs, fmt.Errorf("%v: 'objectives' can be used only with summary type", where))
+		}
+		for i := 1; i < len(def.Buckets); i++ {
+			if def.Buckets[i] <= def.Buckets[i-1] {
+				errs = append(errs, fmt.Errorf("%v: 'buckets' must be strictly increasing but %v is followed by %v", where, def.Buckets[i-1], def.Buckets[i]))
+				break
+			}
+		}
+		for _, objective := range def.Objectives {
+			if objective.Quantile < 0 || objective.Quantile > 1 {
+				errs = append(errs, fmt.Errorf("%v: objective quantile %v is out of the [0, 1] range", where, objective.Quantile))
+			}
+			if objective.Error <= 0 || objective.Error >= 1 {
+				errs = append(errs, fmt.Errorf("%v: objective error %v must be between 0 and 1 (exclusive)", where, objective.Error))
+			}
+		}
+
+		labelNames := make(map[string]bool)
+		for _, labelName := range def.Labels {
+			switch {
+			case !labelNameRegex.MatchString(labelName) || strings.HasPrefix(labelName, "__"):
+				errs = append(errs, fmt.Errorf("%v: '%v' is not a valid label name", where, labelName))
+			case labelName == "metricType":
+				errs = append(errs, fmt.Errorf("%v: label 'metricType' is added automatically - do not list it", where))
+			case labelNames[labelName]:
+				errs = append(errs, fmt.Errorf("%v: label '%v' is listed more than once", where, labelName))
+			}
+			if _, isGlobal := globalMetricLabels[labelName]; isGlobal {
+				errs = append(errs, fmt.Errorf("%v: label '%v' is a global label already", where, labelName))
+			}
+			labelNames[labelName] = true
+		}
+		for labelName := range def.AllowedLabelValues {
+			if !labelNames[labelName] {
+				errs = append(errs, fmt.Errorf("%v: 'allowedLabelValues' refers to label '%v' which is not in 'labels'", where, labelName))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Creates the templates from the definitions and registers them into the given registry. Returns the templates - key is the fully qualified name.
+func (defs MetricTemplateDefinitionsModel) BuildMetricTemplates(reg prometheus.Registerer) (map[string]MetricTemplate, error) {
+	if err := defs.Validate(); err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]MetricTemplate, len(defs.Templates))
+	for _, def := range defs.Templates {
+		tpl := def.buildMetricTemplate()
+		tpl.Register(reg)
+		if !tpl.IsRegistered() {
+			return templates, fmt.Errorf("failed to register %v - see the logs for details", tpl.ToString())
+		}
+		templates[tpl.FullyQualifiedName()] = tpl
+	}
+	return templates, nil
+}
+
+func (def MetricTemplateDefinitionModel) buildMetricTemplate() MetricTemplate {
+	// we must not modify the definition
+	labelNames := append([]string{}, def.Labels...)
+
+	var tpl MetricTemplate
+	switch def.Type {
+	case "summary":
+		opts := prometheus.SummaryOpts{Namespace: def.Namespace, Subsystem: def.Subsystem, Name: def.Name, Help: def.Help, Objectives: DefaultSummaryObjectives}
+		if len(def.Objectives) > 0 {
+			opts.Objectives = make(map[float64]float64, len(def.Objectives))
+			for _, objective := range def.Objectives {
+				opts.Objectives[objective.Quantile] = objective.Error
+			}
+		}
+		tpl = newSummaryMetricTemplate(opts, labelNames)
+	case "counter":
+		tpl = GetCounterMetricTemplate(prometheus.CounterOpts{Namespace: def.Namespace, Subsystem: def.Subsystem, Name: def.Name, Help: def.Help}, labelNames)
+	case "gauge":
+		tpl = GetGaugeMetricTemplate(prometheus.GaugeOpts{Namespace: def.Namespace, Subsystem: def.Subsystem, Name: def.Name, Help: def.Help}, labelNames)
+	case "histogram":
+		opts := prometheus.HistogramOpts{Namespace: def.Namespace, Subsystem: def.Subsystem, Name: def.Name, Help: def.Help, Buckets: def.Buckets}
+		tpl = GetHistogramMetricTemplate(opts, labelNames)
+	}
+
+	// sorted so the outcome does not depend on map iteration order
+	allowedLabelNames := make([]string, 0, len(def.AllowedLabelValues))
+	for labelName := range def.AllowedLabelValues {
+		allowedLabelNames = append(allowedLabelNames, labelName)
+	}
+	sort.Strings(allowedLabelNames)
+	for _, labelName := range allowedLabelNames {
+		tpl.SetAllowedLabelValues(labelName, def.AllowedLabelValues[labelName]...)
+	}
+	return tpl
+}
+
+// Loads the template definitions from the given JSON or Yaml file then creates and registers the templates into the global MetricRegistry - so invoke it
+// after InitMetrics() (and after you have set your global labels). This way the standard templates of your team can live in one shared file.
+//
+// Returns the templates - key is the fully qualified name. You can also get them later with GetLoadedMetricTemplate().
+func LoadMetricTemplates(defsPath string) (map[string]MetricTemplate, error) {
+	defs, err := ParseMetricTemplateDefinitions(defsPath)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := defs.BuildMetricTemplates(MetricRegistry)
+
+	loadedMetricTemplatesLock.Lock()
+	for name, tpl := range templates {
+		loadedMetricTemplates[name] = tpl
+	}
+	loadedMetricTemplatesLock.Unlock()
+
+	return templates, err
+}
+
+// Returns a template previously created by LoadMetricTemplates() - by its fully qualified name. The bool is FALSE if there is no such template.
+func GetLoadedMetricTemplate(fullyQualifiedName string) (MetricTemplate, bool) {
+	loadedMetricTemplatesLock.Lock()
+	defer loadedMetricTemplatesLock.Unlock()
+	tpl, found := loadedMetricTemplates[fullyQualifiedName]
+	return tpl, found
+}
@@ -0,0 +1,173 @@
+package kt_observability_monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDefinitions(t *testing.T, fileName string, content string) string {
+	t.Helper()
+	defsPath := filepath.Join(t.TempDir(), fileName)
+	if err := os.WriteFile(defsPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %v: %v", defsPath, err)
+	}
+	return defsPath
+}
+
+func TestParseMetricTemplateDefinitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+		// substring of the expected error - empty if no error is expected
+		wantErr string
+	}{
+		{
+			name:     "valid yaml",
+			fileName: "defs.yaml",
+			content: `templates:
+  - type: histogram
+    name: defsTestTime
+    labels: [of]
+    buckets: [10, 100, 500]
+  - type: summary
+    name: defsTestLatency
+    objectives:
+      - {quantile: 0.99, error: 0.001}
+`,
+		},
+		{
+			name:     "valid json",
+			fileName: "defs.json",
+			content:  `{"templates": [{"type": "counter", "name": "defsTestCount", "labels": ["of"], "allowedLabelValues": {"of": ["a"]}}]}`,
+		},
+		{
+			name:     "unknown extension",
+			fileName: "defs.txt",
+			content:  `templates: []`,
+			wantErr:  "unknown metric template definitions file extension",
+		},
+		{
+			name:     "broken yaml",
+			fileName: "defs.yaml",
+			content:  `templates: [`,
+			wantErr:  "failed to parse metric template definitions",
+		},
+		{
+			name:     "buckets not in increasing order",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: histogram, name: defsTestTime, buckets: [500, 100]}\n",
+			wantErr:  "'buckets' must be strictly increasing",
+		},
+		{
+			name:     "duplicated bucket",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: histogram, name: defsTestTime, buckets: [10, 10, 100]}\n",
+			wantErr:  "'buckets' must be strictly increasing",
+		},
+		{
+			name:     "buckets with non histogram",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: counter, name: defsTestCount, buckets: [10]}\n",
+			wantErr:  "'buckets' can be used only with histogram type",
+		},
+		{
+			name:     "objective quantile out of range",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: summary, name: defsTestLatency, objectives: [{quantile: 1.5, error: 0.01}]}\n",
+			wantErr:  "objective quantile 1.5 is out of the [0, 1] range",
+		},
+		{
+			name:     "objective error zero",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: summary, name: defsTestLatency, objectives: [{quantile: 0.5, error: 0}]}\n",
+			wantErr:  "objective error 0 must be between 0 and 1",
+		},
+		{
+			name:     "objective error too big",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: summary, name: defsTestLatency, objectives: [{quantile: 0.5, error: 1}]}\n",
+			wantErr:  "objective error 1 must be between 0 and 1",
+		},
+		{
+			name:     "unknown type",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: meter, name: defsTestCount}\n",
+			wantErr:  "unknown 'type' 'meter'",
+		},
+		{
+			name:     "missing name",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: counter}\n",
+			wantErr:  "'name' is missing",
+		},
+		{
+			name:     "name defined twice",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: counter, name: defsTestCount}\n  - {type: gauge, name: defsTestCount}\n",
+			wantErr:  "the name is defined more than once",
+		},
+		{
+			name:     "invalid labels",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: counter, name: defsTestCount, labels: [of, of, metricType, __x, 1a]}\n",
+			wantErr:  "label 'of' is listed more than once",
+		},
+		{
+			name:     "allowed values of an unknown label",
+			fileName: "defs.yaml",
+			content:  "templates:\n  - {type: counter, name: defsTestCount, labels: [of], allowedLabelValues: {qualifier: [a]}}\n",
+			wantErr:  "refers to label 'qualifier' which is not in 'labels'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseMetricTemplateDefinitions(writeDefinitions(t, test.fileName, test.content))
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case test.wantErr != "" && err == nil:
+				t.Errorf("expected error containing %q, got nil", test.wantErr)
+			case test.wantErr != "" && !strings.Contains(err.Error(), test.wantErr):
+				t.Errorf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	defs := MetricTemplateDefinitionsModel{Templates: []MetricTemplateDefinitionModel{
+		{Type: "histogram", Name: "defsTestTime", Buckets: []float64{5, 1}},
+		{Type: "summary", Name: "defsTestLatency", Objectives: []SummaryObjectiveModel{{Quantile: 0.5, Error: -0.1}}},
+	}}
+	err := defs.Validate()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, want := range []string{"template #1 (defsTestTime)", "template #2 (defsTestLatency)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error mentioning %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadMetricTemplates(t *testing.T) {
+	InitMetrics()
+	defsPath := writeDefinitions(t, "defs.yaml", `templates:
+  - type: histogram
+    name: defsTestLoadedTime
+    labels: [of]
+    buckets: [10, 100]
+`)
+	templates, err := LoadMetricTemplates(defsPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tpl, found := GetLoadedMetricTemplate("defsTestLoadedTime")
+	if !found || !tpl.IsRegistered() || len(templates) != 1 {
+		t.Fatalf("expected the loaded template to be registered")
+	}
+	GetHistogramMetricInstance(tpl, map[string]any{"of": "a"}).Observe(50)
+}
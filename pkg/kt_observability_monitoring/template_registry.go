@@ -0,0 +1,193 @@
+package kt_observability_monitoring
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	// all templates created since the last InitMetrics() - registered or not. Key is the fully qualified name, if a template is created again with the same
+	// name then the latest one wins - unless the previous one is registered, that is kept until the new one gets registered (if ever)
+	createdMetricTemplates     = make(map[string]MetricTemplate)
+	createdMetricTemplatesLock sync.Mutex
+
+	// all templates which were successfully registered - key is the fully qualified name
+	registeredMetricTemplates     = make(map[string]MetricTemplate)
+	registeredMetricTemplatesLock sync.Mutex
+)
+
+// Describes a template - the tools generating code, dashboards, alerts etc. are working with this so they can take the templates from a definitions file or
+// from the registered ones the same way
+type MetricTemplateInfo struct {
+	Name       string `json:"name"`
+	MetricType string `json:"metricType"`
+	Help       string `json:"help"`
+	// the custom label names - without the global labels and the "metricType" label
+	LabelNames []string `json:"labelNames"`
+	// only for "summary" - the quantiles the summary reports, sorted
+	Quantiles []float64 `json:"quantiles,omitempty"`
+	// only for "histogram" - the upper bounds of the buckets, sorted
+	Buckets []float64 `json:"buckets,omitempty"`
+}
+
+// Returns the description of the template
+func (tpl *MetricTemplate) Info() MetricTemplateInfo {
+	return MetricTemplateInfo{
+		Name:       tpl.fullyQualifiedName,
+		MetricType: tpl.metricType,
+		Help:       tpl.help,
+		// the last one is always "metricType"
+		LabelNames: append([]string{}, tpl.customLabelNames[:len(tpl.customLabelNames)-1]...),
+		Quantiles:  sortedCopy(tpl.quantiles),
+		Buckets:    sortedCopy(tpl.buckets),
+	}
+}
+
+// Returns the description of the defined template
+func (def MetricTemplateDefinitionModel) Info() MetricTemplateInfo {
+	info := MetricTemplateInfo{
+		Name:       def.FullyQualifiedName(),
+		MetricType: def.Type,
+		Help:       def.Help,
+		LabelNames: append([]string{}, def.Labels...),
+	}
+	switch def.Type {
+	case "summary":
+		info.Quantiles = objectiveQuantiles(DefaultSummaryObjectives)
+		if len(def.Objectives) > 0 {
+			info.Quantiles = make([]float64, 0, len(def.Objectives))
+			for _, objective := range def.Objectives {
+				info.Quantiles = append(info.Quantiles, objective.Quantile)
+			}
+			sort.Float64s(info.Quantiles)
+		}
+	case "histogram":
+		info.Buckets = sortedCopy(DefaultHistogramBuckets)
+		if len(def.Buckets) > 0 {
+			info.Buckets = sortedCopy(def.Buckets)
+		}
+	}
+	return info
+}
+
+// the quantiles of the summary objectives - sorted
+func objectiveQuantiles(objectives map[float64]float64) []float64 {
+	quantiles := make([]float64, 0, len(objectives))
+	for quantile := range objectives {
+		quantiles = append(quantiles, quantile)
+	}
+	sort.Float64s(quantiles)
+	return quantiles
+}
+
+// nil stays nil
+func sortedCopy(values []float64) []float64 {
+	if values == nil {
+		return nil
+	}
+	values = append([]float64{}, values...)
+	sort.Float64s(values)
+	return values
+}
+
+// Returns the description of all defined templates
+func (defs MetricTemplateDefinitionsModel) Infos() []MetricTemplateInfo {
+	infos := make([]MetricTemplateInfo, 0, len(defs.Templates))
+	for _, def := range defs.Templates {
+		infos = append(infos, def.Info())
+	}
+	return infos
+}
+
+// Returns the description of all registered templates (including the predefined ones) sorted by name
+func RegisteredMetricTemplateInfos() []MetricTemplateInfo {
+	templates := RegisteredMetricTemplates()
+	infos := make([]MetricTemplateInfo, 0, len(templates))
+	for _, tpl := range templates {
+		infos = append(infos, tpl.Info())
+	}
+	return infos
+}
+
+// Returns how many templates were created since the last InitMetrics() - registered or not, the predefined ones included if they are created already. The
+// Default... settings (e.g. DefaultSummaryObjectives) are read when a template is created - so if this is not 0 then changing them does not affect all
+// templates.
+func CreatedMetricTemplateCount() int {
+	createdMetricTemplatesLock.Lock()
+	defer createdMetricTemplatesLock.Unlock()
+	return len(createdMetricTemplates)
+}
+
+func rememberCreatedMetricTemplate(tpl MetricTemplate) MetricTemplate {
+	createdMetricTemplatesLock.Lock()
+	defer createdMetricTemplatesLock.Unlock()
+	// a duplicate which fails to register must not hide the registered one in the catalog
+	if previous, found := createdMetricTemplates[tpl.fullyQualifiedName]; !found || !previous.IsRegistered() {
+		createdMetricTemplates[tpl.fullyQualifiedName] = tpl
+	}
+	return tpl
+}
+
+// InitMetrics() creates a new registry - the templates we know so far belong to the previous one
+func forgetMetricTemplates() {
+	createdMetricTemplatesLock.Lock()
+	createdMetricTemplates = make(map[string]MetricTemplate)
+	createdMetricTemplatesLock.Unlock()
+
+	registeredMetricTemplatesLock.Lock()
+	for _, tpl := range registeredMetricTemplates {
+		tpl.state.isRegistered.Store(false)
+	}
+	registeredMetricTemplates = make(map[string]MetricTemplate)
+	registeredMetricTemplatesLock.Unlock()
+
+	loadedMetricTemplatesLock.Lock()
+	loadedMetricTemplates = make(map[string]MetricTemplate)
+	loadedMetricTemplatesLock.Unlock()
+
+	// the predefined ones are re-created into the new registry on first use
+	metricTemplatesLock.Lock()
+	metricTemplatesAvailable.Store(false)
+	metricTemplatesLock.Unlock()
+
+	stopAllSweepers()
+	resetSetOwnedInstances()
+	forgetScheduledJobLastSuccesses()
+}
+
+func rememberRegisteredMetricTemplate(tpl MetricTemplate) {
+	registeredMetricTemplatesLock.Lock()
+	registeredMetricTemplates[tpl.fullyQualifiedName] = tpl
+	registeredMetricTemplatesLock.Unlock()
+
+	// the registered one is what the catalog should show
+	createdMetricTemplatesLock.Lock()
+	createdMetricTemplates[tpl.fullyQualifiedName] = tpl
+	createdMetricTemplatesLock.Unlock()
+}
+
+// Returns a registered template by its fully qualified name - the predefined templates are included too. The bool is FALSE if there is no such template.
+func GetRegisteredMetricTemplate(fullyQualifiedName string) (MetricTemplate, bool) {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+
+	registeredMetricTemplatesLock.Lock()
+	defer registeredMetricTemplatesLock.Unlock()
+	tpl, found := registeredMetricTemplates[fullyQualifiedName]
+	return tpl, found
+}
+
+// Returns all the registered templates - including the predefined ones - sorted by their fully qualified name
+func RegisteredMetricTemplates() []MetricTemplate {
+	createMetricTemplatesIfNotCreatedYet(MetricRegistry)
+
+	registeredMetricTemplatesLock.Lock()
+	defer registeredMetricTemplatesLock.Unlock()
+	templates := make([]MetricTemplate, 0, len(registeredMetricTemplates))
+	for _, tpl := range registeredMetricTemplates {
+		templates = append(templates, tpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].fullyQualifiedName < templates[j].fullyQualifiedName
+	})
+	return templates
+}
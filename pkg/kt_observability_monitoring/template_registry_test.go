@@ -0,0 +1,73 @@
+package kt_observability_monitoring
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestInitMetricsRefreshesTheTemplates(t *testing.T) {
+	InitMetrics()
+	previous := GetExecCountTemplate()
+	newTestCounterTemplate(t, "registryTestCount")
+	newTestCounterTemplate(t, "registryTestCount")
+
+	InitMetrics()
+	if previous.IsRegistered() {
+		t.Errorf("the template of the previous registry must not be registered anymore")
+	}
+	current := GetExecCountTemplate()
+	if !current.IsRegistered() {
+		t.Errorf("the predefined template must be registered into the new registry")
+	}
+	if found, _ := GetRegisteredMetricTemplate("registryTestCount"); found.state != nil {
+		t.Errorf("the template of the previous registry must be forgotten")
+	}
+
+	newTestCounterTemplate(t, "registryTestCount")
+	newTestCounterTemplate(t, "registryTestCount")
+	GetCounterMetricInstance(current, map[string]any{"of": "registryTest", "qualifier": "-"}).Inc()
+	count := 0
+	for _, status := range ListTemplates(false) {
+		if status.Name == "registryTestCount" {
+			count++
+			// the second one failed to register - but the first one is exposed, so that is what the catalog must show
+			if !status.IsRegistered {
+				t.Errorf("the catalog must show the registered template, not the duplicate which failed to register")
+			}
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected the template once in the catalog, got %d", count)
+	}
+
+	families, err := MetricRegistry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "execCount" {
+			return
+		}
+	}
+	t.Errorf("execCount is not in the new registry")
+}
+
+// run it with -race - the first use of the predefined templates from more goroutines must not race
+func TestPredefinedTemplatesAreCreatedOnce(t *testing.T) {
+	InitMetrics()
+	var wg sync.WaitGroup
+	templates := make([]MetricTemplate, 8)
+	for i := range templates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			templates[i] = GetExecCountTemplate()
+		}()
+	}
+	wg.Wait()
+	for _, tpl := range templates {
+		if tpl.state != templates[0].state || !tpl.IsRegistered() {
+			t.Fatalf("every goroutine must get the same registered template")
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package kt_observability_monitoring
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Returned by WorkerPool.Submit() if the queue of the pool is full and the task was rejected
+	ErrWorkerPoolQueueFull = errors.New("worker pool queue is full")
+	// Returned by WorkerPool.Submit() if the pool was already shut down
+	ErrWorkerPoolClosed = errors.New("worker pool is shut down")
+)
+
+// If you already have a worker pool (or goroutine pool, or anything similar with a queue and workers) you can use this class to attach the standard worker
+// pool Metrics to it. Just invoke the hook methods from the appropriate points of your pool.
+//
+// If you do not have a pool yet then take a look at WorkerPool - which is using this set under the hood.
+//
+// Unlike the HTTP lazy sets this one creates all its Metric instances immediately - as they are all "of" the same pool it makes no sense to wait.
+// The hook methods are safe for concurrent use.
+type WorkerPoolMetricsSet struct {
+	of        string
+	qualifier any
+
+	created createdMetricInstances
+
+	queueDepthGauge     prometheus.Gauge
+	activeWorkersGauge  prometheus.Gauge
+	taskWaitTime        prometheus.Observer
+	taskProcessingTime  prometheus.Observer
+	taskExecCounter     prometheus.Counter
+	taskFailedCounter   prometheus.Counter
+	taskRejectedCounter prometheus.Counter
+}
+
+type WorkerPoolMetricsSetOpt func(m *WorkerPoolMetricsSet)
+
+// Creates a new metrics set for a worker pool.
+//
+// Pass in "of" as the best name (meaningful) of the worker pool! And feel free to use the optional setup too!
+func NewWorkerPoolMetricsSet(of string, opts ...WorkerPoolMetricsSetOpt) *WorkerPoolMetricsSet {
+	if of == "" {
+		panic("Can not create WorkerPoolMetricsSet with empty 'of' parameter!")
+	}
+
+	metrics := WorkerPoolMetricsSet{
+		of:        of,
+		qualifier: "-",
+	}
+
+	for _, o := range opts {
+		o(&metrics)
+	}
+
+	metrics.queueDepthGauge = metrics.created.gauge(GetWorkerPoolQueueDepthTemplate(), metrics.labels())
+	metrics.activeWorkersGauge = metrics.created.gauge(GetWorkerPoolActiveWorkersTemplate(), metrics.labels())
+	metrics.taskWaitTime = metrics.created.summary(GetWorkerPoolTaskWaitTimeTemplate(), metrics.labels())
+	metrics.taskProcessingTime = metrics.created.summary(GetWorkerPoolTaskProcessingTimeTemplate(), metrics.labels())
+	metrics.taskExecCounter = metrics.created.counter(GetWorkerPoolTaskExecCountTemplate(), metrics.labels())
+	metrics.taskFailedCounter = metrics.created.counter(GetWorkerPoolTaskFailedCountTemplate(), metrics.labels())
+	metrics.taskRejectedCounter = metrics.created.counter(GetWorkerPoolTaskRejectedCountTemplate(), metrics.labels())
+
+	return &metrics
+}
+
+// Assigns a "qualifier" to all Metric instances in your set of your choice.
+func WithWorkerPoolQualifier(qualifier any) WorkerPoolMetricsSetOpt {
+	return func(m *WorkerPoolMetricsSet) {
+		if qualifier != nil {
+			m.qualifier = qualifier
+		}
+	}
+}
+
+func (m *WorkerPoolMetricsSet) labels() map[string]any {
+	return map[string]any{"of": m.of, "qualifier": m.qualifier}
+}
+
+// Deletes all the Metric instances of the set - so they are not exposed anymore. Invoke it if the pool is gone for good - the set must not be used after this!
+func (m *WorkerPoolMetricsSet) Close() {
+	m.created.deleteAll()
+}
+
+// Invoke when a task was put into the queue - increases the queue depth
+func (m *WorkerPoolMetricsSet) TaskEnqueued() {
+	m.queueDepthGauge.Inc()
+}
+
+// Invoke when a task could not be put into the queue (e.g. because it was full) - will increase the rejected counter
+func (m *WorkerPoolMetricsSet) TaskRejected() {
+	m.taskRejectedCounter.Inc()
+}
+
+// Invoke when a worker picked up a task from the queue - pass in how many millis the task was waiting in the queue. This decreases the queue depth and
+// increases the number of active workers.
+func (m *WorkerPoolMetricsSet) TaskStarted(waitedMillis float64) {
+	m.queueDepthGauge.Dec()
+	m.activeWorkersGauge.Inc()
+	m.taskWaitTime.Observe(waitedMillis)
+}
+
+// Invoke when a worker finished with a task - pass in how many millis the execution took and the error the task returned (nil if it was successful).
+// This decreases the number of active workers and also counts the execution / failure.
+func (m *WorkerPoolMetricsSet) TaskFinished(tookMillis float64, err error) {
+	m.activeWorkersGauge.Dec()
+	m.taskProcessingTime.Observe(tookMillis)
+	m.taskExecCounter.Inc()
+	if err != nil {
+		m.taskFailedCounter.Inc()
+	}
+}
+
+type workerPoolTask struct {
+	task       func() error
+	enqueuedAt time.Time
+}
+
+// A simple fixed size worker pool with a bounded queue - fully instrumented with the standard worker pool Metrics (see WorkerPoolMetricsSet).
+//
+// Tasks are submitted with Submit(). If the queue is full the task is rejected immediately (and counted) instead of blocking the caller.
+type WorkerPool struct {
+	of      string
+	metrics *WorkerPoolMetricsSet
+
+	queue    chan workerPoolTask
+	workers  sync.WaitGroup
+	lock     sync.RWMutex
+	isClosed bool
+}
+
+// Creates and starts a new worker pool with the given number of workers and queue size. The "of" should be a meaningful name of the pool - this is used as
+// "of" in all Metrics. Optional setup is passed to the underlying WorkerPoolMetricsSet.
+func NewWorkerPool(of string, workers int, queueSize int, opts ...WorkerPoolMetricsSetOpt) *WorkerPool {
+	if workers < 1 {
+		panic(fmt.Sprintf("Can not create WorkerPool '%v' with %d workers - at least 1 is needed!", of, workers))
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	pool := &WorkerPool{
+		of:      of,
+		metrics: NewWorkerPoolMetricsSet(of, opts...),
+		queue:   make(chan workerPoolTask, queueSize),
+	}
+
+	pool.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+
+	return pool
+}
+
+func (p *WorkerPool) work() {
+	defer p.workers.Done()
+
+	for t := range p.queue {
+		startedAt := time.Now()
+		p.metrics.TaskStarted(float64(startedAt.Sub(t.enqueuedAt)) / float64(time.Millisecond))
+		err := p.execute(t.task)
+		p.metrics.TaskFinished(float64(time.Since(startedAt))/float64(time.Millisecond), err)
+	}
+}
+
+// executes the task - a panic in the task is turned into an error so the worker survives
+func (p *WorkerPool) execute(task func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked in worker pool '%v': %v", p.of, r)
+		}
+	}()
+	return task()
+}
+
+// Returns the metrics set the pool is using
+func (p *WorkerPool) Metrics() *WorkerPoolMetricsSet {
+	return p.metrics
+}
+
+// Puts the task into the queue of the pool. Returns ErrWorkerPoolQueueFull if the queue is full at the moment and ErrWorkerPoolClosed if the pool was shut
+// down already - in both cases the task is not executed.
+func (p *WorkerPool) Submit(task func() error) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.isClosed {
+		return ErrWorkerPoolClosed
+	}
+
+	// we count it first - otherwise a fast worker might decrease the gauge before we increased it
+	p.metrics.TaskEnqueued()
+	select {
+	case p.queue <- workerPoolTask{task: task, enqueuedAt: time.Now()}:
+		return nil
+	default:
+		p.metrics.queueDepthGauge.Dec()
+		p.metrics.TaskRejected()
+		return ErrWorkerPoolQueueFull
+	}
+}
+
+// Stops accepting new tasks and blocks until all already queued tasks are executed. The Metrics of the pool remain exposed - if you do not want that then
+// invoke Close() on the Metrics() of the pool after this.
+func (p *WorkerPool) Shutdown() {
+	p.lock.Lock()
+	if !p.isClosed {
+		p.isClosed = true
+		close(p.queue)
+	}
+	p.lock.Unlock()
+
+	p.workers.Wait()
+}
@@ -0,0 +1,96 @@
+package kt_observability_monitoring
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolRejectsWhenTheQueueIsFull(t *testing.T) {
+	InitMetrics()
+	pool := NewWorkerPool("wpQueueFullTest", 1, 1)
+	labels := map[string]string{"of": "wpQueueFullTest"}
+
+	started := make(chan bool)
+	release := make(chan bool)
+	if err := pool.Submit(func() error { started <- true; <-release; return nil }); err != nil {
+		t.Fatalf("expected the first task to be accepted, got %v", err)
+	}
+	<-started
+	if err := pool.Submit(func() error { return nil }); err != nil {
+		t.Fatalf("expected the second task to be queued, got %v", err)
+	}
+	if err := pool.Submit(func() error { return nil }); !errors.Is(err, ErrWorkerPoolQueueFull) {
+		t.Fatalf("expected ErrWorkerPoolQueueFull, got %v", err)
+	}
+
+	if got := metricValue(t, GetWorkerPoolTaskRejectedCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 rejected task, got %v", got)
+	}
+	if got := metricValue(t, GetWorkerPoolQueueDepthTemplate(), labels); got != 1 {
+		t.Errorf("expected queue depth 1, got %v", got)
+	}
+	if got := metricValue(t, GetWorkerPoolActiveWorkersTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 active worker, got %v", got)
+	}
+
+	close(release)
+	pool.Shutdown()
+	if err := pool.Submit(func() error { return nil }); !errors.Is(err, ErrWorkerPoolClosed) {
+		t.Errorf("expected ErrWorkerPoolClosed after shutdown, got %v", err)
+	}
+	if got := metricValue(t, GetWorkerPoolTaskExecCountTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 executed tasks, got %v", got)
+	}
+	if got := metricValue(t, GetWorkerPoolQueueDepthTemplate(), labels); got != 0 {
+		t.Errorf("expected empty queue, got %v", got)
+	}
+	if got := metricValue(t, GetWorkerPoolActiveWorkersTemplate(), labels); got != 0 {
+		t.Errorf("expected no active worker, got %v", got)
+	}
+}
+
+func TestWorkerPoolRecordsWaitAndProcessingTime(t *testing.T) {
+	InitMetrics()
+	pool := NewWorkerPool("wpTimingTest", 1, 2)
+	labels := map[string]string{"of": "wpTimingTest"}
+
+	pool.Submit(func() error { time.Sleep(20 * time.Millisecond); return nil })
+	// it waits for the first one in the queue - and takes much less than a millisecond
+	pool.Submit(func() error { return errors.New("failed") })
+	pool.Shutdown()
+
+	if got := metricValue(t, GetWorkerPoolTaskWaitTimeTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 wait time observations, got %v", got)
+	}
+	if got := summarySum(t, GetWorkerPoolTaskWaitTimeTemplate(), labels); got < 10 {
+		t.Errorf("expected the second task to wait for the first one, got %vms in total", got)
+	}
+	if got := metricValue(t, GetWorkerPoolTaskProcessingTimeTemplate(), labels); got != 2 {
+		t.Errorf("expected 2 processing time observations, got %v", got)
+	}
+	// fractions of a millisecond are not lost
+	if got := summarySum(t, GetWorkerPoolTaskProcessingTimeTemplate(), labels); got < 20 || got == float64(int64(got)) {
+		t.Errorf("expected at least 20ms processing time with a fraction, got %vms", got)
+	}
+	if got := metricValue(t, GetWorkerPoolTaskFailedCountTemplate(), labels); got != 1 {
+		t.Errorf("expected 1 failed task, got %v", got)
+	}
+}
+
+func TestWorkerPoolSurvivesPanickingTasks(t *testing.T) {
+	InitMetrics()
+	pool := NewWorkerPool("wpPanicTest", 1, 2)
+
+	pool.Submit(func() error { panic("boom") })
+	pool.Submit(func() error { return nil })
+	pool.Shutdown()
+
+	labels := map[string]string{"of": "wpPanicTest"}
+	if got := metricValue(t, GetWorkerPoolTaskExecCountTemplate(), labels); got != 2 {
+		t.Errorf("expected both tasks to be executed, got %v", got)
+	}
+	if got := metricValue(t, GetWorkerPoolTaskFailedCountTemplate(), labels); got != 1 {
+		t.Errorf("expected the panic to be counted as failure, got %v", got)
+	}
+}
@@ -0,0 +1,298 @@
+package kt_observability_setup
+
+//go:generate go run ../../cmd/kt-configdoc -out ../../CONFIGURATION.md
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+// The prefix of the env vars ConfigFromEnv() is reading
+const ConfigEnvPrefix = "KT_OBS_"
+
+// Every knob of the library in one place - use ConfigFromEnv() to take them from env vars and WithConfig() to apply them in Setup().
+//
+// The "env" tag is the name of the env var, "doc" is the description - CONFIGURATION.md is generated from these (see ConfigDocumentation()), so if you add a
+// field please run go generate.
+type Config struct {
+	LogConfigPath       string              `env:"KT_OBS_LOG_CONFIG" doc:"Path of the kt_logging .yaml or .json config file - if not given the logging config is not touched"`
+	GlobalLabelsFile    string              `env:"KT_OBS_GLOBAL_LABELS_FILE" doc:"Path of a .yaml, .json or .env file with extra global labels"`
+	ExtraLabels         map[string]string   `env:"KT_OBS_EXTRA_LABELS" doc:"Extra global labels, e.g. region=eu-west-1,tier=2 - they override the ones from the file"`
+	MetricsAddr         string              `env:"KT_OBS_METRICS_ADDR" doc:"Address of the metrics server, e.g. :9008 - if not given no server is started"`
+	MetricsPath         string              `env:"KT_OBS_METRICS_PATH" doc:"Path the metrics are served on"`
+	TemplateCatalogPath string              `env:"KT_OBS_TEMPLATE_CATALOG_PATH" doc:"Path the metric template catalog is served on, e.g. /metric-templates - if not given it is not served"`
+	RuntimeCollectors   bool                `env:"KT_OBS_RUNTIME_COLLECTORS" doc:"Register the Go runtime and process collectors"`
+	LogEventCounter     bool                `env:"KT_OBS_LOG_EVENT_COUNTER" doc:"Count the log events per logger and level in the logEventCount metric - of the handlers having the kt-log-event-count sink in their outputPaths"`
+	SummaryObjectives   map[float64]float64 `env:"KT_OBS_SUMMARY_OBJECTIVES" doc:"Quantile:error pairs of the summary templates, e.g. 0.5:0.05,0.99:0.001"`
+	SummaryMaxAge       time.Duration       `env:"KT_OBS_SUMMARY_MAXAGE" doc:"How long summary templates keep the observations, e.g. 60s or 2m"`
+	SummaryAgeBuckets   uint32              `env:"KT_OBS_SUMMARY_AGEBUCKETS" doc:"Number of rotating buckets summary templates are using over the max age"`
+	HistogramBuckets    []float64           `env:"KT_OBS_HISTOGRAM_BUCKETS" doc:"Buckets of the histogram templates which do not define their own, e.g. 10,100,1000"`
+	CardinalityLimit    int                 `env:"KT_OBS_CARDINALITY_LIMIT" doc:"Max number of label value combinations per template - 0 means no limit"`
+	K8sPodLabels        []string            `env:"KT_OBS_K8S_POD_LABELS" doc:"Pod labels promoted to global labels when running in Kubernetes, e.g. app.kubernetes.io/part-of,team"`
+	K8sPodAnnotations   []string            `env:"KT_OBS_K8S_POD_ANNOTATIONS" doc:"Pod annotations promoted to global labels when running in Kubernetes, e.g. example.com/team"`
+}
+
+// Returns the config with the current defaults of the library - the map and slice values are copies, so you can modify them
+func DefaultConfig() Config {
+	return Config{
+		MetricsPath:       "/metrics",
+		SummaryObjectives: maps.Clone(kt_observability_monitoring.DefaultSummaryObjectives),
+		SummaryMaxAge:     kt_observability_monitoring.DefaultSummaryMaxAge,
+		SummaryAgeBuckets: kt_observability_monitoring.DefaultSummaryAgeBuckets,
+		HistogramBuckets:  slices.Clone(kt_observability_monitoring.DefaultHistogramBuckets),
+		CardinalityLimit:  kt_observability_monitoring.DefaultCardinalityLimit,
+	}
+}
+
+// Takes the config from the env vars of the process - see ParseConfig()
+func ConfigFromEnv() (Config, error) {
+	return ParseConfig(os.Environ())
+}
+
+// Builds the config from the given env vars ("KEY=value" strings, like os.Environ() returns) - the fields whose env var is not there keep their
+// DefaultConfig() value. Returns all problems found joined into one error - including env vars with ConfigEnvPrefix we do not know (typos).
+func ParseConfig(environ []string) (Config, error) {
+	config := DefaultConfig()
+
+	values := make(map[string]string)
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, ConfigEnvPrefix) {
+			values[key] = value
+		}
+	}
+
+	var errs []error
+	configValue := reflect.ValueOf(&config).Elem()
+	configType := configValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		envVarName := configType.Field(i).Tag.Get("env")
+		value, found := values[envVarName]
+		delete(values, envVarName)
+		if !found {
+			continue
+		}
+		if err := parseConfigValue(configValue.Field(i), strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", envVarName, err))
+		}
+	}
+
+	unknownEnvVarNames := make([]string, 0, len(values))
+	for envVarName := range values {
+		unknownEnvVarNames = append(unknownEnvVarNames, envVarName)
+	}
+	sort.Strings(unknownEnvVarNames)
+	for _, envVarName := range unknownEnvVarNames {
+		errs = append(errs, fmt.Errorf("%v: unknown setting - see CONFIGURATION.md for the supported ones", envVarName))
+	}
+	errs = append(errs, config.validate())
+
+	return config, errors.Join(errs...)
+}
+
+// checks the values which are parsed fine but make no sense - Setup() checks the config given with WithConfig() too
+func (c Config) validate() error {
+	var errs []error
+	if c.CardinalityLimit < 0 {
+		errs = append(errs, fmt.Errorf("KT_OBS_CARDINALITY_LIMIT: %v is negative - use 0 for no limit", c.CardinalityLimit))
+	}
+	for _, quantile := range slices.Sorted(maps.Keys(c.SummaryObjectives)) {
+		if quantile < 0 || quantile > 1 {
+			errs = append(errs, fmt.Errorf("KT_OBS_SUMMARY_OBJECTIVES: quantile %v is not between 0 and 1", quantile))
+		}
+		if allowedError := c.SummaryObjectives[quantile]; allowedError <= 0 || allowedError >= 1 {
+			errs = append(errs, fmt.Errorf("KT_OBS_SUMMARY_OBJECTIVES: error %v of quantile %v must be greater than 0 and less than 1", allowedError, quantile))
+		}
+	}
+	if c.SummaryMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("KT_OBS_SUMMARY_MAXAGE: %v is negative", c.SummaryMaxAge))
+	}
+	// the Prometheus client panics on equal buckets too
+	for i := 1; i < len(c.HistogramBuckets); i++ {
+		if c.HistogramBuckets[i] <= c.HistogramBuckets[i-1] {
+			errs = append(errs, fmt.Errorf("KT_OBS_HISTOGRAM_BUCKETS: %v must be strictly increasing", c.HistogramBuckets))
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseConfigValue(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("'%v' is not a bool - use true or false", value)
+		}
+		field.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("'%v' is not an integer", value)
+		}
+		field.SetInt(int64(n))
+	case uint32:
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("'%v' is not a positive integer", value)
+		}
+		field.SetUint(n)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("'%v' is not a duration - use e.g. 60s or 2m", value)
+		}
+		field.SetInt(int64(d))
+	case []float64:
+		var numbers []float64
+		for _, item := range splitConfigList(value) {
+			f, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return fmt.Errorf("'%v' is not a number", item)
+			}
+			numbers = append(numbers, f)
+		}
+		field.Set(reflect.ValueOf(numbers))
+	case []string:
+		field.Set(reflect.ValueOf(splitConfigList(value)))
+	case map[string]string:
+		pairs := make(map[string]string)
+		for _, item := range splitConfigList(value) {
+			key, val, found := strings.Cut(item, "=")
+			if !found || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("'%v' is not a key=value pair", item)
+			}
+			pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		field.Set(reflect.ValueOf(pairs))
+	case map[float64]float64:
+		objectives := make(map[float64]float64)
+		for _, item := range splitConfigList(value) {
+			quantileStr, errorStr, found := strings.Cut(item, ":")
+			quantile, quantileErr := strconv.ParseFloat(strings.TrimSpace(quantileStr), 64)
+			allowedError, errorErr := strconv.ParseFloat(strings.TrimSpace(errorStr), 64)
+			if !found || quantileErr != nil || errorErr != nil {
+				return fmt.Errorf("'%v' is not a quantile:error pair", item)
+			}
+			objectives[quantile] = allowedError
+		}
+		field.Set(reflect.ValueOf(objectives))
+	default:
+		return fmt.Errorf("unsupported config field type %v", field.Type())
+	}
+	return nil
+}
+
+// splits a comma separated list - empty items are skipped
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sets the monitoring defaults of the config - must happen before the templates are created, we warn if some are created already
+func (c Config) applyMonitoringDefaults() {
+	if createdCount := kt_observability_monitoring.CreatedMetricTemplateCount(); createdCount > 0 {
+		kt_logging.GetLogger("keytiles.observability.Setup").Warn(
+			"%v metric templates were created before the monitoring defaults were applied - they keep the previous summary, histogram and cardinality settings",
+			createdCount)
+	}
+	kt_observability_monitoring.DefaultSummaryObjectives = maps.Clone(c.SummaryObjectives)
+	kt_observability_monitoring.DefaultSummaryMaxAge = c.SummaryMaxAge
+	kt_observability_monitoring.DefaultSummaryAgeBuckets = c.SummaryAgeBuckets
+	kt_observability_monitoring.DefaultHistogramBuckets = slices.Clone(c.HistogramBuckets)
+	kt_observability_monitoring.DefaultCardinalityLimit = c.CardinalityLimit
+}
+
+// Returns the documentation of the config as a Markdown table - built from the Config struct and DefaultConfig(). CONFIGURATION.md is generated with this.
+func ConfigDocumentation() string {
+	var doc strings.Builder
+	doc.WriteString("| Env var | Type | Default | Description |\n")
+	doc.WriteString("|---|---|---|---|\n")
+
+	defaults := reflect.ValueOf(DefaultConfig())
+	configType := defaults.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		fmt.Fprintf(&doc, "| `%v` | %v | %v | %v |\n", field.Tag.Get("env"), configTypeName(defaults.Field(i)),
+			formatConfigValue(defaults.Field(i)), field.Tag.Get("doc"))
+	}
+	return doc.String()
+}
+
+func configTypeName(field reflect.Value) string {
+	switch field.Interface().(type) {
+	case time.Duration:
+		return "duration"
+	case uint32:
+		return "positive integer"
+	case int:
+		return "integer"
+	case []float64:
+		return "list of numbers"
+	case []string:
+		return "list of strings"
+	case map[string]string:
+		return "list of key=value"
+	case map[float64]float64:
+		return "list of quantile:error"
+	default:
+		return field.Type().String()
+	}
+}
+
+// formats the value the same way it is given in the env var - empty values are "-"
+func formatConfigValue(field reflect.Value) string {
+	var formatted string
+	switch v := field.Interface().(type) {
+	case []float64:
+		items := make([]string, 0, len(v))
+		for _, f := range v {
+			items = append(items, strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		formatted = strings.Join(items, ",")
+	case []string:
+		formatted = strings.Join(v, ",")
+	case map[string]string:
+		items := make([]string, 0, len(v))
+		for key, val := range v {
+			items = append(items, key+"="+val)
+		}
+		sort.Strings(items)
+		formatted = strings.Join(items, ",")
+	case map[float64]float64:
+		quantiles := make([]float64, 0, len(v))
+		for quantile := range v {
+			quantiles = append(quantiles, quantile)
+		}
+		sort.Float64s(quantiles)
+		items := make([]string, 0, len(v))
+		for _, quantile := range quantiles {
+			items = append(items, strconv.FormatFloat(quantile, 'f', -1, 64)+":"+strconv.FormatFloat(v[quantile], 'f', -1, 64))
+		}
+		formatted = strings.Join(items, ",")
+	default:
+		formatted = fmt.Sprintf("%v", v)
+	}
+	if formatted == "" {
+		return "-"
+	}
+	return "`" + formatted + "`"
+}
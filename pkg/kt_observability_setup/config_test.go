@@ -0,0 +1,144 @@
+package kt_observability_setup
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		// modifies DefaultConfig() to what we expect
+		want func(c *Config)
+		// substrings of the expected error - empty if no error is expected
+		wantErrs []string
+	}{
+		{
+			name:    "defaults",
+			environ: []string{"HOME=/root", "PATH=/bin"},
+			want:    func(c *Config) {},
+		},
+		{
+			name: "all types",
+			environ: []string{
+				"KT_OBS_METRICS_ADDR=:9008",
+				"KT_OBS_RUNTIME_COLLECTORS=true",
+				"KT_OBS_EXTRA_LABELS=region=eu-west-1, tier = 2",
+				"KT_OBS_SUMMARY_OBJECTIVES=0.5:0.05,0.99:0.001",
+				"KT_OBS_SUMMARY_MAXAGE=2m",
+				"KT_OBS_SUMMARY_AGEBUCKETS=3",
+				"KT_OBS_HISTOGRAM_BUCKETS=10,100,1000",
+				"KT_OBS_CARDINALITY_LIMIT= 500 ",
+				"KT_OBS_K8S_POD_LABELS=team,,app",
+			},
+			want: func(c *Config) {
+				c.MetricsAddr = ":9008"
+				c.RuntimeCollectors = true
+				c.ExtraLabels = map[string]string{"region": "eu-west-1", "tier": "2"}
+				c.SummaryObjectives = map[float64]float64{0.5: 0.05, 0.99: 0.001}
+				c.SummaryMaxAge = 2 * time.Minute
+				c.SummaryAgeBuckets = 3
+				c.HistogramBuckets = []float64{10, 100, 1000}
+				c.CardinalityLimit = 500
+				c.K8sPodLabels = []string{"team", "app"}
+			},
+		},
+		{
+			name: "parsing errors",
+			environ: []string{
+				"KT_OBS_RUNTIME_COLLECTORS=yes please",
+				"KT_OBS_CARDINALITY_LIMIT=many",
+				"KT_OBS_SUMMARY_AGEBUCKETS=-1",
+				"KT_OBS_SUMMARY_MAXAGE=60",
+				"KT_OBS_HISTOGRAM_BUCKETS=10,ten",
+				"KT_OBS_EXTRA_LABELS=region",
+				"KT_OBS_SUMMARY_OBJECTIVES=0.5",
+			},
+			wantErrs: []string{
+				"KT_OBS_RUNTIME_COLLECTORS: 'yes please' is not a bool",
+				"KT_OBS_CARDINALITY_LIMIT: 'many' is not an integer",
+				"KT_OBS_SUMMARY_AGEBUCKETS: '-1' is not a positive integer",
+				"KT_OBS_SUMMARY_MAXAGE: '60' is not a duration",
+				"KT_OBS_HISTOGRAM_BUCKETS: 'ten' is not a number",
+				"KT_OBS_EXTRA_LABELS: 'region' is not a key=value pair",
+				"KT_OBS_SUMMARY_OBJECTIVES: '0.5' is not a quantile:error pair",
+			},
+		},
+		{
+			name:     "unknown settings",
+			environ:  []string{"KT_OBS_METRICS_ADRR=:9008", "KT_OBS_FOO=1"},
+			wantErrs: []string{"KT_OBS_FOO: unknown setting", "KT_OBS_METRICS_ADRR: unknown setting"},
+		},
+		{
+			name: "invalid values",
+			environ: []string{
+				"KT_OBS_CARDINALITY_LIMIT=-1",
+				"KT_OBS_SUMMARY_OBJECTIVES=1.5:0.01,0.5:0",
+				"KT_OBS_SUMMARY_MAXAGE=-5s",
+			},
+			wantErrs: []string{
+				"KT_OBS_CARDINALITY_LIMIT: -1 is negative",
+				"KT_OBS_SUMMARY_OBJECTIVES: quantile 1.5 is not between 0 and 1",
+				"KT_OBS_SUMMARY_OBJECTIVES: error 0 of quantile 0.5 must be greater than 0",
+				"KT_OBS_SUMMARY_MAXAGE: -5s is negative",
+			},
+		},
+		{
+			name:     "buckets not in increasing order",
+			environ:  []string{"KT_OBS_HISTOGRAM_BUCKETS=100,10"},
+			wantErrs: []string{"KT_OBS_HISTOGRAM_BUCKETS: [100 10] must be strictly increasing"},
+		},
+		{
+			name:     "equal buckets",
+			environ:  []string{"KT_OBS_HISTOGRAM_BUCKETS=10,10,100"},
+			wantErrs: []string{"KT_OBS_HISTOGRAM_BUCKETS: [10 10 100] must be strictly increasing"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := ParseConfig(test.environ)
+			if len(test.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				want := DefaultConfig()
+				test.want(&want)
+				if !reflect.DeepEqual(config, want) {
+					t.Errorf("expected %+v, got %+v", want, config)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %q, got nil", test.wantErrs)
+			}
+			for _, wantErr := range test.wantErrs {
+				if !strings.Contains(err.Error(), wantErr) {
+					t.Errorf("expected error containing %q, got %v", wantErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultConfigReturnsCopies(t *testing.T) {
+	config := DefaultConfig()
+	config.HistogramBuckets[0] = -1
+	config.SummaryObjectives[0.42] = 0.01
+	if fresh := DefaultConfig(); fresh.HistogramBuckets[0] == -1 || fresh.SummaryObjectives[0.42] != 0 {
+		t.Errorf("modifying the returned config must not modify the defaults")
+	}
+}
+
+func TestConfigurationMdIsUpToDate(t *testing.T) {
+	generated, err := os.ReadFile("../../CONFIGURATION.md")
+	if err != nil {
+		t.Fatalf("failed to read CONFIGURATION.md: %v", err)
+	}
+	if !strings.HasSuffix(string(generated), ConfigDocumentation()) {
+		t.Errorf("CONFIGURATION.md is outdated - run go generate ./pkg/kt_observability_setup/")
+	}
+}
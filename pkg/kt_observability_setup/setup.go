@@ -0,0 +1,303 @@
+// Wires logging and monitoring together in one call - so services do not have to repeat the same bootstrap sequence (build the global labels, set them for
+// logging, init metrics, set them for metrics, start the exporter).
+//
+// It is a separate package because kt_observability is imported by both kt_observability_logging and kt_observability_monitoring - it can not import them
+// back.
+package kt_observability_setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type setupConfig struct {
+	logConfigPath     string
+	globalLabels      map[string]any
+	globalLabelsFile  string
+	extraGlobalLabels map[string]any
+	// the pod labels / annotations the default global label provider promotes
+	k8sPodLabels      []string
+	k8sPodAnnotations []string
+	metricsAddr       string
+	metricsPath       string
+	catalogPath       string
+	runtimeCollectors bool
+	// if not nil then the log event counter is installed with these options
+	logEventCounterOpts []kt_observability_monitoring.LogEventCounterOpt
+	// if set then these are applied as the defaults of kt_observability_monitoring
+	monitoringDefaults *Config
+}
+
+type Option func(*setupConfig)
+
+// Initializes kt_logging from the given .yaml or .json config file first - by default the logging is not touched
+func WithLogConfig(cfgPath string) Option {
+	return func(c *setupConfig) {
+		c.logConfigPath = cfgPath
+	}
+}
+
+// The global labels to use - by default they are built with kt_observability.BuildGlobalLabelsMap()
+func WithGlobalLabels(labels map[string]any) Option {
+	return func(c *setupConfig) {
+		c.globalLabels = labels
+	}
+}
+
+// Extra global labels from a .yaml, .json or .env file (see kt_observability.LoadGlobalLabels()) - they override the global labels with the same name
+func WithGlobalLabelsFile(labelsPath string) Option {
+	return func(c *setupConfig) {
+		c.globalLabelsFile = labelsPath
+	}
+}
+
+// The pod labels and annotations (keys) promoted to global labels when running in Kubernetes - see kt_observability.WithKubernetesPodLabels() and
+// kt_observability.WithKubernetesPodAnnotations(). If any is given then the global labels are built with a kt_observability.NewDefaultGlobalLabelProvider()
+// using them instead of kt_observability.GlobalLabelProvider (which is left untouched). Has no effect together with WithGlobalLabels().
+func WithKubernetesPodMetadata(podLabels []string, podAnnotations []string) Option {
+	return func(c *setupConfig) {
+		c.k8sPodLabels = podLabels
+		c.k8sPodAnnotations = podAnnotations
+	}
+}
+
+// Labels added on top of the global labels - they override the global labels (and the ones from the WithGlobalLabelsFile()) with the same name
+func WithExtraGlobalLabels(labels map[string]any) Option {
+	return func(c *setupConfig) {
+		c.extraGlobalLabels = labels
+	}
+}
+
+// Starts an HTTP server exposing the MetricRegistry - e.g. WithMetricsServer(":9008", "/metrics"). By default no server is started.
+func WithMetricsServer(addr string, path string) Option {
+	return func(c *setupConfig) {
+		c.metricsAddr = addr
+		c.metricsPath = path
+	}
+}
+
+// Serves the template catalog (see kt_observability_monitoring.MetricTemplatesHandler()) on the given path of the metrics server too - e.g.
+// "/metric-templates". Only has effect together with WithMetricsServer().
+func WithTemplateCatalog(path string) Option {
+	return func(c *setupConfig) {
+		c.catalogPath = path
+	}
+}
+
+// Registers the standard Go runtime and process collectors (the "go_..." and "process_..." metrics) into the MetricRegistry too
+func WithRuntimeCollectors() Option {
+	return func(c *setupConfig) {
+		c.runtimeCollectors = true
+	}
+}
+
+// Installs the "logEventCount" counter (see kt_observability_monitoring.InstallLogEventCounter()) with the given options - so you get the count of log
+// events per logger and level as a Metric too. Only the handlers having the "kt-log-event-count://<handler name>" sink in their 'outputPaths' are counted -
+// so you most likely want WithLogConfig() too.
+func WithLogEventCounter(opts ...kt_observability_monitoring.LogEventCounterOpt) Option {
+	return func(c *setupConfig) {
+		c.logEventCounterOpts = append([]kt_observability_monitoring.LogEventCounterOpt{}, opts...)
+	}
+}
+
+// Applies everything from the config - e.g. the one you got from ConfigFromEnv(). The summary and histogram defaults and the cardinality limit are set
+// in kt_observability_monitoring before the MetricRegistry is created. Options after this one override the config.
+func WithConfig(config Config) Option {
+	return func(c *setupConfig) {
+		c.logConfigPath = config.LogConfigPath
+		c.globalLabelsFile = config.GlobalLabelsFile
+		c.extraGlobalLabels = make(map[string]any, len(config.ExtraLabels))
+		for key, value := range config.ExtraLabels {
+			c.extraGlobalLabels[key] = value
+		}
+		c.k8sPodLabels = config.K8sPodLabels
+		c.k8sPodAnnotations = config.K8sPodAnnotations
+		c.metricsAddr = config.MetricsAddr
+		c.metricsPath = config.MetricsPath
+		c.catalogPath = config.TemplateCatalogPath
+		c.runtimeCollectors = config.RuntimeCollectors
+		if config.LogEventCounter {
+			c.logEventCounterOpts = []kt_observability_monitoring.LogEventCounterOpt{}
+		} else {
+			c.logEventCounterOpts = nil
+		}
+		c.monitoringDefaults = &config
+	}
+}
+
+// What you get back from Setup() - use it to shut down what Setup() started
+type Handle struct {
+	globalLabels  map[string]any
+	metricsServer *http.Server
+	metricsAddr   string
+	// if we have installed the log event counter
+	logEventCounter bool
+	shutdownOnce    sync.Once
+	shutdownErr     error
+}
+
+// Bootstraps logging and monitoring:
+//  1. initializes kt_logging from the config file - if WithLogConfig() was given
+//  2. builds and validates the global labels (see kt_observability.ValidateGlobalLabels()) and sets them for logging
+//  3. applies the monitoring defaults - if WithConfig() was given
+//  4. creates the kt_observability_monitoring.MetricRegistry (InitMetrics()) and sets the global labels for metrics too
+//  5. registers the runtime collectors - if WithRuntimeCollectors() was given
+//  6. starts the metrics server - if WithMetricsServer() was given
+//  7. installs the log event counter - if WithLogEventCounter() was given. This is the last step so nothing can fail after it and leave the counter installed
+//
+// If anything fails you get back an error and nothing is left running. Everything which can fail (the global labels, the metrics server address) is checked
+// before the global state of kt_logging and kt_observability_monitoring is touched - except the logging config which comes first. Otherwise you get back a
+// Handle - invoke its Shutdown() when the service stops.
+//
+// Invoke it once, at startup. Invoking it again (e.g. in tests) is possible but it re-creates the MetricRegistry (see
+// kt_observability_monitoring.InitMetrics()) - so Shutdown() the previous Handle first.
+func Setup(opts ...Option) (*Handle, error) {
+	cfg := &setupConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.logConfigPath != "" {
+		if err := kt_logging.InitFromConfig(cfg.logConfigPath); err != nil {
+			return nil, fmt.Errorf("failed to init logging! error was: %w", err)
+		}
+	}
+
+	globalLabels := make(map[string]any)
+	baseLabels := cfg.globalLabels
+	if baseLabels == nil {
+		labelProvider := kt_observability.GlobalLabelProvider
+		if len(cfg.k8sPodLabels) > 0 || len(cfg.k8sPodAnnotations) > 0 {
+			labelProvider = kt_observability.NewDefaultGlobalLabelProvider(
+				kt_observability.WithKubernetesPodLabels(cfg.k8sPodLabels...),
+				kt_observability.WithKubernetesPodAnnotations(cfg.k8sPodAnnotations...),
+			)
+		}
+		baseLabels = kt_observability.BuildGlobalLabelsMapFrom(labelProvider)
+	}
+	for key, value := range baseLabels {
+		globalLabels[key] = value
+	}
+	if cfg.globalLabelsFile != "" {
+		fileLabels, err := kt_observability.LoadGlobalLabels(cfg.globalLabelsFile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileLabels {
+			globalLabels[key] = value
+		}
+	}
+	for key, value := range cfg.extraGlobalLabels {
+		globalLabels[key] = value
+	}
+	if err := kt_observability.ValidateGlobalLabels(globalLabels); err != nil {
+		return nil, fmt.Errorf("invalid global labels: %w", err)
+	}
+	if cfg.monitoringDefaults != nil {
+		if err := cfg.monitoringDefaults.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	// we listen right here - so if the port is taken you get the error before anything is changed
+	var metricsListener net.Listener
+	if cfg.metricsAddr != "" {
+		listener, err := net.Listen("tcp", cfg.metricsAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start metrics server! error was: %w", err)
+		}
+		metricsListener = listener
+	}
+
+	if cfg.monitoringDefaults != nil {
+		cfg.monitoringDefaults.applyMonitoringDefaults()
+	}
+
+	kt_logging.SetGlobalLabels(kt_observability_logging.BuildLogLabels(globalLabels))
+	kt_observability_monitoring.InitMetrics()
+	kt_observability_monitoring.SetGlobalLabels(globalLabels)
+
+	if cfg.runtimeCollectors {
+		if err := errors.Join(
+			kt_observability_monitoring.MetricRegistry.Register(collectors.NewGoCollector()),
+			kt_observability_monitoring.MetricRegistry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})),
+		); err != nil {
+			if metricsListener != nil {
+				metricsListener.Close()
+			}
+			return nil, fmt.Errorf("failed to register runtime collectors! error was: %w", err)
+		}
+	}
+
+	handle := &Handle{globalLabels: globalLabels}
+	if metricsListener != nil {
+		handle.startMetricsServer(cfg, metricsListener)
+	}
+	// keep this the last step - if anything fails before it we have nothing to uninstall
+	if cfg.logEventCounterOpts != nil {
+		kt_observability_monitoring.InstallLogEventCounter(cfg.logEventCounterOpts...)
+		handle.logEventCounter = true
+	}
+	return handle, nil
+}
+
+func (h *Handle) startMetricsServer(cfg *setupConfig, listener net.Listener) {
+	metricsPath := cfg.metricsPath
+	if metricsPath == "" {
+		metricsPath = "/metrics"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(
+		metricsPath,
+		promhttp.HandlerFor(kt_observability_monitoring.MetricRegistry, promhttp.HandlerOpts{Registry: kt_observability_monitoring.MetricRegistry}),
+	)
+	if cfg.catalogPath != "" {
+		mux.Handle(cfg.catalogPath, kt_observability_monitoring.MetricTemplatesHandler())
+	}
+
+	h.metricsServer = &http.Server{Handler: mux}
+	h.metricsAddr = listener.Addr().String()
+
+	LOG := kt_logging.GetLogger("keytiles.observability.Setup")
+	go func() {
+		if err := h.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			LOG.Error("metrics server stopped - error: %v", err)
+		}
+	}()
+	LOG.Info("Prometheus exporter listening at %v%v", h.metricsAddr, metricsPath)
+}
+
+// Returns the global labels Setup() applied to logging and metrics
+func (h *Handle) GlobalLabels() map[string]any {
+	return h.globalLabels
+}
+
+// Returns the address the metrics server is listening on - useful if you started it on port 0. Empty string if there is no metrics server.
+func (h *Handle) MetricsAddr() string {
+	return h.metricsAddr
+}
+
+// Stops what Setup() started - gracefully, waiting for the ongoing scrapes until the context is done. It is safe to invoke it more times.
+func (h *Handle) Shutdown(ctx context.Context) error {
+	h.shutdownOnce.Do(func() {
+		if h.logEventCounter {
+			kt_observability_monitoring.UninstallLogEventCounter()
+		}
+		if h.metricsServer != nil {
+			h.shutdownErr = h.metricsServer.Shutdown(ctx)
+		}
+	})
+	return h.shutdownErr
+}
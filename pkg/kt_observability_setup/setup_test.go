@@ -0,0 +1,159 @@
+package kt_observability_setup
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+)
+
+var testGlobalLabels = map[string]any{"serviceName": "setupTest", "tier": 2}
+
+// a kt_logging config counting the events of the root Logger - see kt_observability_monitoring.InstallLogEventCounter()
+func writeCountingLogConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	config := `
+loggers:
+  root: {level: info, handlers: [stdout_json]}
+handlers:
+  stdout_json: {level: info, encoding: json, outputPaths: [stdout, "kt-log-event-count://stdout_json"]}
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write %v: %v", path, err)
+	}
+	return path
+}
+
+// logs an error with the given Logger and tells if it was counted in the "logEventCount" Metric
+func logEventCounted(t *testing.T, loggerName string) bool {
+	t.Helper()
+	kt_logging.GetLogger(loggerName).Error("is it counted?")
+	mfs, err := kt_observability_monitoring.MetricRegistry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "logEventCount" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "of" && pair.GetValue() == loggerName {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func httpGet(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %v failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestSetupAndShutdown(t *testing.T) {
+	handle, err := Setup(
+		WithLogConfig(writeCountingLogConfig(t)),
+		WithGlobalLabels(testGlobalLabels),
+		WithMetricsServer("127.0.0.1:0", "/metrics"),
+		WithTemplateCatalog("/metric-templates"),
+		WithLogEventCounter(),
+	)
+	if err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if !logEventCounted(t, "setupTest.running") {
+		t.Errorf("the log event counter must be installed")
+	}
+	status, body := httpGet(t, "http://"+handle.MetricsAddr()+"/metrics")
+	if status != http.StatusOK || !strings.Contains(body, `logEventCount{`) || !strings.Contains(body, `serviceName="setupTest"`) {
+		t.Errorf("expected the metrics with the global labels, got %v:\n%v", status, body)
+	}
+	if status, body := httpGet(t, "http://"+handle.MetricsAddr()+"/metric-templates"); status != http.StatusOK || !strings.Contains(body, "logEventCount") {
+		t.Errorf("expected the template catalog, got %v:\n%v", status, body)
+	}
+
+	if err := handle.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if err := handle.Shutdown(context.Background()); err != nil {
+		t.Errorf("shutting down again must be a no-op, got %v", err)
+	}
+	if _, err := http.Get("http://" + handle.MetricsAddr() + "/metrics"); err == nil {
+		t.Errorf("the metrics server must be stopped")
+	}
+	if logEventCounted(t, "setupTest.stopped") {
+		t.Errorf("the log event counter must be uninstalled")
+	}
+}
+
+func TestFailedSetupLeavesNothingBehind(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer occupied.Close()
+
+	originalProvider := kt_observability.GlobalLabelProvider
+	_, err = Setup(
+		WithLogConfig(writeCountingLogConfig(t)),
+		WithKubernetesPodMetadata([]string{"team"}, nil),
+		WithMetricsServer(occupied.Addr().String(), "/metrics"),
+		WithLogEventCounter(),
+	)
+	if err == nil {
+		t.Fatalf("expected the setup to fail on the taken port")
+	}
+	if kt_observability.GlobalLabelProvider != originalProvider {
+		t.Errorf("the GlobalLabelProvider must not be replaced")
+	}
+	kt_observability_monitoring.InitMetrics()
+	if logEventCounted(t, "setupTest.failed") {
+		t.Errorf("the log event counter must not be installed")
+	}
+}
+
+func TestSetupWithInvalidInputDoesNotListen(t *testing.T) {
+	// a port which is free - we close the listener right away
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	invalidConfig := DefaultConfig()
+	invalidConfig.SummaryMaxAge = -1
+	for name, opts := range map[string][]Option{
+		"invalid global labels": {WithGlobalLabels(map[string]any{"bad-name": "x"})},
+		"invalid config":        {WithConfig(invalidConfig), WithGlobalLabels(testGlobalLabels)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Setup(append(opts, WithMetricsServer(addr, "/metrics"), WithLogEventCounter())...); err == nil {
+				t.Fatalf("expected the setup to fail")
+			}
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				t.Fatalf("the failed setup must not keep listening on %v: %v", addr, err)
+			}
+			listener.Close()
+		})
+	}
+}
@@ -24,19 +24,15 @@ func NewHttpServerHandler() *HttpServerHandler {
 }
 
 func (n *HttpServerHandler) ServePingOK(w http.ResponseWriter, req *http.Request) {
-	startedAt := time.Now().UnixMilli()
-
 	statusCode := 200
 	body := "Pong"
 
-	// we have a request - mark it
-	n.pingMetricSet.ServeStarted(req)
+	// we have a request - mark it (this time with the in-flight token way)
+	inFlight := n.pingMetricSet.Begin(req)
 
 	defer func() {
-		// adjust metrics
-		millisTook := time.Now().UnixMilli() - startedAt
-		n.pingMetricSet.ServeTookMillis(req, strconv.Itoa(statusCode), float64(millisTook))
-		n.pingMetricSet.ServeSucceeded(req, strconv.Itoa(statusCode))
+		// adjust metrics - time, success/failure and in-flight in one go
+		inFlight.End(strconv.Itoa(statusCode))
 	}()
 
 	// let's wait some random time - simulating execution time
@@ -12,11 +12,10 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/keytiles/lib-logging-golang/v2/pkg/kt_logging"
-	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_logging"
 	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_monitoring"
+	"github.com/keytiles/lib-observability-golang/v2/pkg/kt_observability_setup"
 	http_handler "github.com/keytiles/lib-observability-golang/v2/tests/integration_tests/http"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -24,6 +23,9 @@ var (
 	brokerTopic1_messageRetried   prometheus.Counter
 	brokerTopic1_processingFailed prometheus.Counter
 	brokerTopic1_processingTime   prometheus.Observer
+	brokerTopic1_processingHist   prometheus.Observer
+
+	appLogicJobMetrics *kt_observability_monitoring.ScheduledJobMetricsSet
 
 	threadExecCount int
 )
@@ -35,19 +37,23 @@ func main() {
 	globalLabels["globalLabel1"] = "value1"
 	globalLabels["globalLabel2"] = 5
 
-	// set the global labels for logging
-	kt_logging.SetGlobalLabels(kt_observability_logging.BuildLogLabels(globalLabels))
-
-	// init metrics and set global labels for metrics too
-	kt_observability_monitoring.InitMetrics()
-	kt_observability_monitoring.SetGlobalLabels(globalLabels)
+	// set the global labels for logging and metrics, and expose the metrics via http at localhost:9008/metrics - plus the template catalog next to it, to
+	// see which templates and label value combinations we have
+	observability, err := kt_observability_setup.Setup(
+		kt_observability_setup.WithLogConfig("log_config.yaml"),
+		kt_observability_setup.WithGlobalLabels(globalLabels),
+		kt_observability_setup.WithMetricsServer(":9008", "/metrics"),
+		kt_observability_setup.WithTemplateCatalog("/metric-templates"),
+		kt_observability_setup.WithLogEventCounter(),
+	)
+	if err != nil {
+		panic(err)
+	}
 
 	LOG := kt_logging.GetLogger("main")
 
 	LOG.Info("starting up application...")
-
-	// let's establish the prometheus http endpoint
-	exposeMetrics(LOG, globalLabels)
+	LOG.Info("Metric template catalog is available at http://localhost:9008/metric-templates")
 
 	// create simple HTTP server
 	httpHost := "0.0.0.0"
@@ -86,6 +92,20 @@ func main() {
 		map[string]any{"of": "msgProcessingRetried", "qualifier": "broker-topic-1"},
 	)
 
+	// templates can also come from a definitions file - this is how a team-wide standards file can be shared
+	definedTemplates, err := kt_observability_monitoring.LoadMetricTemplates("metric_templates.yaml")
+	if err != nil {
+		LOG.Error("failed to load metric templates - error: %v", err)
+		panic(err)
+	}
+	brokerTopic1_processingHist = kt_observability_monitoring.GetHistogramMetricInstance(
+		definedTemplates["msgProcessingTimeHist"],
+		map[string]any{"of": "msgProcessing", "topic": "broker-topic-1"},
+	)
+
+	// and the simulated app logic is a scheduled job - let's observe it that way too
+	appLogicJobMetrics = kt_observability_monitoring.NewScheduledJobMetricsSet("simulateAppLogic")
+
 	LOG.Info("starting main thread...")
 
 	ctx, stopAndExitFunc := context.WithCancel(context.Background())
@@ -102,7 +122,7 @@ func main() {
 				// lets break out from the loop and finish go routine
 				doRun = false
 			case <-simulateMetricsTicker.C:
-				simulateAppLogic()
+				appLogicJobMetrics.Run(ctx, simulateAppLogic)
 			}
 		}
 
@@ -118,28 +138,14 @@ func main() {
 	// we cancel the context -> both threads will get the signal
 	stopAndExitFunc()
 
-	LOG.Info("app stopped, exiting...")
-
-}
-
-func exposeMetrics(LOG *kt_logging.Logger, globalLabels map[string]any) {
-
-	kt_observability_monitoring.InitMetrics()
-	kt_observability_monitoring.SetGlobalLabels(globalLabels)
-
-	// Expose prometheus metrics via http at localhost:9008/metrics
-	port := "9008"
-	path := "/metrics"
-
-	prometheusExposer := http.NewServeMux()
-	prometheusExposer.Handle(
-		path,
-		promhttp.HandlerFor(kt_observability_monitoring.MetricRegistry, promhttp.HandlerOpts{Registry: kt_observability_monitoring.MetricRegistry}),
-	)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := observability.Shutdown(shutdownCtx); err != nil {
+		LOG.Warn("failed to shut down observability - error: %v", err)
+	}
 
-	go http.ListenAndServe(":"+port, prometheusExposer)
+	LOG.Info("app stopped, exiting...")
 
-	LOG.Info("Prometheus exporter listening at http://localhost:%s%s", port, path)
 }
 
 // This method blocks the execution until process is not stopped
@@ -155,7 +161,7 @@ func waitUntilSigtermArrives() {
 	LOG.Info("kill signal arrived - exiting...")
 }
 
-func simulateAppLogic() {
+func simulateAppLogic(ctx context.Context) error {
 	LOG := kt_logging.GetLogger("main.thread")
 
 	threadExecCount++
@@ -167,6 +173,7 @@ func simulateAppLogic() {
 	processingMillis := 500 + rand.Intn(1500) // will be between 500 and 2000 millis
 	LOG.Info("      simulating message pocessing took %d millis", processingMillis)
 	brokerTopic1_processingTime.Observe(float64(processingMillis))
+	brokerTopic1_processingHist.Observe(float64(processingMillis))
 
 	if threadExecCount%3 == 0 {
 		LOG.Info("      simulating message failed and was retried...")
@@ -175,9 +182,11 @@ func simulateAppLogic() {
 		if hasFailedEventually {
 			brokerTopic1_processingFailed.Inc()
 			LOG.Info("      ... and eventually failed!")
+			return fmt.Errorf("message processing failed in round #%d", threadExecCount)
 		} else {
 			LOG.Info("      ... but eventually succeeded!")
 		}
 	}
 
+	return nil
 }